@@ -1,34 +1,125 @@
 package main
 
 import (
+	"compress/gzip"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 
 	"netpus/internal/autostart"
+	"netpus/internal/brand"
 	"netpus/internal/database"
 	"netpus/internal/monitor"
+	"netpus/internal/notify"
 	"netpus/internal/tray"
 	"netpus/internal/utils"
 )
 
 // App struct
 type App struct {
-	ctx       context.Context
-	db        *database.DB
-	monitor   *monitor.Monitor
-	tray      *tray.Tray
-	config    *utils.Config
-	configMux sync.RWMutex
+	ctx        context.Context
+	db         *database.DB
+	monitor    *monitor.Monitor
+	tray       *tray.Tray
+	notifier   *notify.Notifier
+	notifyErr  error // Why the notifier failed to initialize, if it did
+	config     *utils.Config
+	configMux  sync.RWMutex
+	configFile string // Optional JSON file providing default settings
+
+	startupDelay time.Duration // Wait before starting the monitor (autostart launches only)
+
+	ready       atomic.Bool // Set once startup has opened the database and created the monitor
+	firstSample atomic.Bool // Set while the monitor takes its first sample right after startup
+
+	tripMeter    *tripMarker
+	tripMeterMux sync.Mutex
+
+	// Watched apps the monitor is waiting for; non-empty only while the process watcher paused it
+	waitingFor []string
+	// Set while the user paused monitoring; the process watcher leaves such a pause alone
+	pausedByUser bool
+	waitingMux   sync.Mutex
+
+	// Notifications held back during quiet hours, delivered when they end
+	quietQueue []notice
+	quietMux   sync.Mutex
+
+	// Most recent failed query behind a getter that returned an empty result
+	lastQueryError *QueryError
+	queryErrorMux  sync.Mutex
+
+	speedTestRunning atomic.Bool // Only one speed test runs at a time
+
+	liveOnly atomic.Bool // Set by SetLiveOnlyMode; saving is on only when neither it nor retention turns it off
+
+	// Stored app colors and labels, cached since live stats are decorated on every poll; nil until
+	// loaded and after a change
+	displays    map[string]database.AppDisplay
+	displaysMux sync.Mutex
+}
+
+// errNoDatabase is returned by bindings called while no database is open, e.g. after startup failed
+var errNoDatabase = errors.New("database is not open")
+
+// queryErrorEvent is emitted to the frontend whenever a getter's query fails
+const queryErrorEvent = "query-error"
+
+// QueryError tells the frontend that a query failed, so an empty result isn't mistaken for no data
+type QueryError struct {
+	Operation string `json:"operation"` // What was being done, e.g. "get usage stats"
+	Message   string `json:"message"`   // The underlying error
+	At        int64  `json:"at"`        // Unix timestamp
+}
+
+// notice is a notification waiting to be delivered
+type notice struct {
+	title   string
+	message string
+}
+
+// maxQueuedNotices bounds how many notifications are held during quiet hours; older ones are dropped
+const maxQueuedNotices = 20
+
+// tripMarker records monitor totals at the moment the trip meter was started
+type tripMarker struct {
+	startUpload   int64
+	startDownload int64
+	startTime     time.Time
+}
+
+// TripMeter represents usage measured since the trip meter was started
+type TripMeter struct {
+	Active         bool  `json:"active"`
+	StartedAt      int64 `json:"startedAt"`      // Unix timestamp
+	ElapsedSeconds int64 `json:"elapsedSeconds"` // Seconds since start
+	Upload         int64 `json:"upload"`         // Bytes uploaded since start
+	Download       int64 `json:"download"`       // Bytes downloaded since start
 }
 
-// NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+// NewApp creates a new App application struct.
+// configFile may name a JSON file whose settings seed defaults; settings saved in the database win.
+// startupDelay postpones monitoring, so autostart launches don't race the network stack at boot.
+func NewApp(configFile string, startupDelay time.Duration) *App {
+	return &App{
+		configFile:   configFile,
+		startupDelay: startupDelay,
+	}
 }
 
 // startup is called when the app starts
@@ -36,46 +127,190 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
 	// Initialize database
-	dbPath := utils.GetDatabasePath()
-	db, err := database.New(dbPath)
+	db, err := a.openDatabase(ctx)
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		log.Printf("Failed to initialize database: %v", err)
+		runtime.Quit(ctx)
+		return
 	}
 	a.db = db
 
-	// Load configuration
-	config, err := utils.LoadConfig(db)
+	if report := db.StartupReport(); report.RecoveredFromCorruption {
+		log.Printf("Database was corrupted and recreated; backup saved to %q (%d records salvaged)",
+			report.BackupPath, report.SalvagedRecords)
+	}
+
+	// Load configuration: defaults, then the optional config file, then stored settings
+	defaults := utils.DefaultConfig()
+	if a.configFile != "" {
+		fileConfig, err := utils.LoadConfigFile(a.configFile)
+		if err != nil {
+			log.Printf("Failed to load config file %s: %v, ignoring it", a.configFile, err)
+		} else {
+			defaults = fileConfig
+		}
+	}
+	config, err := utils.LoadConfigWithDefaults(db, defaults)
 	if err != nil {
 		log.Printf("Failed to load config: %v, using defaults", err)
 		config = utils.DefaultConfig()
 	}
 	a.config = config
+	utils.SetSIUnits(config.UseSIUnits)
 
 	// Initialize monitor
+	if !monitor.IsElevated() {
+		log.Printf("Running without administrator privileges; some processes may not be attributed")
+	}
 	a.monitor = monitor.New(db)
-	if err := a.monitor.Start(ctx); err != nil {
-		log.Printf("Failed to start monitor: %v", err)
+	a.monitor.SetExcludedApps(a.config.ExcludedApps)
+	a.monitor.SetStoreSystemTotals(a.config.StoreSystemTotals)
+	a.monitor.SetProxyApps(a.config.ProxyApps)
+	a.monitor.SetCycleCap(a.config.MaxCycleBytes, a.config.OversizedCycleAction == utils.CycleActionClamp)
+	a.monitor.SetMinProcessLifetime(time.Duration(a.config.MinProcessLifetimeSeconds) * time.Second)
+	a.monitor.SetStorageGranularity(time.Duration(a.config.StorageGranularitySeconds) * time.Second)
+	a.monitor.SetRollupByProcessTree(a.config.RollupByProcessTree)
+	a.monitor.SetTrackLANTraffic(a.config.TrackLANTraffic)
+	a.monitor.SetDestinationRules(a.config.DestinationRules)
+	if err := utils.ValidateMonitorConfig(a.config.Monitor); err != nil {
+		log.Printf("Ignoring monitor config: %v", err)
+		a.config.Monitor = utils.DefaultMonitorConfig()
+	}
+	a.monitor.SetTiming(monitorTiming(a.config.Monitor))
+	a.monitor.SetWarmupSamples(a.config.Monitor.WarmupSamples)
+	a.monitor.SetMaxTrackedApps(a.config.Monitor.MaxTrackedApps)
+	if a.config.CrashJournal {
+		a.enableJournal()
 	}
 
 	// Apply data retention setting to monitor (disable saving if set to "Do not save")
-	if a.config.DataRetention == -2 {
+	if a.config.RetentionMode() == utils.RetentionModeDoNotSave {
 		a.monitor.SetSaveEnabled(false)
 	}
 
+	// Initialize notifications
+	a.notifier, a.notifyErr = notify.New(brand.AppName)
+	if a.notifyErr != nil {
+		log.Printf("Notifications unavailable: %v", a.notifyErr)
+	}
+
 	// Initialize system tray
 	a.tray = tray.New(a)
 	go a.tray.Setup()
 
+	// Stay paused if the user paused before the last exit. This precedes startMonitor, so the
+	// monitor's warm-up collection is skipped too.
+	if val, err := db.GetSetting(pausedSetting); err == nil && val == "true" {
+		log.Printf("Monitoring was paused before the last exit, staying paused")
+		a.setPausedByUser(true)
+		a.pauseMonitoring()
+	}
+
 	// Start background tasks
 	go a.updateTrayTooltip()
 	go a.periodicCleanup()
 	go a.hourlyCleanup()
 	go a.minuteCleanup()
+	go a.processWatcher()
+	go a.screenLockWatcher()
+	go a.deliverQueuedNotices()
+	go a.scheduledBackups()
+	a.firstSample.Store(a.startupDelay == 0)
+	go a.startMonitor(ctx)
+
+	a.ready.Store(true)
+}
+
+// enableJournal replays records a crashed run left behind, then journals the batch next to the database
+func (a *App) enableJournal() {
+	path := filepath.Join(filepath.Dir(a.db.Path()), "batch.journal")
+	replayed, err := a.monitor.EnableJournal(path)
+	if err != nil {
+		log.Printf("Failed to enable batch journal: %v", err)
+		return
+	}
+	if replayed > 0 {
+		log.Printf("Recovered %d unsaved records from the batch journal", replayed)
+	}
+}
+
+// startMonitor starts collection after the startup delay, if any, then watches it.
+// The tray and window are already up, so the app stays responsive while it waits.
+func (a *App) startMonitor(ctx context.Context) {
+	if a.startupDelay > 0 {
+		log.Printf("Delaying network monitor start by %s", a.startupDelay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(a.startupDelay):
+		}
+	}
+
+	if err := a.monitor.Start(ctx); err != nil {
+		log.Printf("Failed to start monitor: %v", err)
+	}
+	a.firstSample.Store(false)
+	a.monitorWatchdog()
+}
+
+// maxOpenAttempts bounds how often startup tries to open the database before giving up
+const maxOpenAttempts = 3
+
+// openDatabase opens the database, asking the user how to proceed when it can't be opened
+// (typically because a stale Netpus process still holds the file) instead of exiting silently.
+// The user can retry or pick another folder, which is remembered for later launches; cancelling
+// the folder picker or running out of attempts gives up.
+func (a *App) openDatabase(ctx context.Context) (*database.DB, error) {
+	dbPath, err := utils.GetDatabasePath()
+	if err != nil {
+		return nil, err
+	}
+	moved := false
+	for attempt := 1; ; attempt++ {
+		db, err := database.New(dbPath)
+		if err == nil {
+			if moved {
+				if err := utils.SaveDatabaseLocation(dbPath); err != nil {
+					log.Printf("Failed to save database location %s: %v", dbPath, err)
+				}
+			}
+			return db, nil
+		}
+		log.Printf("Failed to open database %s (attempt %d of %d): %v", dbPath, attempt, maxOpenAttempts, err)
+		if attempt == maxOpenAttempts {
+			return nil, err
+		}
+
+		choice, dialogErr := runtime.MessageDialog(ctx, runtime.MessageDialogOptions{
+			Type:  runtime.QuestionDialog,
+			Title: brand.AppName + " could not open its database",
+			Message: fmt.Sprintf("%s\n\n%v\n\nAnother %s process may still be using it.\n\n"+
+				"Choose a different folder for the database? Select No to try again.", dbPath, err, brand.AppName),
+		})
+		if dialogErr != nil {
+			return nil, err
+		}
+		if choice != "Yes" {
+			continue
+		}
+
+		dir, dirErr := runtime.OpenDirectoryDialog(ctx, runtime.OpenDialogOptions{
+			Title: "Choose a folder for the " + brand.AppName + " database",
+		})
+		if dirErr != nil || dir == "" {
+			return nil, err
+		}
+		dbPath = filepath.Join(dir, brand.DatabaseFileName())
+		moved = true
+	}
 }
 
 // domReady is called after front-end resources have been loaded
 func (a *App) domReady(ctx context.Context) {
-	// Optional: perform actions after DOM is ready
+	// Let the frontend know about migrations or corruption recovery
+	if a.db != nil {
+		runtime.EventsEmit(ctx, "startup-report", a.db.StartupReport())
+	}
 }
 
 // beforeClose is called when the application is about to quit
@@ -87,6 +322,7 @@ func (a *App) beforeClose(ctx context.Context) (prevent bool) {
 
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
+	// Stop blocks until the final batch is written, so the database must close after it
 	if a.monitor != nil {
 		a.monitor.Stop()
 	}
@@ -98,12 +334,369 @@ func (a *App) shutdown(ctx context.Context) {
 	}
 }
 
+// queryFailed logs a query error that a getter is about to hide behind an empty result, and emits
+// it as a "query-error" event so the frontend can show an error state instead of "no data"
+func (a *App) queryFailed(operation string, err error) {
+	log.Printf("Failed to %s: %v", operation, err)
+
+	queryErr := QueryError{Operation: operation, Message: err.Error(), At: time.Now().Unix()}
+	a.queryErrorMux.Lock()
+	a.lastQueryError = &queryErr
+	a.queryErrorMux.Unlock()
+
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, queryErrorEvent, queryErr)
+	}
+}
+
+// GetLastQueryError returns the most recent query failure, or nil if none happened yet, for
+// frontends that poll instead of listening for "query-error" events
+func (a *App) GetLastQueryError() *QueryError {
+	a.queryErrorMux.Lock()
+	defer a.queryErrorMux.Unlock()
+	if a.lastQueryError == nil {
+		return nil
+	}
+	queryErr := *a.lastQueryError
+	return &queryErr
+}
+
+// IsReady reports whether the backend can serve data: the database is open, the monitor is
+// created and, when it starts right away, has taken its first sample. The frontend polls it before
+// rendering so the dashboard doesn't start at zero. A monitor that starts paused, waits for watched
+// apps or is delayed takes no sample yet, so it doesn't hold readiness back.
+func (a *App) IsReady() bool {
+	return a.ready.Load() && !a.firstSample.Load()
+}
+
+// GetStartupReport returns database migration and corruption recovery results
+func (a *App) GetStartupReport() database.StartupReport {
+	if a.db == nil {
+		return database.StartupReport{}
+	}
+	return a.db.StartupReport()
+}
+
 // GetNetworkStats returns current network statistics
 func (a *App) GetNetworkStats() map[string]*monitor.NetworkStat {
 	if a.monitor == nil {
 		return make(map[string]*monitor.NetworkStat)
 	}
-	return a.monitor.GetStats()
+	return a.withLiveDisplay(a.monitor.GetStats())
+}
+
+// GetActiveNetworkStats returns live statistics only for apps with non-zero current speed
+func (a *App) GetActiveNetworkStats() map[string]*monitor.NetworkStat {
+	if a.monitor == nil {
+		return make(map[string]*monitor.NetworkStat)
+	}
+	return a.withLiveDisplay(a.monitor.GetActiveStats())
+}
+
+// GetTotalSpeed returns only the current total speeds in bytes per second, as "upload" and
+// "download", for compact views that don't need per-app stats
+func (a *App) GetTotalSpeed() map[string]int64 {
+	if a.monitor == nil {
+		return map[string]int64{"upload": 0, "download": 0}
+	}
+	upload, download := a.monitor.GetTotalSpeed()
+	return map[string]int64{"upload": upload, "download": download}
+}
+
+// IsAppActive reports whether an app is currently moving data. Names match case-insensitively,
+// with or without ".exe"; unknown apps are inactive.
+func (a *App) IsAppActive(appName string) bool {
+	if a.monitor == nil {
+		return false
+	}
+	upload, download := a.monitor.GetAppSpeed(appName)
+	return upload > 0 || download > 0
+}
+
+// GetAppCurrentSpeed returns one app's current speeds in bytes per second, as
+// {"upload": ..., "download": ...}, so widgets can poll a single app without fetching every stat.
+// Unknown apps report zeros.
+func (a *App) GetAppCurrentSpeed(appName string) map[string]int64 {
+	if a.monitor == nil {
+		return map[string]int64{"upload": 0, "download": 0}
+	}
+	upload, download := a.monitor.GetAppSpeed(appName)
+	return map[string]int64{"upload": upload, "download": download}
+}
+
+// GetActiveConnections returns the TCP/UDP connections the monitor last used to attribute traffic,
+// for checking why an app is credited with its usage
+func (a *App) GetActiveConnections() []monitor.ConnectionInfo {
+	if a.monitor == nil {
+		return []monitor.ConnectionInfo{}
+	}
+	return a.monitor.GetConnections()
+}
+
+// ListInterfaces returns the machine's network interfaces with their status
+func (a *App) ListInterfaces() []monitor.InterfaceInfo {
+	interfaces, err := monitor.ListInterfaces()
+	if err != nil {
+		a.queryFailed("list network interfaces", err)
+		return []monitor.InterfaceInfo{}
+	}
+	return interfaces
+}
+
+// Interface preview sampling: previewSamples cycles of previewSampleInterval, abandoned after previewTimeout
+const (
+	previewSamples        = 2
+	previewSampleInterval = 500 * time.Millisecond
+	previewTimeout        = 3 * time.Second
+)
+
+// InterfacePreview shows whether an interface is carrying traffic before it is selected
+type InterfacePreview struct {
+	Found           bool                  `json:"found"`           // The LUID or alias matched an interface
+	Interface       monitor.InterfaceInfo `json:"interface"`       // Status at the last sample
+	Samples         []monitor.SpeedSample `json:"samples"`         // Speeds over each sample cycle, oldest first
+	UploadSpeed     int64                 `json:"uploadSpeed"`     // Bytes per second averaged over the samples
+	DownloadSpeed   int64                 `json:"downloadSpeed"`   // Bytes per second averaged over the samples
+	CarryingTraffic bool                  `json:"carryingTraffic"` // Up and moved bytes while sampled
+	Error           string                `json:"error,omitempty"` // Why sampling failed or stopped early
+}
+
+// findInterface returns the interface whose LUID or alias (case-insensitive) is luidOrAlias
+func findInterface(interfaces []monitor.InterfaceInfo, luidOrAlias string) (monitor.InterfaceInfo, bool) {
+	for _, iface := range interfaces {
+		if strconv.FormatUint(iface.LUID, 10) == luidOrAlias || strings.EqualFold(iface.Alias, luidOrAlias) {
+			return iface, true
+		}
+	}
+	return monitor.InterfaceInfo{}, false
+}
+
+// PreviewInterface samples an interface's throughput for a few short cycles and reports whether it
+// is up and carrying traffic, so a disconnected adapter isn't selected by mistake. Sampling never
+// takes longer than previewTimeout.
+func (a *App) PreviewInterface(luidOrAlias string) InterfacePreview {
+	preview := InterfacePreview{Samples: []monitor.SpeedSample{}}
+	luidOrAlias = strings.TrimSpace(luidOrAlias)
+
+	interfaces, err := monitor.ListInterfaces()
+	if err != nil {
+		preview.Error = err.Error()
+		return preview
+	}
+	previous, ok := findInterface(interfaces, luidOrAlias)
+	if !ok {
+		preview.Error = fmt.Sprintf("no interface named %q", luidOrAlias)
+		return preview
+	}
+	preview.Found = true
+	preview.Interface = previous
+
+	start := time.Now()
+	previousAt := start
+	deadline := time.NewTimer(previewTimeout)
+	defer deadline.Stop()
+	var sent, received uint64
+
+	for len(preview.Samples) < previewSamples {
+		select {
+		case <-a.ctx.Done():
+			preview.Error = "sampling cancelled"
+			return preview
+		case <-deadline.C:
+			preview.Error = "sampling timed out"
+			return preview
+		case <-time.After(previewSampleInterval):
+		}
+
+		interfaces, err := monitor.ListInterfaces()
+		if err != nil {
+			preview.Error = err.Error()
+			return preview
+		}
+		current, ok := findInterface(interfaces, strconv.FormatUint(previous.LUID, 10))
+		if !ok {
+			preview.Error = "interface disappeared while sampling"
+			preview.Interface.Up = false
+			return preview
+		}
+
+		now := time.Now()
+		elapsed := now.Sub(previousAt).Seconds()
+		// Counters restart when an adapter resets, so a decrease counts as no traffic
+		var upDelta, downDelta uint64
+		if current.BytesSent >= previous.BytesSent {
+			upDelta = current.BytesSent - previous.BytesSent
+		}
+		if current.BytesReceived >= previous.BytesReceived {
+			downDelta = current.BytesReceived - previous.BytesReceived
+		}
+		preview.Samples = append(preview.Samples, monitor.SpeedSample{
+			Timestamp:     now.UnixMilli(),
+			UploadSpeed:   int64(float64(upDelta) / elapsed),
+			DownloadSpeed: int64(float64(downDelta) / elapsed),
+		})
+		sent += upDelta
+		received += downDelta
+		preview.Interface = current
+		previous, previousAt = current, now
+	}
+
+	elapsed := previousAt.Sub(start).Seconds()
+	preview.UploadSpeed = int64(float64(sent) / elapsed)
+	preview.DownloadSpeed = int64(float64(received) / elapsed)
+	preview.CarryingTraffic = preview.Interface.Up && sent+received > 0
+	return preview
+}
+
+// Speed test bounds: the download stops at speedTestMaxBytes or after speedTestTimeout, whichever
+// comes first, and speedTestHistory results are returned by GetSpeedTestHistory
+const (
+	speedTestTimeout  = 30 * time.Second
+	speedTestMaxBytes = 100 << 20
+	speedTestHistory  = 100
+)
+
+// SpeedTestResult is the throughput measured by one speed test
+type SpeedTestResult struct {
+	Timestamp      int64  `json:"timestamp"`       // Unix timestamp the test started
+	URL            string `json:"url"`             // Payload that was downloaded
+	Bytes          int64  `json:"bytes"`           // Payload bytes received
+	DurationMs     int64  `json:"durationMs"`      // Time spent downloading
+	DownloadSpeed  int64  `json:"downloadSpeed"`   // Bytes per second achieved by the download
+	InterfaceSpeed int64  `json:"interfaceSpeed"`  // Bytes per second received by all interfaces meanwhile, other traffic included; 0 if unknown
+	Error          string `json:"error,omitempty"` // Why the test failed
+}
+
+// RunSpeedTest downloads the configured speed test payload and records the throughput achieved,
+// as a baseline for the usage figures. The interface counters are read around the download to
+// corroborate it. A test cut short by the time limit still reports what it received.
+func (a *App) RunSpeedTest() SpeedTestResult {
+	if a.db == nil {
+		return SpeedTestResult{Timestamp: time.Now().Unix(), Error: errNoDatabase.Error()}
+	}
+	a.configMux.RLock()
+	result := SpeedTestResult{Timestamp: time.Now().Unix(), URL: a.config.SpeedTestURL}
+	a.configMux.RUnlock()
+
+	if !a.speedTestRunning.CompareAndSwap(false, true) {
+		result.Error = "a speed test is already running"
+		return result
+	}
+	defer a.speedTestRunning.Store(false)
+
+	if err := a.downloadSpeedTest(&result); err != nil {
+		result.Error = err.Error()
+	}
+	test := database.SpeedTest{
+		Timestamp:      result.Timestamp,
+		URL:            result.URL,
+		Bytes:          result.Bytes,
+		DurationMs:     result.DurationMs,
+		DownloadSpeed:  result.DownloadSpeed,
+		InterfaceSpeed: result.InterfaceSpeed,
+		Error:          result.Error,
+	}
+	if err := a.db.InsertSpeedTest(test); err != nil {
+		log.Printf("Failed to store speed test result: %v", err)
+	}
+	return result
+}
+
+// downloadSpeedTest performs the timed download and fills in result's measurements
+func (a *App) downloadSpeedTest(result *SpeedTestResult) error {
+	ctx, cancel := context.WithTimeout(a.ctx, speedTestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid speed test URL: %w", err)
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("speed test request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("speed test server returned %s", resp.Status)
+	}
+
+	// Timing starts once the server answers, so connection setup doesn't count against throughput
+	receivedBefore, countersOK := receivedBytes()
+	start := time.Now()
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, speedTestMaxBytes))
+	elapsed := time.Since(start)
+	receivedAfter, countersAfterOK := receivedBytes()
+
+	if err != nil && !(errors.Is(err, context.DeadlineExceeded) && n > 0) {
+		return fmt.Errorf("speed test download failed: %w", err)
+	}
+	result.Bytes = n
+	result.DurationMs = elapsed.Milliseconds()
+	if elapsed > 0 {
+		result.DownloadSpeed = int64(float64(n) / elapsed.Seconds())
+		if countersOK && countersAfterOK && receivedAfter >= receivedBefore {
+			result.InterfaceSpeed = int64(float64(receivedAfter-receivedBefore) / elapsed.Seconds())
+		}
+	}
+	return nil
+}
+
+// receivedBytes sums the receive counters of every interface that is up
+func receivedBytes() (uint64, bool) {
+	interfaces, err := monitor.ListInterfaces()
+	if err != nil {
+		return 0, false
+	}
+	var total uint64
+	for _, iface := range interfaces {
+		if iface.Up {
+			total += iface.BytesReceived
+		}
+	}
+	return total, true
+}
+
+// GetSpeedTestHistory returns recent speed test results, newest first
+func (a *App) GetSpeedTestHistory() []SpeedTestResult {
+	if a.db == nil {
+		return nil
+	}
+	tests, err := a.db.GetSpeedTests(speedTestHistory)
+	if err != nil {
+		a.queryFailed("get speed test history", err)
+		return []SpeedTestResult{}
+	}
+	results := make([]SpeedTestResult, len(tests))
+	for i, test := range tests {
+		results[i] = SpeedTestResult{
+			Timestamp:      test.Timestamp,
+			URL:            test.URL,
+			Bytes:          test.Bytes,
+			DurationMs:     test.DurationMs,
+			DownloadSpeed:  test.DownloadSpeed,
+			InterfaceSpeed: test.InterfaceSpeed,
+			Error:          test.Error,
+		}
+	}
+	return results
+}
+
+// GetSpeedHistory returns recent total speed samples for the live graph, oldest first
+func (a *App) GetSpeedHistory() []monitor.SpeedSample {
+	if a.monitor == nil {
+		return []monitor.SpeedSample{}
+	}
+	return a.monitor.GetRecentSamples()
+}
+
+// RefreshStatsNow collects fresh statistics immediately instead of waiting for the next tick
+func (a *App) RefreshStatsNow() map[string]*monitor.NetworkStat {
+	if a.monitor == nil {
+		return make(map[string]*monitor.NetworkStat)
+	}
+	return a.withLiveDisplay(a.monitor.CollectNow())
 }
 
 // GetMonitorStatus returns the current monitor status
@@ -114,11 +707,75 @@ func (a *App) GetMonitorStatus() monitor.MonitorStatus {
 	return a.monitor.GetMonitorStatus()
 }
 
+// GetProcessCacheStats returns how often process lookups were served from the cache
+func (a *App) GetProcessCacheStats() monitor.ProcessCacheStats {
+	if a.monitor == nil {
+		return monitor.ProcessCacheStats{}
+	}
+	return a.monitor.GetProcessCacheStats()
+}
+
+// GetFlushDiagnostics returns how the database batch writer is performing
+func (a *App) GetFlushDiagnostics() monitor.FlushStats {
+	if a.monitor == nil {
+		return monitor.FlushStats{}
+	}
+	return a.monitor.GetFlushStats()
+}
+
+// SaveInfo tells whether usage is reaching the database
+type SaveInfo struct {
+	SaveEnabled     bool   `json:"saveEnabled"`     // False when retention is set to not save
+	LastSavedAt     int64  `json:"lastSavedAt"`     // Unix timestamp of the last successful write; 0 if none yet
+	SecondsSince    int64  `json:"secondsSince"`    // Since LastSavedAt; -1 if nothing was saved yet
+	LastFlushFailed bool   `json:"lastFlushFailed"` // The most recent write attempt failed
+	LastError       string `json:"lastError"`       // Error of the most recent write when it failed
+	PendingRecords  int    `json:"pendingRecords"`  // Records waiting for the next write
+}
+
+// GetLastSaveInfo returns when usage was last written and whether the latest write failed, so the
+// UI can show "last saved 8s ago" or warn that saving is failing. Counters reset by
+// ResetFlushDiagnostics reset this too.
+func (a *App) GetLastSaveInfo() SaveInfo {
+	info := SaveInfo{SecondsSince: -1}
+	if a.monitor == nil {
+		return info
+	}
+	stats := a.monitor.GetFlushStats()
+	info.SaveEnabled = a.monitor.GetMonitorStatus().SaveEnabled
+	info.LastSavedAt = stats.LastFlushAt
+	if stats.LastFlushAt > 0 {
+		info.SecondsSince = time.Now().Unix() - stats.LastFlushAt
+	}
+	info.LastFlushFailed = stats.LastFailed
+	if stats.LastFailed {
+		info.LastError = stats.LastError
+	}
+	info.PendingRecords = stats.PendingRecords
+	return info
+}
+
+// ResetFlushDiagnostics clears the batch writer counters
+func (a *App) ResetFlushDiagnostics() {
+	if a.monitor != nil {
+		a.monitor.ResetFlushStats()
+	}
+}
+
+// IsElevated reports whether Netpus is running with administrator privileges
+func (a *App) IsElevated() bool {
+	return monitor.IsElevated()
+}
+
 // GetTodayStats returns today's total upload and download
 func (a *App) GetTodayStats() map[string]interface{} {
+	if a.db == nil {
+		return map[string]interface{}{"upload": int64(0), "download": int64(0)}
+	}
 	today := time.Now().Format("2006-01-02")
 	summary, err := a.db.GetDailySummary(today)
 	if err != nil {
+		a.queryFailed("get today's totals", err)
 		return map[string]interface{}{
 			"upload":   int64(0),
 			"download": int64(0),
@@ -130,156 +787,1895 @@ func (a *App) GetTodayStats() map[string]interface{} {
 	}
 }
 
-// Get24HourUsage returns the last 24 hours usage statistics
-func (a *App) Get24HourUsage() map[string]interface{} {
-	stats, err := a.db.Get24HourUsage()
-	if err != nil {
-		return map[string]interface{}{
-			"upload":   int64(0),
-			"download": int64(0),
-		}
+// GetAllTimeStats returns total upload and download across all stored days
+func (a *App) GetAllTimeStats() map[string]interface{} {
+	if a.db == nil {
+		return map[string]interface{}{"upload": int64(0), "download": int64(0)}
+	}
+	upload, download, err := a.db.GetAllTimeTotals()
+	if err != nil {
+		a.queryFailed("get all-time totals", err)
+		return map[string]interface{}{
+			"upload":   int64(0),
+			"download": int64(0),
+		}
+	}
+	return map[string]interface{}{
+		"upload":   upload,
+		"download": download,
+	}
+}
+
+// GetLifetimeTotals returns every byte Netpus has ever stored; retention cleanup never lowers it
+func (a *App) GetLifetimeTotals() map[string]interface{} {
+	if a.db == nil {
+		return map[string]interface{}{"upload": int64(0), "download": int64(0)}
+	}
+	upload, download, err := a.db.GetLifetimeTotals()
+	if err != nil {
+		a.queryFailed("get lifetime totals", err)
+		return map[string]interface{}{
+			"upload":   int64(0),
+			"download": int64(0),
+		}
+	}
+	return map[string]interface{}{
+		"upload":   upload,
+		"download": download,
+	}
+}
+
+// Get24HourUsage returns the last 24 hours usage statistics
+func (a *App) Get24HourUsage() map[string]interface{} {
+	if a.db == nil {
+		return map[string]interface{}{"upload": int64(0), "download": int64(0)}
+	}
+	stats, err := a.db.Get24HourUsage()
+	if err != nil {
+		a.queryFailed("get 24-hour usage", err)
+		return map[string]interface{}{
+			"upload":   int64(0),
+			"download": int64(0),
+		}
+	}
+	return map[string]interface{}{
+		"upload":   stats["upload"],
+		"download": stats["download"],
+	}
+}
+
+// GetSystemTotals returns raw system-wide totals for the last N days alongside the per-app attributed totals
+func (a *App) GetSystemTotals(days int) (map[string]interface{}, error) {
+	if a.db == nil {
+		return nil, errNoDatabase
+	}
+	if days < 0 {
+		return nil, fmt.Errorf("invalid range: %d days", days)
+	}
+	endTime := time.Now().Unix()
+	startTime := time.Now().AddDate(0, 0, -days).Unix()
+
+	result := map[string]interface{}{
+		"upload":             int64(0),
+		"download":           int64(0),
+		"attributedUpload":   int64(0),
+		"attributedDownload": int64(0),
+	}
+
+	system, err := a.db.GetSystemUsage(startTime, endTime)
+	if err != nil {
+		a.queryFailed("get system totals", err)
+		return result, nil
+	}
+	result["upload"] = system["upload"]
+	result["download"] = system["download"]
+
+	stats, err := a.db.GetAppUsageStats(startTime, endTime)
+	if err != nil {
+		a.queryFailed("get attributed totals", err)
+		return result, nil
+	}
+	var attributedUp, attributedDown int64
+	for _, stat := range stats {
+		attributedUp += stat.TotalUpload
+		attributedDown += stat.TotalDownload
+	}
+	result["attributedUpload"] = attributedUp
+	result["attributedDownload"] = attributedDown
+
+	return result, nil
+}
+
+// GetNetworkUsageStats returns aggregated network usage statistics
+func (a *App) GetNetworkUsageStats() []database.AppUsageStat {
+	if a.db == nil {
+		return nil
+	}
+	// Only day-based retention limits the window; 0 means all time
+	days := 0
+	if a.config.RetentionMode() == utils.RetentionModeDays {
+		days = a.config.DataRetention
+	}
+	stats, err := a.db.GetAppUsageWithRetention(days)
+	if err != nil {
+		a.queryFailed("get usage stats", err)
+		return []database.AppUsageStat{}
+	}
+	return a.withTiers(a.withDisplay(a.filterExcludedApps(stats)))
+}
+
+// GetUsageLastHours returns per-app usage statistics for the last N hours
+func (a *App) GetUsageLastHours(hours int) ([]database.AppUsageStat, error) {
+	if a.db == nil {
+		return nil, errNoDatabase
+	}
+	if hours <= 0 {
+		return nil, fmt.Errorf("invalid number of hours: %d", hours)
+	}
+	stats, err := a.db.GetAppUsageHours(hours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage for the last %d hours: %w", hours, err)
+	}
+	return a.withDisplay(a.filterExcludedApps(stats)), nil
+}
+
+// UsageBreakdown is the top apps plus one aggregate for everything else, summing to the grand total
+type UsageBreakdown struct {
+	Top       []database.AppUsageStat `json:"top"`
+	Other     database.AppUsageStat   `json:"other"`
+	OtherApps int                     `json:"otherApps"` // Number of apps lumped into Other
+}
+
+// GetUsageBreakdownWithOther returns the top N apps within the retention window and an "Other"
+// entry aggregating the rest, so a pie chart of the result sums to 100%
+func (a *App) GetUsageBreakdownWithOther(limit int) UsageBreakdown {
+	if a.db == nil {
+		return UsageBreakdown{}
+	}
+	breakdown := UsageBreakdown{
+		Top:   []database.AppUsageStat{},
+		Other: database.AppUsageStat{AppName: "Other"},
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	a.configMux.RLock()
+	days := 0
+	if a.config.RetentionMode() == utils.RetentionModeDays {
+		days = a.config.DataRetention
+	}
+	excluded := make([]string, 0, len(a.config.ExcludedApps))
+	for _, app := range a.config.ExcludedApps {
+		excluded = append(excluded, utils.CanonicalAppName(app))
+	}
+	a.configMux.RUnlock()
+
+	var startTime int64
+	if days > 0 {
+		startTime = time.Now().AddDate(0, 0, -days).Unix()
+	}
+	top, other, otherApps, err := a.db.GetUsageBreakdownWithOther(startTime, time.Now().Unix(), limit, excluded)
+	if err != nil {
+		a.queryFailed("get usage breakdown", err)
+		return breakdown
+	}
+	if top != nil {
+		breakdown.Top = a.withDisplay(top)
+	}
+	breakdown.Other = other
+	breakdown.OtherApps = otherApps
+	return breakdown
+}
+
+// SearchApps returns usage statistics for apps whose name contains the query
+func (a *App) SearchApps(query string) []database.AppUsageStat {
+	if a.db == nil {
+		return nil
+	}
+	const searchLimit = 50
+	stats, err := a.db.SearchApps(query, searchLimit)
+	if err != nil {
+		a.queryFailed("search apps", err)
+		return []database.AppUsageStat{}
+	}
+	return a.withDisplay(a.filterExcludedApps(stats))
+}
+
+// GetTopUploaders returns the apps that uploaded the most over the last N days
+func (a *App) GetTopUploaders(days int) []database.AppUsageStat {
+	return a.getTopByDirection(days, "upload")
+}
+
+// GetTopDownloaders returns the apps that downloaded the most over the last N days
+func (a *App) GetTopDownloaders(days int) []database.AppUsageStat {
+	return a.getTopByDirection(days, "download")
+}
+
+// getTopByDirection returns the top apps for one traffic direction
+func (a *App) getTopByDirection(days int, direction string) []database.AppUsageStat {
+	if a.db == nil {
+		return nil
+	}
+	const topLimit = 10
+	stats, err := a.db.GetTopByDirection(days, topLimit, direction)
+	if err != nil {
+		a.queryFailed(fmt.Sprintf("get top %s apps", direction), err)
+		return []database.AppUsageStat{}
+	}
+	return a.withDisplay(a.filterExcludedApps(stats))
+}
+
+// GetRecentlySeenApps returns apps that first used the network within the last N hours
+func (a *App) GetRecentlySeenApps(sinceHours int) []database.AppMetadata {
+	if a.db == nil {
+		return nil
+	}
+	since := time.Now().Add(-time.Duration(sinceHours) * time.Hour).Unix()
+	apps, err := a.db.GetAppsFirstSeenSince(since)
+	if err != nil {
+		a.queryFailed("get recently seen apps", err)
+		return []database.AppMetadata{}
+	}
+
+	a.configMux.RLock()
+	defer a.configMux.RUnlock()
+	filtered := make([]database.AppMetadata, 0, len(apps))
+	for _, app := range apps {
+		if !a.config.IsAppExcluded(app.AppName) {
+			filtered = append(filtered, app)
+		}
+	}
+	return filtered
+}
+
+// ProtocolBreakdown splits an app's stored usage between TCP and UDP
+type ProtocolBreakdown struct {
+	AppName         string `json:"appName"`
+	TCPUpload       int64  `json:"tcpUpload"`
+	TCPDownload     int64  `json:"tcpDownload"`
+	UDPUpload       int64  `json:"udpUpload"`
+	UDPDownload     int64  `json:"udpDownload"`
+	UnknownUpload   int64  `json:"unknownUpload"`   // Records stored before protocols were tracked
+	UnknownDownload int64  `json:"unknownDownload"` // Records stored before protocols were tracked
+}
+
+// GetAppProtocolBreakdown returns an app's TCP vs UDP totals within the retention window.
+// Protocols the app never used are reported as zero.
+func (a *App) GetAppProtocolBreakdown(appName string) ProtocolBreakdown {
+	if a.db == nil {
+		return ProtocolBreakdown{}
+	}
+	breakdown := ProtocolBreakdown{AppName: appName}
+
+	a.configMux.RLock()
+	days := 0
+	if a.config.RetentionMode() == utils.RetentionModeDays {
+		days = a.config.DataRetention
+	}
+	a.configMux.RUnlock()
+	stats, err := a.db.GetUsageByAppAndProtocol(appName, days)
+	if err != nil {
+		a.queryFailed(fmt.Sprintf("get protocol breakdown for %s", appName), err)
+		return breakdown
+	}
+
+	for _, stat := range stats {
+		switch stat.Protocol {
+		case "tcp":
+			breakdown.TCPUpload += stat.TotalUpload
+			breakdown.TCPDownload += stat.TotalDownload
+		case "udp":
+			breakdown.UDPUpload += stat.TotalUpload
+			breakdown.UDPDownload += stat.TotalDownload
+		default:
+			breakdown.UnknownUpload += stat.TotalUpload
+			breakdown.UnknownDownload += stat.TotalDownload
+		}
+	}
+	return breakdown
+}
+
+// AddressFamilyBreakdown splits stored usage between IPv4 and IPv6
+type AddressFamilyBreakdown struct {
+	IPv4Upload      int64 `json:"ipv4Upload"`
+	IPv4Download    int64 `json:"ipv4Download"`
+	IPv6Upload      int64 `json:"ipv6Upload"`
+	IPv6Download    int64 `json:"ipv6Download"`
+	UnknownUpload   int64 `json:"unknownUpload"`   // Records stored before address families were tracked
+	UnknownDownload int64 `json:"unknownDownload"` // Records stored before address families were tracked
+}
+
+// GetProtocolFamilyBreakdown returns IPv4 vs IPv6 totals over the last N days (0 means all time)
+func (a *App) GetProtocolFamilyBreakdown(days int) AddressFamilyBreakdown {
+	if a.db == nil {
+		return AddressFamilyBreakdown{}
+	}
+	var breakdown AddressFamilyBreakdown
+	if days < 0 {
+		days = 0
+	}
+
+	stats, err := a.db.GetUsageByAddressFamily(days)
+	if err != nil {
+		a.queryFailed("get address family breakdown", err)
+		return breakdown
+	}
+
+	for _, stat := range stats {
+		switch stat.Family {
+		case "ipv4":
+			breakdown.IPv4Upload += stat.TotalUpload
+			breakdown.IPv4Download += stat.TotalDownload
+		case "ipv6":
+			breakdown.IPv6Upload += stat.TotalUpload
+			breakdown.IPv6Download += stat.TotalDownload
+		default:
+			breakdown.UnknownUpload += stat.TotalUpload
+			breakdown.UnknownDownload += stat.TotalDownload
+		}
+	}
+	return breakdown
+}
+
+// GetUsageByDestination returns stored usage grouped by destination service over the last N days
+// (0 means all time). Traffic matching no destination rule is grouped under "Other".
+func (a *App) GetUsageByDestination(days int) []database.DestinationStat {
+	if a.db == nil {
+		return nil
+	}
+	if days < 0 {
+		days = 0
+	}
+	stats, err := a.db.GetUsageByDestination(days)
+	if err != nil {
+		a.queryFailed("get usage by destination", err)
+		return []database.DestinationStat{}
+	}
+	return stats
+}
+
+// AppComparison lists apps that started or stopped using the network compared to yesterday
+type AppComparison struct {
+	Added       []database.AppUsageStat `json:"added"`       // Active today but not yesterday
+	Disappeared []database.AppUsageStat `json:"disappeared"` // Active yesterday but not today
+}
+
+// GetAppsComparedToYesterday compares the apps active today with those active yesterday
+func (a *App) GetAppsComparedToYesterday() AppComparison {
+	if a.db == nil {
+		return AppComparison{}
+	}
+	comparison := AppComparison{
+		Added:       []database.AppUsageStat{},
+		Disappeared: []database.AppUsageStat{},
+	}
+
+	now := time.Now()
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	yesterdayStart := todayStart.AddDate(0, 0, -1)
+
+	today, err := a.db.GetAppUsageStats(todayStart.Unix(), now.Unix())
+	if err != nil {
+		a.queryFailed("get today's app usage", err)
+		return comparison
+	}
+	yesterday, err := a.db.GetAppUsageStats(yesterdayStart.Unix(), todayStart.Unix()-1)
+	if err != nil {
+		a.queryFailed("get yesterday's app usage", err)
+		return comparison
+	}
+	today = a.filterExcludedApps(today)
+	yesterday = a.filterExcludedApps(yesterday)
+
+	seenToday := make(map[string]bool, len(today))
+	for _, stat := range today {
+		seenToday[stat.AppName] = true
+	}
+	seenYesterday := make(map[string]bool, len(yesterday))
+	for _, stat := range yesterday {
+		seenYesterday[stat.AppName] = true
+	}
+
+	for _, stat := range today {
+		if !seenYesterday[stat.AppName] {
+			comparison.Added = append(comparison.Added, stat)
+		}
+	}
+	for _, stat := range yesterday {
+		if !seenToday[stat.AppName] {
+			comparison.Disappeared = append(comparison.Disappeared, stat)
+		}
+	}
+
+	return comparison
+}
+
+// comparisonPeriods maps CompareUsage presets to their window length
+var comparisonPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+// comparisonTopApps is how many apps CompareUsage reports individually
+const comparisonTopApps = 10
+
+// UsageComparison compares the latest period's usage with the period just before it
+type UsageComparison struct {
+	Period        string         `json:"period"`        // "day", "week" or "month"
+	CurrentStart  int64          `json:"currentStart"`  // Unix timestamp; the current period runs to now
+	PreviousStart int64          `json:"previousStart"` // Unix timestamp; the previous period ends at CurrentStart
+	CurrentTotal  int64          `json:"currentTotal"`  // Upload + download bytes
+	PreviousTotal int64          `json:"previousTotal"` // Upload + download bytes
+	PercentChange float64        `json:"percentChange"` // Change relative to the previous period; 0 when it had no usage
+	NoBaseline    bool           `json:"noBaseline"`    // The previous period had no usage, so no percentage is meaningful
+	Apps          []AppUsageDiff `json:"apps"`          // Busiest apps in either period, busiest first
+}
+
+// AppUsageDiff is one app's usage in both compared periods
+type AppUsageDiff struct {
+	AppName       string  `json:"appName"`
+	DisplayLabel  string  `json:"displayLabel"`
+	CurrentTotal  int64   `json:"currentTotal"`
+	PreviousTotal int64   `json:"previousTotal"`
+	Change        int64   `json:"change"`        // CurrentTotal - PreviousTotal
+	PercentChange float64 `json:"percentChange"` // 0 when the app had no previous usage
+	NoBaseline    bool    `json:"noBaseline"`    // The app had no usage in the previous period
+}
+
+// percentChange returns the change from previous to current in percent, and false when previous
+// is zero and there is nothing to compare against
+func percentChange(current, previous int64) (float64, bool) {
+	if previous == 0 {
+		return 0, false
+	}
+	return float64(current-previous) / float64(previous) * 100, true
+}
+
+// CompareUsage compares usage over a rolling period ("day", "week" or "month", i.e. the last
+// 24 hours, 7 days or 30 days) with the equally long period before it, overall and per app
+func (a *App) CompareUsage(period string) UsageComparison {
+	if a.db == nil {
+		return UsageComparison{}
+	}
+	comparison := UsageComparison{Period: period, Apps: []AppUsageDiff{}}
+	length, ok := comparisonPeriods[period]
+	if !ok {
+		log.Printf("Invalid comparison period: %q", period)
+		return comparison
+	}
+
+	now := time.Now()
+	currentStart := now.Add(-length)
+	previousStart := currentStart.Add(-length)
+	comparison.CurrentStart = currentStart.Unix()
+	comparison.PreviousStart = previousStart.Unix()
+
+	current, err := a.db.GetAppUsageStats(currentStart.Unix(), now.Unix())
+	if err != nil {
+		a.queryFailed(fmt.Sprintf("get current %s usage", period), err)
+		return comparison
+	}
+	previous, err := a.db.GetAppUsageStats(previousStart.Unix(), currentStart.Unix()-1)
+	if err != nil {
+		a.queryFailed(fmt.Sprintf("get previous %s usage", period), err)
+		return comparison
+	}
+	current = a.withDisplay(a.filterExcludedApps(current))
+	previous = a.withDisplay(a.filterExcludedApps(previous))
+
+	diffs := make(map[string]*AppUsageDiff)
+	diffFor := func(stat database.AppUsageStat) *AppUsageDiff {
+		diff, ok := diffs[stat.AppName]
+		if !ok {
+			diff = &AppUsageDiff{AppName: stat.AppName, DisplayLabel: stat.DisplayLabel}
+			diffs[stat.AppName] = diff
+		}
+		return diff
+	}
+	for _, stat := range current {
+		total := stat.TotalUpload + stat.TotalDownload
+		comparison.CurrentTotal += total
+		diffFor(stat).CurrentTotal = total
+	}
+	for _, stat := range previous {
+		total := stat.TotalUpload + stat.TotalDownload
+		comparison.PreviousTotal += total
+		diffFor(stat).PreviousTotal = total
+	}
+	var hasBaseline bool
+	comparison.PercentChange, hasBaseline = percentChange(comparison.CurrentTotal, comparison.PreviousTotal)
+	comparison.NoBaseline = !hasBaseline
+
+	for _, diff := range diffs {
+		diff.Change = diff.CurrentTotal - diff.PreviousTotal
+		diff.PercentChange, hasBaseline = percentChange(diff.CurrentTotal, diff.PreviousTotal)
+		diff.NoBaseline = !hasBaseline
+		comparison.Apps = append(comparison.Apps, *diff)
+	}
+	busiest := func(d AppUsageDiff) int64 {
+		if d.CurrentTotal > d.PreviousTotal {
+			return d.CurrentTotal
+		}
+		return d.PreviousTotal
+	}
+	sort.Slice(comparison.Apps, func(i, j int) bool {
+		return busiest(comparison.Apps[i]) > busiest(comparison.Apps[j])
+	})
+	if len(comparison.Apps) > comparisonTopApps {
+		comparison.Apps = comparison.Apps[:comparisonTopApps]
+	}
+	return comparison
+}
+
+// LockedVsActiveUsage splits usage over a period by whether the screen was locked
+type LockedVsActiveUsage struct {
+	Days            int              `json:"days"`            // Period length; 0 covers all stored records
+	LockedUpload    int64            `json:"lockedUpload"`    // Bytes while the screen was locked
+	LockedDownload  int64            `json:"lockedDownload"`  // Bytes while the screen was locked
+	ActiveUpload    int64            `json:"activeUpload"`    // Bytes while the screen was unlocked
+	ActiveDownload  int64            `json:"activeDownload"`  // Bytes while the screen was unlocked
+	UnknownUpload   int64            `json:"unknownUpload"`   // Bytes before lock tracking or while the state couldn't be read
+	UnknownDownload int64            `json:"unknownDownload"` // Bytes before lock tracking or while the state couldn't be read
+	Apps            []AppScreenUsage `json:"apps"`            // Busiest apps while locked first
+}
+
+// AppScreenUsage is one app's usage split by screen lock state
+type AppScreenUsage struct {
+	AppName         string `json:"appName"`
+	DisplayLabel    string `json:"displayLabel"`
+	LockedUpload    int64  `json:"lockedUpload"`
+	LockedDownload  int64  `json:"lockedDownload"`
+	ActiveUpload    int64  `json:"activeUpload"`
+	ActiveDownload  int64  `json:"activeDownload"`
+	UnknownUpload   int64  `json:"unknownUpload"`
+	UnknownDownload int64  `json:"unknownDownload"`
+}
+
+// GetLockedVsActiveUsage returns how much each app used over the last N days while the screen was
+// locked versus in use, revealing apps that sync in the background. 0 days covers all records.
+func (a *App) GetLockedVsActiveUsage(days int) LockedVsActiveUsage {
+	if a.db == nil {
+		return LockedVsActiveUsage{}
+	}
+	usage := LockedVsActiveUsage{Days: days, Apps: []AppScreenUsage{}}
+	if days < 0 {
+		log.Printf("Invalid number of days: %d", days)
+		return usage
+	}
+
+	now := time.Now()
+	var start int64
+	if days > 0 {
+		start = now.AddDate(0, 0, -days).Unix()
+	}
+	stats, err := a.db.GetUsageByScreenState(start, now.Unix())
+	if err != nil {
+		a.queryFailed("get locked vs active usage", err)
+		return usage
+	}
+	displays := a.appDisplays()
+
+	a.configMux.RLock()
+	defer a.configMux.RUnlock()
+	for _, stat := range stats {
+		if a.config.IsAppExcluded(stat.AppName) {
+			continue
+		}
+		usage.LockedUpload += stat.LockedUpload
+		usage.LockedDownload += stat.LockedDownload
+		usage.ActiveUpload += stat.ActiveUpload
+		usage.ActiveDownload += stat.ActiveDownload
+		usage.UnknownUpload += stat.UnknownUpload
+		usage.UnknownDownload += stat.UnknownDownload
+		usage.Apps = append(usage.Apps, AppScreenUsage{
+			AppName:         stat.AppName,
+			DisplayLabel:    resolveDisplay(displays, stat.AppName).Label,
+			LockedUpload:    stat.LockedUpload,
+			LockedDownload:  stat.LockedDownload,
+			ActiveUpload:    stat.ActiveUpload,
+			ActiveDownload:  stat.ActiveDownload,
+			UnknownUpload:   stat.UnknownUpload,
+			UnknownDownload: stat.UnknownDownload,
+		})
+	}
+	return usage
+}
+
+// maxHistoricalDays bounds how many days GetHistoricalData returns (about ten years)
+const maxHistoricalDays = 3650
+
+// GetHistoricalData returns one daily summary per day for the last N days, newest first.
+// Days without data are zero-filled; N is capped at maxHistoricalDays and must not be negative.
+func (a *App) GetHistoricalData(days int) ([]database.DailySummary, error) {
+	if a.db == nil {
+		return nil, errNoDatabase
+	}
+	if days < 0 {
+		return nil, fmt.Errorf("invalid number of days: %d", days)
+	}
+	if days > maxHistoricalDays {
+		days = maxHistoricalDays
+	}
+	summaries, err := a.db.GetRecentSummaries(days)
+	if err != nil {
+		a.queryFailed("get historical data", err)
+		return []database.DailySummary{}, fmt.Errorf("failed to get historical data: %w", err)
+	}
+	return summaries, nil
+}
+
+// UsageInsights describes when the network was busiest
+type UsageInsights struct {
+	BusiestHour database.HourStat     `json:"busiestHour"`
+	BusiestDay  database.DailySummary `json:"busiestDay"`
+}
+
+// GetUsageInsights returns the busiest hour of day and busiest day over the last 30 days
+func (a *App) GetUsageInsights() UsageInsights {
+	if a.db == nil {
+		return UsageInsights{}
+	}
+	const insightDays = 30
+	hour, day, err := a.db.GetBusiestPeriods(insightDays)
+	if err != nil {
+		a.queryFailed("get usage insights", err)
+		return UsageInsights{}
+	}
+	return UsageInsights{
+		BusiestHour: hour,
+		BusiestDay:  day,
+	}
+}
+
+// Projection estimates whether usage in the current billing cycle will exceed a data cap
+type Projection struct {
+	UsedBytes      int64   `json:"usedBytes"`      // Usage so far this cycle
+	ProjectedBytes int64   `json:"projectedBytes"` // Estimated usage for the full cycle
+	PercentOfCap   float64 `json:"percentOfCap"`   // Projected usage as a percentage of the cap
+	DaysUntilCap   float64 `json:"daysUntilCap"`   // Days until the cap is hit at the current rate, -1 if never
+	DaysElapsed    float64 `json:"daysElapsed"`
+	DaysInCycle    int     `json:"daysInCycle"`
+	WillExceed     bool    `json:"willExceed"`
+}
+
+// ProjectMonthlyUsage extrapolates this month's usage to the end of the month and compares it to capBytes.
+// The billing cycle is the calendar month.
+func (a *App) ProjectMonthlyUsage(capBytes int64) Projection {
+	if a.db == nil {
+		return Projection{}
+	}
+	now := time.Now()
+	cycleStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	cycleEnd := cycleStart.AddDate(0, 1, 0)
+	daysInCycle := int(cycleEnd.Sub(cycleStart).Hours()/24 + 0.5)
+
+	totals, err := a.db.GetSummaryTotals(cycleStart.Format("2006-01-02"), now.Format("2006-01-02"))
+	if err != nil {
+		a.queryFailed("get billing cycle usage", err)
+		return Projection{DaysInCycle: daysInCycle, DaysUntilCap: -1}
+	}
+	used := totals["upload"] + totals["download"]
+
+	// Treat the first day as a full day so a few minutes of data doesn't explode the average
+	elapsed := now.Sub(cycleStart).Hours() / 24
+	if elapsed < 1 {
+		elapsed = 1
+	}
+	dailyAverage := float64(used) / elapsed
+	projected := int64(dailyAverage * float64(daysInCycle))
+
+	projection := Projection{
+		UsedBytes:      used,
+		ProjectedBytes: projected,
+		DaysElapsed:    elapsed,
+		DaysInCycle:    daysInCycle,
+		DaysUntilCap:   -1,
+	}
+
+	if capBytes > 0 {
+		projection.PercentOfCap = float64(projected) / float64(capBytes) * 100
+		projection.WillExceed = projected > capBytes
+		if used >= capBytes {
+			projection.DaysUntilCap = 0
+		} else if dailyAverage > 0 {
+			projection.DaysUntilCap = float64(capBytes-used) / dailyAverage
+		}
+	}
+
+	return projection
+}
+
+// AppProjection estimates one app's usage for the full calendar month
+type AppProjection struct {
+	AppName        string  `json:"appName"`
+	UsedBytes      int64   `json:"usedBytes"`      // Usage so far this month
+	ProjectedBytes int64   `json:"projectedBytes"` // Estimated usage for the full month
+	DailyAverage   int64   `json:"dailyAverage"`   // Bytes per day the projection assumes
+	HistoryDays    float64 `json:"historyDays"`    // Days of data behind the daily average
+	LowConfidence  bool    `json:"lowConfidence"`  // Too little history for a reliable projection
+}
+
+const (
+	appProjectionLimit       = 10 // Top apps projected
+	minProjectionHistoryDays = 3  // Less history than this marks a projection low-confidence
+)
+
+// GetAppProjections extrapolates the top apps' month-to-date usage to the end of the month.
+// The daily average comes from the last days days (0 uses the whole month so far); apps seen only
+// recently are averaged over the time they have existed and flagged as low-confidence.
+func (a *App) GetAppProjections(days int) []AppProjection {
+	if a.db == nil {
+		return nil
+	}
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	remaining := monthEnd.Sub(now).Hours() / 24
+
+	used, err := a.db.GetAppUsageStats(monthStart.Unix(), now.Unix())
+	if err != nil {
+		a.queryFailed("get month-to-date app usage", err)
+		return []AppProjection{}
+	}
+	used = a.filterExcludedApps(used)
+	if len(used) > appProjectionLimit {
+		used = used[:appProjectionLimit]
+	}
+
+	windowStart := monthStart
+	if days > 0 {
+		windowStart = now.AddDate(0, 0, -days)
+	}
+	windowUsage := make(map[string]int64)
+	if windowStart.Equal(monthStart) {
+		for _, stat := range used {
+			windowUsage[stat.AppName] = stat.TotalUpload + stat.TotalDownload
+		}
+	} else {
+		recent, err := a.db.GetAppUsageStats(windowStart.Unix(), now.Unix())
+		if err != nil {
+			a.queryFailed("get recent app usage", err)
+			return []AppProjection{}
+		}
+		for _, stat := range recent {
+			windowUsage[stat.AppName] = stat.TotalUpload + stat.TotalDownload
+		}
+	}
+
+	projections := make([]AppProjection, 0, len(used))
+	for _, stat := range used {
+		// Average over the part of the window the app has existed for
+		start := windowStart
+		if meta, err := a.db.GetAppMetadata(stat.AppName); err == nil && meta.FirstSeen > start.Unix() {
+			start = time.Unix(meta.FirstSeen, 0)
+		}
+		history := now.Sub(start).Hours() / 24
+		// Treat less than a day as a full day so a few minutes of data doesn't explode the average
+		divisor := history
+		if divisor < 1 {
+			divisor = 1
+		}
+		dailyAverage := float64(windowUsage[stat.AppName]) / divisor
+		monthUsed := stat.TotalUpload + stat.TotalDownload
+
+		projections = append(projections, AppProjection{
+			AppName:        stat.AppName,
+			UsedBytes:      monthUsed,
+			ProjectedBytes: monthUsed + int64(dailyAverage*remaining),
+			DailyAverage:   int64(dailyAverage),
+			HistoryDays:    history,
+			LowConfidence:  history < minProjectionHistoryDays,
+		})
+	}
+
+	sort.Slice(projections, func(i, j int) bool {
+		return projections[i].ProjectedBytes > projections[j].ProjectedBytes
+	})
+	return projections
+}
+
+// GetSettings returns current settings
+func (a *App) GetSettings() utils.Config {
+	a.configMux.RLock()
+	defer a.configMux.RUnlock()
+	return *a.config
+}
+
+// GetConfigSchema describes each setting (type, label, bounds, allowed values) so the settings
+// form can be rendered and validated from the same rules UpdateSettings enforces
+func (a *App) GetConfigSchema() []utils.SettingSchema {
+	return utils.ConfigSchema()
+}
+
+// GetMonitorConfig returns the effective collection and write cadence
+func (a *App) GetMonitorConfig() utils.MonitorConfig {
+	a.configMux.RLock()
+	defer a.configMux.RUnlock()
+	return a.config.Monitor
+}
+
+// SetMonitorConfig validates, saves and applies a new collection and write cadence.
+// The monitor's loops restart when an interval changes.
+func (a *App) SetMonitorConfig(cfg utils.MonitorConfig) error {
+	settings := a.GetSettings()
+	settings.Monitor = cfg
+	return a.UpdateSettings(settings)
+}
+
+// monitorTiming converts the stored monitor cadence to the monitor's representation
+func monitorTiming(c utils.MonitorConfig) monitor.Timing {
+	return monitor.Timing{
+		UpdateInterval:   time.Duration(c.UpdateIntervalMs) * time.Millisecond,
+		BatchInterval:    time.Duration(c.BatchIntervalSeconds) * time.Second,
+		CleanupThreshold: time.Duration(c.CleanupThresholdMs) * time.Millisecond,
+		InactiveGrace:    time.Duration(c.InactiveGraceMs) * time.Millisecond,
+		BatchSizeLimit:   c.BatchSizeThreshold,
+	}
+}
+
+// UpdateSettings updates application settings
+func (a *App) UpdateSettings(settings utils.Config) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	// Validate settings
+	if err := utils.ValidateTheme(settings.Theme); err != nil {
+		return err
+	}
+	if settings.RetentionMode() == utils.RetentionModeInvalid {
+		return fmt.Errorf("invalid data retention: %d", settings.DataRetention)
+	}
+	if settings.TrayFormat == "" {
+		settings.TrayFormat = utils.DefaultTrayFormat
+	} else if err := utils.ValidateTrayFormat(settings.TrayFormat); err != nil {
+		log.Printf("Invalid tray format %q (%v), using default", settings.TrayFormat, err)
+		settings.TrayFormat = utils.DefaultTrayFormat
+	}
+	if err := utils.ValidateDataUnit(settings.TrayUnit); err != nil {
+		return fmt.Errorf("invalid tray unit: %w", err)
+	}
+	if err := utils.ValidateDataUnit(settings.DisplayUnit); err != nil {
+		return fmt.Errorf("invalid display unit: %w", err)
+	}
+	if settings.StartupDelaySeconds < 0 || settings.StartupDelaySeconds > utils.MaxStartupDelaySeconds {
+		return fmt.Errorf("invalid startup delay: %d seconds (expected 0-%d)",
+			settings.StartupDelaySeconds, utils.MaxStartupDelaySeconds)
+	}
+	if settings.SummaryRetentionDays < 0 {
+		return fmt.Errorf("invalid summary retention: %d days (expected 0 for forever, or more)",
+			settings.SummaryRetentionDays)
+	}
+	if settings.ConnectionRetentionDays < 0 {
+		return fmt.Errorf("invalid destination retention: %d days (expected 0 to follow data retention, or more)",
+			settings.ConnectionRetentionDays)
+	}
+	if err := utils.ValidateMonitorConfig(settings.Monitor); err != nil {
+		return fmt.Errorf("invalid monitor config: %w", err)
+	}
+	if err := utils.ValidateDestinationRules(settings.DestinationRules); err != nil {
+		return err
+	}
+	if err := utils.ValidateSpeedTestURL(settings.SpeedTestURL); err != nil {
+		return err
+	}
+	if err := utils.ValidateUsageTierThresholds(settings.UsageTierThresholds); err != nil {
+		return fmt.Errorf("invalid usage tiers: %w", err)
+	}
+	if err := utils.ValidateQuietHours(settings.QuietHoursStart, settings.QuietHoursEnd); err != nil {
+		return err
+	}
+	if settings.MaxCycleBytes < 0 {
+		return fmt.Errorf("invalid cycle cap: %d bytes (expected 0 to disable, or more)", settings.MaxCycleBytes)
+	}
+	if settings.AutoBackupDays < 0 {
+		return fmt.Errorf("invalid backup interval: %d days (expected 0 to disable, or more)", settings.AutoBackupDays)
+	}
+	if settings.MinProcessLifetimeSeconds < 0 || settings.MinProcessLifetimeSeconds > utils.MaxProcessLifetimeSeconds {
+		return fmt.Errorf("invalid minimum process lifetime: %d seconds (expected 0-%d)",
+			settings.MinProcessLifetimeSeconds, utils.MaxProcessLifetimeSeconds)
+	}
+	if settings.StorageGranularitySeconds < 0 || settings.StorageGranularitySeconds > utils.MaxStorageGranularitySeconds {
+		return fmt.Errorf("invalid storage granularity: %d seconds (expected 0-%d)",
+			settings.StorageGranularitySeconds, utils.MaxStorageGranularitySeconds)
+	}
+	if settings.BackupKeep < utils.MinBackupKeep {
+		return fmt.Errorf("invalid backup count: %d (expected at least %d)", settings.BackupKeep, utils.MinBackupKeep)
+	}
+	if settings.OversizedCycleAction == "" {
+		settings.OversizedCycleAction = utils.CycleActionDiscard
+	} else if err := utils.ValidateCycleAction(settings.OversizedCycleAction); err != nil {
+		return err
+	}
+
+	a.configMux.Lock()
+	defer a.configMux.Unlock()
+
+	// Handle autostart change; a new delay re-registers the autostart command
+	delayChanged := settings.AutoStart && settings.StartupDelaySeconds != a.config.StartupDelaySeconds
+	if settings.AutoStart != a.config.AutoStart || delayChanged {
+		execPath, err := utils.GetExecutablePath()
+		if err != nil {
+			log.Printf("Failed to get executable path for autostart: %v", err)
+			return fmt.Errorf("failed to get executable path: %w", err)
+		}
+
+		if settings.AutoStart {
+			if err := autostart.Enable(execPath, settings.StartupDelaySeconds); err != nil {
+				log.Printf("Failed to enable autostart: %v", err)
+				return fmt.Errorf("failed to enable autostart: %w", err)
+			}
+			log.Printf("Autostart enabled with path: %s", execPath)
+		} else {
+			if err := autostart.Disable(); err != nil {
+				log.Printf("Failed to disable autostart: %v", err)
+				return fmt.Errorf("failed to disable autostart: %w", err)
+			}
+			log.Printf("Autostart disabled")
+		}
+	}
+
+	// Handle data retention change - toggle save enabled for monitor, keeping live-only mode
+	if settings.DataRetention != a.config.DataRetention && a.monitor != nil {
+		a.applySaveEnabled(settings.DataRetention)
+		log.Printf("Data retention changed from %d to %d", a.config.DataRetention, settings.DataRetention)
+
+		// Apply new retention immediately (run cleanup now)
+		go func(retention, summaryDays int) {
+			log.Printf("Applying retention change immediately: %d", retention)
+			a.db.DeleteExpiredRecords()
+			switch utils.ClassifyRetention(retention) {
+			case utils.RetentionModeTest:
+				cutoff := time.Now().Add(-1 * time.Minute).Unix()
+				a.pruneOldRecords(cutoff, summaryDays)
+				log.Printf("Cleaned records older than 1 minute")
+			case utils.RetentionModeDays:
+				cutoff := time.Now().AddDate(0, 0, -retention).Unix()
+				a.pruneOldRecords(cutoff, summaryDays)
+				log.Printf("Cleaned records older than %d days", retention)
+			}
+		}(settings.DataRetention, settings.SummaryRetentionDays)
+	}
+
+	// Save settings
+	if err := settings.Save(a.db); err != nil {
+		return err
+	}
+
+	utils.SetSIUnits(settings.UseSIUnits)
+	if a.monitor != nil {
+		a.monitor.SetExcludedApps(settings.ExcludedApps)
+		a.monitor.SetStoreSystemTotals(settings.StoreSystemTotals)
+		a.monitor.SetProxyApps(settings.ProxyApps)
+		a.monitor.SetCycleCap(settings.MaxCycleBytes, settings.OversizedCycleAction == utils.CycleActionClamp)
+		a.monitor.SetMinProcessLifetime(time.Duration(settings.MinProcessLifetimeSeconds) * time.Second)
+		a.monitor.SetStorageGranularity(time.Duration(settings.StorageGranularitySeconds) * time.Second)
+		a.monitor.SetRollupByProcessTree(settings.RollupByProcessTree)
+		a.monitor.SetTrackLANTraffic(settings.TrackLANTraffic)
+		a.monitor.SetDestinationRules(settings.DestinationRules)
+		if settings.CrashJournal && !a.config.CrashJournal {
+			a.enableJournal()
+		} else if !settings.CrashJournal && a.config.CrashJournal {
+			a.monitor.DisableJournal()
+		}
+		if settings.Monitor != a.config.Monitor {
+			a.monitor.SetTiming(monitorTiming(settings.Monitor))
+			a.monitor.SetWarmupSamples(settings.Monitor.WarmupSamples)
+			a.monitor.SetMaxTrackedApps(settings.Monitor.MaxTrackedApps)
+		}
+	}
+	a.config = &settings
+	return nil
+}
+
+// ExportSettings returns all settings as JSON, for backup or moving to another machine
+func (a *App) ExportSettings() (string, error) {
+	settings := a.GetSettings()
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode settings: %w", err)
+	}
+	return string(data), nil
+}
+
+// ImportSettings applies settings previously produced by ExportSettings.
+// Unknown fields and invalid values are rejected; settings missing from the JSON keep their current values.
+func (a *App) ImportSettings(settingsJSON string) error {
+	settings := a.GetSettings()
+	// Decoding reuses slice storage, so detach it from the live config first
+	settings.ExcludedApps = append([]string{}, settings.ExcludedApps...)
+	settings.ActiveWhenRunning = append([]string{}, settings.ActiveWhenRunning...)
+	settings.ProxyApps = append([]string{}, settings.ProxyApps...)
+	settings.DestinationRules = append([]utils.DestinationRule{}, settings.DestinationRules...)
+
+	decoder := json.NewDecoder(strings.NewReader(settingsJSON))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		return fmt.Errorf("invalid settings JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid settings JSON: unexpected data after settings object")
+	}
+	if settings.TrayFormat != "" {
+		if err := utils.ValidateTrayFormat(settings.TrayFormat); err != nil {
+			return fmt.Errorf("invalid tray format: %w", err)
+		}
+	}
+
+	// UpdateSettings validates the rest and applies side effects (autostart, saving)
+	return a.UpdateSettings(settings)
+}
+
+// GetExcludedApps returns the apps that are never tracked
+func (a *App) GetExcludedApps() []string {
+	a.configMux.RLock()
+	defer a.configMux.RUnlock()
+	return append([]string{}, a.config.ExcludedApps...)
+}
+
+// ExcludeApp stops tracking an app entirely
+func (a *App) ExcludeApp(appName string) error {
+	if utils.CanonicalAppName(appName) == "" {
+		return fmt.Errorf("app name is required")
+	}
+
+	a.configMux.Lock()
+	defer a.configMux.Unlock()
+
+	if a.config.IsAppExcluded(appName) {
+		return nil
+	}
+
+	updated := *a.config
+	updated.ExcludedApps = append(append([]string{}, a.config.ExcludedApps...), appName)
+	return a.applyExcludedApps(&updated)
+}
+
+// UnexcludeApp resumes tracking a previously excluded app
+func (a *App) UnexcludeApp(appName string) error {
+	a.configMux.Lock()
+	defer a.configMux.Unlock()
+
+	canonical := utils.CanonicalAppName(appName)
+	updated := *a.config
+	updated.ExcludedApps = []string{}
+	for _, excluded := range a.config.ExcludedApps {
+		if utils.CanonicalAppName(excluded) != canonical {
+			updated.ExcludedApps = append(updated.ExcludedApps, excluded)
+		}
+	}
+	return a.applyExcludedApps(&updated)
+}
+
+// PauseTrackingForApp stops storing an app's usage for the given number of minutes, then resumes automatically.
+// The app keeps appearing in live stats meanwhile.
+func (a *App) PauseTrackingForApp(appName string, minutes int) error {
+	if utils.CanonicalAppName(appName) == "" {
+		return fmt.Errorf("app name is required")
+	}
+	if minutes <= 0 {
+		return fmt.Errorf("invalid pause duration: %d minutes", minutes)
+	}
+	if a.monitor == nil {
+		return fmt.Errorf("monitor not running")
+	}
+	a.monitor.PauseAppTracking(appName, time.Duration(minutes)*time.Minute)
+	return nil
+}
+
+// ResumeTrackingForApp ends a temporary tracking pause early
+func (a *App) ResumeTrackingForApp(appName string) {
+	if a.monitor != nil {
+		a.monitor.ResumeAppTracking(appName)
+	}
+}
+
+// GetPausedApps returns apps whose tracking is temporarily paused and when each pause ends (Unix timestamp)
+func (a *App) GetPausedApps() map[string]int64 {
+	if a.monitor == nil {
+		return map[string]int64{}
+	}
+	return a.monitor.GetPausedApps()
+}
+
+// applyExcludedApps saves a config with a new exclusion list and pushes it to the monitor.
+// Caller must hold configMux.
+func (a *App) applyExcludedApps(updated *utils.Config) error {
+	if err := updated.Save(a.db); err != nil {
+		return err
+	}
+	if a.monitor != nil {
+		a.monitor.SetExcludedApps(updated.ExcludedApps)
+	}
+	a.config = updated
+	return nil
+}
+
+// filterExcludedApps removes excluded apps from aggregated usage statistics
+func (a *App) filterExcludedApps(stats []database.AppUsageStat) []database.AppUsageStat {
+	a.configMux.RLock()
+	defer a.configMux.RUnlock()
+
+	filtered := make([]database.AppUsageStat, 0, len(stats))
+	for _, stat := range stats {
+		if !a.config.IsAppExcluded(stat.AppName) {
+			filtered = append(filtered, stat)
+		}
+	}
+	return filtered
+}
+
+// MergeApps folds all stored usage of one app name into another, e.g. "App.exe" into "app.exe"
+// after a rename. The merged name disappears from history.
+func (a *App) MergeApps(from, into string) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	from = strings.TrimSpace(from)
+	into = strings.TrimSpace(into)
+	if err := a.db.MergeApps(from, into); err != nil {
+		return fmt.Errorf("failed to merge %s into %s: %w", from, into, err)
+	}
+	log.Printf("Merged app %s into %s", from, into)
+	return nil
+}
+
+// ResetAppStats clears an app's stored usage so its counters start from zero, keeping the app
+// itself and everyone else's history. It returns the number of records removed.
+func (a *App) ResetAppStats(appName string) (int64, error) {
+	if a.db == nil {
+		return 0, errNoDatabase
+	}
+	appName = strings.TrimSpace(appName)
+	deleted, err := a.db.ResetAppStats(appName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset %s: %w", appName, err)
+	}
+	log.Printf("Reset stats of %s (%d records)", appName, deleted)
+	return deleted, nil
+}
+
+// AppDisplay is how an app is presented in charts and lists
+type AppDisplay struct {
+	AppName string `json:"appName"`
+	Color   string `json:"color"`  // Hex color, derived from the app name unless set
+	Label   string `json:"label"`  // Display name, the app name unless set
+	Custom  bool   `json:"custom"` // A color or label was set explicitly
+}
+
+const maxDisplayLabelLength = 64
+
+// SetAppDisplay assigns an app's chart color and display label. Empty values restore the defaults.
+func (a *App) SetAppDisplay(appName, color, label string) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	name := utils.CanonicalAppName(appName)
+	if name == "" {
+		return fmt.Errorf("app name is required")
+	}
+	if color != "" {
+		if err := utils.ValidateColor(color); err != nil {
+			return err
+		}
+	}
+	label = strings.TrimSpace(label)
+	if len(label) > maxDisplayLabelLength {
+		return fmt.Errorf("label too long: %d characters (max %d)", len(label), maxDisplayLabelLength)
+	}
+	if err := a.db.SetAppDisplay(database.AppDisplay{AppName: name, Color: strings.ToLower(color), DisplayLabel: label}); err != nil {
+		return fmt.Errorf("failed to save app display: %w", err)
+	}
+	a.invalidateDisplays()
+	return nil
+}
+
+// GetAppDisplay returns an app's chart color and display label, with defaults filled in
+func (a *App) GetAppDisplay(appName string) AppDisplay {
+	if a.db == nil {
+		return resolveDisplay(nil, appName)
+	}
+	displays := a.appDisplays()
+	return resolveDisplay(displays, appName)
+}
+
+// appDisplays returns the stored display settings, loading them on first use. A failed load
+// isn't cached, so the next call tries again.
+func (a *App) appDisplays() map[string]database.AppDisplay {
+	if a.db == nil {
+		return nil
+	}
+	a.displaysMux.Lock()
+	defer a.displaysMux.Unlock()
+
+	if a.displays == nil {
+		displays, err := a.db.GetAppDisplays()
+		if err != nil {
+			log.Printf("Failed to get app display settings: %v", err)
+			return nil
+		}
+		a.displays = displays
+	}
+	return a.displays
+}
+
+// invalidateDisplays drops the cached display settings after they changed in the database
+func (a *App) invalidateDisplays() {
+	a.displaysMux.Lock()
+	a.displays = nil
+	a.displaysMux.Unlock()
+}
+
+// resolveDisplay merges stored display settings for an app with the derived defaults
+func resolveDisplay(displays map[string]database.AppDisplay, appName string) AppDisplay {
+	display := AppDisplay{
+		AppName: appName,
+		Color:   utils.DefaultAppColor(appName),
+		Label:   appName,
+	}
+	if stored, ok := displays[utils.CanonicalAppName(appName)]; ok {
+		if stored.Color != "" {
+			display.Color = stored.Color
+		}
+		if stored.DisplayLabel != "" {
+			display.Label = stored.DisplayLabel
+		}
+		display.Custom = true
+	}
+	return display
+}
+
+// withDisplay fills in the color and label of aggregated usage statistics
+func (a *App) withDisplay(stats []database.AppUsageStat) []database.AppUsageStat {
+	displays := a.appDisplays()
+	for i := range stats {
+		display := resolveDisplay(displays, stats[i].AppName)
+		stats[i].Color = display.Color
+		stats[i].DisplayLabel = display.Label
+	}
+	return stats
+}
+
+// withTiers classifies each app's total traffic against the configured usage tiers, so every view
+// colors heavy users the same way
+func (a *App) withTiers(stats []database.AppUsageStat) []database.AppUsageStat {
+	a.configMux.RLock()
+	thresholds := a.config.UsageTierThresholds
+	a.configMux.RUnlock()
+
+	for i := range stats {
+		stats[i].Tier = thresholds.Tier(stats[i].TotalUpload + stats[i].TotalDownload)
+	}
+	return stats
+}
+
+// withLiveDisplay fills in the color and label of live statistics
+func (a *App) withLiveDisplay(stats map[string]*monitor.NetworkStat) map[string]*monitor.NetworkStat {
+	displays := a.appDisplays()
+	for _, stat := range stats {
+		display := resolveDisplay(displays, stat.AppName)
+		stat.Color = display.Color
+		stat.DisplayLabel = display.Label
+	}
+	return stats
+}
+
+// pausedSetting is the settings key remembering that the user paused monitoring, so a pause
+// survives restarts. Pauses made by the process watcher are not remembered.
+const pausedSetting = "paused"
+
+// PauseMonitoring pauses the network monitoring until ResumeMonitoring, even across restarts.
+// It takes over from a wait for watched apps, so their starting doesn't resume monitoring.
+func (a *App) PauseMonitoring() {
+	a.savePaused(true)
+	a.setPausedByUser(true)
+	a.pauseMonitoring()
+}
+
+// ResumeMonitoring resumes the network monitoring
+func (a *App) ResumeMonitoring() {
+	a.savePaused(false)
+	a.setPausedByUser(false)
+	a.resumeMonitoring()
+}
+
+// setPausedByUser records whether the user paused monitoring; a user pause ends any wait for
+// watched apps
+func (a *App) setPausedByUser(paused bool) {
+	a.waitingMux.Lock()
+	a.pausedByUser = paused
+	if paused {
+		a.waitingFor = nil
+	}
+	a.waitingMux.Unlock()
+}
+
+// pauseMonitoring pauses the monitor and updates the tray without remembering the pause
+func (a *App) pauseMonitoring() {
+	if a.monitor != nil {
+		a.monitor.Pause()
+	}
+	if a.tray != nil {
+		a.tray.UpdatePauseState(true)
+	}
+}
+
+// resumeMonitoring resumes the monitor and updates the tray without remembering the change
+func (a *App) resumeMonitoring() {
+	a.waitingMux.Lock()
+	a.waitingFor = nil
+	a.waitingMux.Unlock()
+
+	if a.monitor != nil {
+		a.monitor.Resume()
+	}
+	if a.tray != nil {
+		a.tray.UpdatePauseState(false)
+	}
+}
+
+// savePaused stores whether monitoring should start paused next launch
+func (a *App) savePaused(paused bool) {
+	if a.db == nil {
+		return
+	}
+	if err := a.db.SetSetting(pausedSetting, strconv.FormatBool(paused)); err != nil {
+		log.Printf("Failed to save pause state: %v", err)
+	}
+}
+
+// GetWaitingFor returns the watched apps monitoring is waiting for, or an empty list when not waiting
+func (a *App) GetWaitingFor() []string {
+	a.waitingMux.Lock()
+	defer a.waitingMux.Unlock()
+	return append([]string{}, a.waitingFor...)
+}
+
+// BeginTemporarySession records new usage as temporary data that expires after ttlMinutes
+func (a *App) BeginTemporarySession(ttlMinutes int) error {
+	if ttlMinutes <= 0 {
+		return fmt.Errorf("invalid session TTL: %d minutes", ttlMinutes)
+	}
+	if a.monitor == nil {
+		return fmt.Errorf("monitor not running")
+	}
+	a.monitor.BeginTemporarySession(time.Duration(ttlMinutes) * time.Minute)
+	return nil
+}
+
+// EndTemporarySession returns to recording permanent usage data
+func (a *App) EndTemporarySession() {
+	if a.monitor != nil {
+		a.monitor.EndTemporarySession()
+	}
+}
+
+// StartLabeledSession tags usage collected from now on with label (e.g. "video call"), so it can be
+// totaled later with GetUsageByLabel. Starting a session while one is open replaces its label.
+func (a *App) StartLabeledSession(label string) error {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return fmt.Errorf("session label is required")
+	}
+	if a.monitor == nil {
+		return fmt.Errorf("monitor not running")
+	}
+	a.monitor.StartLabeledSession(label)
+	return nil
+}
+
+// EndLabeledSession stops tagging usage with the open session's label
+func (a *App) EndLabeledSession() {
+	if a.monitor != nil {
+		a.monitor.EndLabeledSession()
+	}
+}
+
+// GetActiveSessionLabel returns the label of the open labeled session, or "" if none is open
+func (a *App) GetActiveSessionLabel() string {
+	if a.monitor == nil {
+		return ""
+	}
+	return a.monitor.GetSessionLabel()
+}
+
+// GetUsageByLabel returns per-app usage recorded during sessions with the given label
+func (a *App) GetUsageByLabel(label string) []database.AppUsageStat {
+	if a.db == nil {
+		return nil
+	}
+	stats, err := a.db.GetUsageByLabel(strings.TrimSpace(label))
+	if err != nil {
+		a.queryFailed(fmt.Sprintf("get usage for session %q", label), err)
+		return []database.AppUsageStat{}
+	}
+	return a.withDisplay(a.filterExcludedApps(stats))
+}
+
+// StartTripMeter starts measuring usage from the current monitor totals
+func (a *App) StartTripMeter() {
+	if a.monitor == nil {
+		return
+	}
+	upload, download := a.monitor.GetSessionTotals()
+
+	a.tripMeterMux.Lock()
+	defer a.tripMeterMux.Unlock()
+	a.tripMeter = &tripMarker{
+		startUpload:   upload,
+		startDownload: download,
+		startTime:     time.Now(),
+	}
+}
+
+// GetTripMeter returns usage measured since the trip meter was started
+func (a *App) GetTripMeter() TripMeter {
+	a.tripMeterMux.Lock()
+	marker := a.tripMeter
+	a.tripMeterMux.Unlock()
+
+	if marker == nil || a.monitor == nil {
+		return TripMeter{}
+	}
+
+	upload, download := a.monitor.GetSessionTotals()
+	return TripMeter{
+		Active:         true,
+		StartedAt:      marker.startTime.Unix(),
+		ElapsedSeconds: int64(time.Since(marker.startTime).Seconds()),
+		Upload:         upload - marker.startUpload,
+		Download:       download - marker.startDownload,
+	}
+}
+
+// ResetTripMeter stops the trip meter and discards its marker
+func (a *App) ResetTripMeter() {
+	a.tripMeterMux.Lock()
+	defer a.tripMeterMux.Unlock()
+	a.tripMeter = nil
+}
+
+// SetLiveOnlyMode keeps the live view updating while discarding data instead of saving it
+func (a *App) SetLiveOnlyMode(enabled bool) error {
+	if a.monitor == nil {
+		return fmt.Errorf("monitor not running")
+	}
+
+	a.configMux.RLock()
+	retention := a.config.DataRetention
+	a.configMux.RUnlock()
+
+	if !enabled && utils.ClassifyRetention(retention) == utils.RetentionModeDoNotSave {
+		return fmt.Errorf("saving is disabled by the data retention setting")
+	}
+	a.liveOnly.Store(enabled)
+	a.applySaveEnabled(retention)
+	return nil
+}
+
+// IsLiveOnlyMode reports whether live-only mode is on. Saving can also be off because of the
+// data retention setting, which this doesn't reflect.
+func (a *App) IsLiveOnlyMode() bool {
+	return a.liveOnly.Load()
+}
+
+// applySaveEnabled turns saving on unless live-only mode or the given retention turns it off
+func (a *App) applySaveEnabled(retention int) {
+	save := !a.liveOnly.Load() && utils.ClassifyRetention(retention) != utils.RetentionModeDoNotSave
+	a.monitor.SetSaveEnabled(save)
+}
+
+// ClearOldData manually clears all old data from database
+func (a *App) ClearOldData() error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	// Clear all data
+	if err := a.db.ClearAllData(); err != nil {
+		return err
+	}
+
+	// Reclaim space in the background; a full VACUUM can take a while on a large file
+	go func() {
+		if result, err := a.db.VacuumIfNeeded(); err != nil {
+			log.Printf("Failed to vacuum database: %v", err)
+		} else {
+			log.Printf("Database %s", result.Reason)
+		}
+	}()
+
+	return nil
+}
+
+// GetLastVacuum returns the most recent vacuum decision, for diagnostics
+func (a *App) GetLastVacuum() database.VacuumResult {
+	if a.db == nil {
+		return database.VacuumResult{}
+	}
+	return a.db.LastVacuum()
+}
+
+// ExportDay writes every usage record for a local calendar day ("2006-01-02") to a CSV file,
+// gzip-compressed when path ends in .gz
+func (a *App) ExportDay(date string, path string) error {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD: %w", date, err)
+	}
+	start := day.Unix()
+	end := day.AddDate(0, 0, 1).Unix() - 1
+
+	return a.exportRange(start, end, path)
+}
+
+// ExportAppsCSV writes per-app usage totals over the last N days (0 for all records) to a CSV
+// file, for only the named apps. Names match like exclusions: case-insensitive, ".exe" optional.
+// The file is gzip-compressed when path ends in .gz.
+func (a *App) ExportAppsCSV(appNames []string, days int, path string) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	if days < 0 {
+		return fmt.Errorf("invalid range: %d days", days)
+	}
+	seen := make(map[string]bool, len(appNames))
+	names := make([]string, 0, len(appNames))
+	for _, name := range appNames {
+		canonical := utils.CanonicalAppName(name)
+		if canonical != "" && !seen[canonical] {
+			seen[canonical] = true
+			names = append(names, canonical)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no apps selected to export")
+	}
+
+	now := time.Now()
+	var start int64
+	if days > 0 {
+		start = now.AddDate(0, 0, -days).Unix()
+	}
+	stats, err := a.db.GetAppUsageForApps(names, start, now.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to query usage: %w", err)
+	}
+	stats = a.withDisplay(stats)
+
+	file, err := createExportFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"app", "label", "upload_bytes", "download_bytes", "total_bytes", "last_seen"}); err != nil {
+		return err
+	}
+	for _, stat := range stats {
+		err := writer.Write([]string{
+			stat.AppName,
+			stat.DisplayLabel,
+			strconv.FormatInt(stat.TotalUpload, 10),
+			strconv.FormatInt(stat.TotalDownload, 10),
+			strconv.FormatInt(stat.TotalUpload+stat.TotalDownload, 10),
+			time.Unix(stat.LastSeen, 0).Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return file.Close()
+}
+
+// exportRange writes usage records between two timestamps (inclusive) to a CSV file
+func (a *App) exportRange(startTime, endTime int64, path string) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	file, err := createExportFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"timestamp", "app", "protocol", "process_id", "upload_bytes", "download_bytes"}); err != nil {
+		return err
+	}
+	// Stream the records so large ranges export in constant memory
+	err = a.db.IterateUsageRecords(startTime, endTime, func(r database.UsageRecord) error {
+		return writer.Write([]string{
+			time.Unix(r.Timestamp, 0).Format(time.RFC3339),
+			r.AppName,
+			r.Protocol,
+			strconv.Itoa(r.ProcessID),
+			strconv.FormatInt(r.UploadBytes, 10),
+			strconv.FormatInt(r.DownloadBytes, 10),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export usage records: %w", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return file.Close()
+}
+
+// exportFile is an export destination that gzip-compresses its output when the path ends in .gz
+type exportFile struct {
+	file *os.File
+	gz   *gzip.Writer
+	out  io.Writer
+}
+
+// createExportFile creates an export destination, choosing compression from the extension
+func createExportFile(path string) (*exportFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	export := &exportFile{file: file, out: file}
+	if strings.EqualFold(filepath.Ext(path), ".gz") {
+		export.gz = gzip.NewWriter(file)
+		export.out = export.gz
+	}
+	return export, nil
+}
+
+// Write writes export data, compressing it if needed
+func (e *exportFile) Write(p []byte) (int, error) {
+	return e.out.Write(p)
+}
+
+// Close flushes compression and closes the file. It is safe to call more than once.
+func (e *exportFile) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	var gzErr error
+	if e.gz != nil {
+		gzErr = e.gz.Close()
+	}
+	err := e.file.Close()
+	e.file = nil
+	if gzErr != nil {
+		return fmt.Errorf("failed to finish compressed export: %w", gzErr)
+	}
+	return err
+}
+
+// clipboardTopApps is how many apps CopyStatsToClipboard lists
+const clipboardTopApps = 10
+
+// CopyStatsToClipboard copies the busiest apps of the last N days (0 for all time) as a
+// tab-separated table, which pastes cleanly into chat, email or a spreadsheet
+func (a *App) CopyStatsToClipboard(days int) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	if days < 0 {
+		return fmt.Errorf("invalid range: %d days", days)
+	}
+	stats, err := a.db.GetAppUsageWithRetention(days)
+	if err != nil {
+		return fmt.Errorf("failed to query usage: %w", err)
+	}
+	stats = a.withDisplay(a.filterExcludedApps(stats))
+	if len(stats) > clipboardTopApps {
+		stats = stats[:clipboardTopApps]
+	}
+
+	a.configMux.RLock()
+	unit := a.config.DisplayUnit
+	a.configMux.RUnlock()
+
+	period := "all time"
+	if days > 0 {
+		period = fmt.Sprintf("last %d days", days)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s top apps (%s)\n", brand.AppName, period)
+	b.WriteString("App\tUpload\tDownload\tTotal\n")
+	for _, s := range stats {
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n", s.DisplayLabel,
+			utils.FormatBytesAs(s.TotalUpload, unit),
+			utils.FormatBytesAs(s.TotalDownload, unit),
+			utils.FormatBytesAs(s.TotalUpload+s.TotalDownload, unit))
+	}
+
+	if err := runtime.ClipboardSetText(a.ctx, b.String()); err != nil {
+		return fmt.Errorf("failed to copy to clipboard: %w", err)
+	}
+	return nil
+}
+
+// GetPaths returns the file locations the app is actually using
+func (a *App) GetPaths() map[string]string {
+	var dbPath string
+	if a.db != nil {
+		dbPath = a.db.Path()
+	} else if path, err := utils.GetDatabasePath(); err == nil {
+		dbPath = path
+	}
+
+	execPath, err := utils.GetExecutablePath()
+	if err != nil {
+		execPath = ""
+	}
+
+	return map[string]string{
+		"database":   dbPath,
+		"dataDir":    filepath.Dir(dbPath),
+		"configFile": a.configFile,
+		"executable": execPath,
+	}
+}
+
+// backupDir returns where database backups are written; "" when none is configured and the
+// database isn't open
+func (a *App) backupDir() string {
+	a.configMux.RLock()
+	dir := a.config.BackupDir
+	a.configMux.RUnlock()
+	if dir == "" && a.db != nil {
+		dir = filepath.Join(filepath.Dir(a.db.Path()), "backups")
+	}
+	return dir
+}
+
+// BackupNow writes a backup of the database to the backup folder
+func (a *App) BackupNow() (database.Backup, error) {
+	if a.db == nil {
+		return database.Backup{}, errNoDatabase
 	}
-	return map[string]interface{}{
-		"upload":   stats["upload"],
-		"download": stats["download"],
+	backup, err := a.db.Backup(a.backupDir())
+	if err != nil {
+		log.Printf("Failed to back up database: %v", err)
+		return database.Backup{}, err
 	}
+	log.Printf("Database backed up to %s", backup.Path)
+	return backup, nil
 }
 
-// GetNetworkUsageStats returns aggregated network usage statistics
-func (a *App) GetNetworkUsageStats() []database.AppUsageStat {
-	days := a.config.DataRetention
-	stats, err := a.db.GetAppUsageWithRetention(days)
-	if err != nil {
-		log.Printf("Failed to get usage stats: %v", err)
-		return []database.AppUsageStat{}
+// ExportDatabaseCopy saves a verified, self-contained copy of the database file to destPath,
+// safe to take while monitoring runs
+func (a *App) ExportDatabaseCopy(destPath string) error {
+	if a.db == nil {
+		return errNoDatabase
 	}
-	return stats
+	destPath = strings.TrimSpace(destPath)
+	if err := a.db.CopyTo(destPath); err != nil {
+		log.Printf("Failed to export database copy: %v", err)
+		return err
+	}
+	log.Printf("Database copied to %s", destPath)
+	return nil
 }
 
-// GetHistoricalData returns daily summaries for the specified number of days
-func (a *App) GetHistoricalData(days int) []database.DailySummary {
-	summaries, err := a.db.GetRecentSummaries(days)
+// GetBackups lists the backups in the backup folder, newest first
+func (a *App) GetBackups() []database.Backup {
+	if a.db == nil {
+		return []database.Backup{}
+	}
+	backups, err := database.ListBackups(a.backupDir())
 	if err != nil {
-		log.Printf("Failed to get historical data: %v", err)
-		return []database.DailySummary{}
+		a.queryFailed("list backups", err)
+		return []database.Backup{}
 	}
-	return summaries
+	return backups
 }
 
-// GetSettings returns current settings
-func (a *App) GetSettings() utils.Config {
-	a.configMux.RLock()
-	defer a.configMux.RUnlock()
-	return *a.config
-}
+// RestoreBackup replaces all data and settings with a backup's. The current data is backed up
+// first, so a restore can itself be undone; restored settings take effect immediately.
+func (a *App) RestoreBackup(path string) error {
+	if a.db == nil {
+		return errNoDatabase
+	}
+	if _, err := a.BackupNow(); err != nil {
+		return fmt.Errorf("failed to back up current data before restoring: %w", err)
+	}
+	if err := a.db.RestoreFrom(path); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+	log.Printf("Restored database from %s", path)
+	a.invalidateDisplays()
 
-// UpdateSettings updates application settings
-func (a *App) UpdateSettings(settings utils.Config) error {
-	// Validate settings
-	if settings.Theme != "auto" && settings.Theme != "light" && settings.Theme != "dark" {
-		return fmt.Errorf("invalid theme: %s", settings.Theme)
+	restored, err := utils.LoadConfig(a.db)
+	if err != nil {
+		return fmt.Errorf("failed to load restored settings: %w", err)
 	}
-	if settings.DataRetention < -2 {
-		return fmt.Errorf("invalid data retention: %d", settings.DataRetention)
+	if err := a.UpdateSettings(*restored); err != nil {
+		return fmt.Errorf("failed to apply restored settings: %w", err)
 	}
+	return nil
+}
 
-	a.configMux.Lock()
-	defer a.configMux.Unlock()
+// scheduledBackups backs up the database every AutoBackupDays days and prunes old backups.
+// It checks hourly, so a backup missed while the app was closed happens soon after launch.
+func (a *App) scheduledBackups() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
 
-	// Handle autostart change
-	if settings.AutoStart != a.config.AutoStart {
-		execPath, err := utils.GetExecutablePath()
-		if err != nil {
-			log.Printf("Failed to get executable path for autostart: %v", err)
-			return fmt.Errorf("failed to get executable path: %w", err)
-		}
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.configMux.RLock()
+			days, keep := a.config.AutoBackupDays, a.config.BackupKeep
+			a.configMux.RUnlock()
+			if days <= 0 || a.db == nil {
+				continue
+			}
 
-		if settings.AutoStart {
-			if err := autostart.Enable(execPath); err != nil {
-				log.Printf("Failed to enable autostart: %v", err)
-				return fmt.Errorf("failed to enable autostart: %w", err)
+			dir := a.backupDir()
+			backups, err := database.ListBackups(dir)
+			if err != nil {
+				log.Printf("Failed to list backups: %v", err)
+				continue
 			}
-			log.Printf("Autostart enabled with path: %s", execPath)
-		} else {
-			if err := autostart.Disable(); err != nil {
-				log.Printf("Failed to disable autostart: %v", err)
-				return fmt.Errorf("failed to disable autostart: %w", err)
+			due := time.Now().AddDate(0, 0, -days).Unix()
+			if len(backups) > 0 && backups[0].CreatedAt > due {
+				continue
 			}
-			log.Printf("Autostart disabled")
-		}
-	}
-
-	// Handle data retention change - toggle save enabled for monitor
-	if settings.DataRetention != a.config.DataRetention && a.monitor != nil {
-		if settings.DataRetention == -2 {
-			a.monitor.SetSaveEnabled(false)
-		} else {
-			a.monitor.SetSaveEnabled(true)
-		}
-		log.Printf("Data retention changed from %d to %d", a.config.DataRetention, settings.DataRetention)
-
-		// Apply new retention immediately (run cleanup now)
-		go func(retention int) {
-			log.Printf("Applying retention change immediately: %d", retention)
-			a.db.DeleteExpiredRecords()
-			if retention == 0 {
-				cutoff := time.Now().Add(-1 * time.Minute).Unix()
-				a.db.DeleteOldRecords(cutoff)
-				log.Printf("Cleaned records older than 1 minute")
-			} else if retention > 0 {
-				cutoff := time.Now().AddDate(0, 0, -retention).Unix()
-				a.db.DeleteOldRecords(cutoff)
-				log.Printf("Cleaned records older than %d days", retention)
+			if _, err := a.BackupNow(); err != nil {
+				continue
 			}
-		}(settings.DataRetention)
-	}
-
-	// Save settings
-	if err := settings.Save(a.db); err != nil {
-		return err
+			if removed, err := database.PruneBackups(dir, keep); err != nil {
+				log.Printf("Failed to prune backups: %v", err)
+			} else if removed > 0 {
+				log.Printf("Pruned %d old backups", removed)
+			}
+		}
 	}
-
-	a.config = &settings
-	return nil
 }
 
-// PauseMonitoring pauses the network monitoring
-func (a *App) PauseMonitoring() {
-	if a.monitor != nil {
-		a.monitor.Pause()
+// ListCorruptedBackups returns backups of corrupted databases left by past recoveries, newest first
+func (a *App) ListCorruptedBackups() []database.CorruptedBackup {
+	if a.db == nil {
+		return nil
 	}
-	if a.tray != nil {
-		a.tray.UpdatePauseState(true)
+	backups, err := a.db.ListCorruptedBackups()
+	if err != nil {
+		a.queryFailed("list corrupted backups", err)
+		return []database.CorruptedBackup{}
 	}
+	return backups
 }
 
-// ResumeMonitoring resumes the network monitoring
-func (a *App) ResumeMonitoring() {
-	if a.monitor != nil {
-		a.monitor.Resume()
-	}
-	if a.tray != nil {
-		a.tray.UpdatePauseState(false)
+// DeleteCorruptedBackup removes a single corrupted-database backup
+func (a *App) DeleteCorruptedBackup(path string) error {
+	if a.db == nil {
+		return errNoDatabase
 	}
+	return a.db.DeleteCorruptedBackup(path)
 }
 
-// ClearOldData manually clears all old data from database
-func (a *App) ClearOldData() error {
-	// Clear all data
-	if err := a.db.ClearAllData(); err != nil {
-		return err
+// PruneCorruptedBackups keeps only the newest keep corrupted-database backups and returns how many were deleted
+func (a *App) PruneCorruptedBackups(keep int) (int, error) {
+	if a.db == nil {
+		return 0, errNoDatabase
 	}
-
-	// Vacuum to reclaim space
-	if err := a.db.Vacuum(); err != nil {
-		return fmt.Errorf("failed to vacuum database: %w", err)
+	if keep < 0 {
+		return 0, fmt.Errorf("invalid number of backups to keep: %d", keep)
 	}
-
-	return nil
+	return a.db.PruneCorruptedBackups(keep)
 }
 
 // GetDatabaseSize returns the database file size
 func (a *App) GetDatabaseSize() int64 {
+	if a.db == nil {
+		return 0
+	}
 	size, err := a.db.GetSize()
 	if err != nil {
 		return 0
@@ -289,14 +2685,158 @@ func (a *App) GetDatabaseSize() int64 {
 
 // GetDatabaseStats returns database statistics
 func (a *App) GetDatabaseStats() map[string]interface{} {
+	if a.db == nil {
+		return map[string]interface{}{}
+	}
 	size, _ := a.db.GetSize()
 	count, _ := a.db.GetRecordCount()
 	oldest, _ := a.db.GetOldestRecord()
+	destinations, _ := a.db.GetDestinationRecordCount()
 
 	return map[string]interface{}{
-		"size":    size,
-		"records": count,
-		"oldest":  oldest,
+		"size":               size,
+		"records":            count,
+		"oldest":             oldest,
+		"destinationRecords": destinations,
+	}
+}
+
+// RetentionPreview describes what a retention cleanup would remove
+type RetentionPreview struct {
+	RecordsToDelete int64 `json:"recordsToDelete"`
+	BytesEstimate   int64 `json:"bytesEstimate"` // Approximate database space freed
+}
+
+// PreviewRetentionCleanup reports what a cleanup with the given retention would remove without deleting anything
+func (a *App) PreviewRetentionCleanup(days int) (RetentionPreview, error) {
+	if a.db == nil {
+		return RetentionPreview{}, errNoDatabase
+	}
+	var cutoff int64
+	switch utils.ClassifyRetention(days) {
+	case utils.RetentionModeDays:
+		cutoff = time.Now().AddDate(0, 0, -days).Unix()
+	case utils.RetentionModeTest:
+		// 1-minute testing mode
+		cutoff = time.Now().Add(-1 * time.Minute).Unix()
+	default:
+		// Forever and "do not save" never delete by age
+		return RetentionPreview{}, nil
+	}
+
+	count, err := a.db.CountOldRecords(cutoff)
+	if err != nil {
+		return RetentionPreview{}, fmt.Errorf("failed to count old records: %w", err)
+	}
+
+	// Estimate freed space proportionally to the share of records removed
+	var bytesEstimate int64
+	total, err := a.db.GetRecordCount()
+	if err == nil && total > 0 {
+		if size, err := a.db.GetSize(); err == nil {
+			bytesEstimate = int64(float64(size) * float64(count) / float64(total))
+		}
+	}
+
+	return RetentionPreview{
+		RecordsToDelete: count,
+		BytesEstimate:   bytesEstimate,
+	}, nil
+}
+
+// DashboardSnapshot bundles everything the dashboard needs on load
+type DashboardSnapshot struct {
+	Settings      utils.Config                    `json:"settings"`
+	MonitorStatus monitor.MonitorStatus           `json:"monitorStatus"`
+	NetworkStats  map[string]*monitor.NetworkStat `json:"networkStats"`
+	TodayStats    map[string]interface{}          `json:"todayStats"`
+	AllTimeStats  map[string]interface{}          `json:"allTimeStats"`
+	DatabaseStats map[string]interface{}          `json:"databaseStats"`
+}
+
+// GetDashboardSnapshot returns settings, live stats and database stats in a single call
+func (a *App) GetDashboardSnapshot() DashboardSnapshot {
+	return DashboardSnapshot{
+		Settings:      a.GetSettings(),
+		MonitorStatus: a.GetMonitorStatus(),
+		NetworkStats:  a.GetNetworkStats(),
+		TodayStats:    a.GetTodayStats(),
+		AllTimeStats:  a.GetAllTimeStats(),
+		DatabaseStats: a.GetDatabaseStats(),
+	}
+}
+
+// SendTestNotification shows a sample notification so users can check toasts are delivered.
+// It goes through the same dispatch as alerts, so quiet hours hold it back too.
+func (a *App) SendTestNotification() error {
+	return a.notify(brand.AppName, "Notifications are working.")
+}
+
+// notify sends a notification unless quiet hours are on, in which case it is logged and then
+// queued or dropped depending on settings. All notifications go through here.
+func (a *App) notify(title, message string) error {
+	if a.notifier == nil {
+		if a.notifyErr != nil {
+			return fmt.Errorf("notifications failed to initialize: %w", a.notifyErr)
+		}
+		return fmt.Errorf("notifications not initialized")
+	}
+
+	a.configMux.RLock()
+	quiet := a.config.InQuietHours(time.Now())
+	queue := a.config.QueueQuietAlerts
+	a.configMux.RUnlock()
+
+	if quiet {
+		if !queue {
+			log.Printf("Quiet hours: dropped notification %q: %s", title, message)
+			return nil
+		}
+		log.Printf("Quiet hours: queued notification %q: %s", title, message)
+		a.quietMux.Lock()
+		a.quietQueue = append(a.quietQueue, notice{title: title, message: message})
+		if len(a.quietQueue) > maxQueuedNotices {
+			a.quietQueue = a.quietQueue[len(a.quietQueue)-maxQueuedNotices:]
+		}
+		a.quietMux.Unlock()
+		return nil
+	}
+
+	if err := a.notifier.Send(title, message); err != nil {
+		log.Printf("Failed to send notification: %v", err)
+		return err
+	}
+	return nil
+}
+
+// deliverQueuedNotices sends notifications held during quiet hours once they end
+func (a *App) deliverQueuedNotices() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			a.configMux.RLock()
+			quiet := a.config.InQuietHours(time.Now())
+			a.configMux.RUnlock()
+			if quiet {
+				continue
+			}
+
+			a.quietMux.Lock()
+			queued := a.quietQueue
+			a.quietQueue = nil
+			a.quietMux.Unlock()
+
+			for _, n := range queued {
+				if err := a.notifier.Send(n.title, n.message); err != nil {
+					log.Printf("Failed to send queued notification: %v", err)
+				}
+			}
+		}
 	}
 }
 
@@ -332,19 +2872,173 @@ func (a *App) updateTrayTooltip() {
 			return
 		case <-ticker.C:
 			if a.tray != nil && a.monitor != nil {
-				stats := a.monitor.GetStats()
-				var totalUp, totalDown int64
-				for _, stat := range stats {
-					totalUp += stat.UploadSpeed
-					totalDown += stat.DownloadSpeed
+				a.tray.UpdateTooltip(a.renderTrayTooltip())
+			}
+		}
+	}
+}
+
+// renderTrayTooltip fills the configured tray template with live stats
+func (a *App) renderTrayTooltip() string {
+	if waiting := a.GetWaitingFor(); len(waiting) > 0 {
+		return brand.AppName + "\nWaiting for " + strings.Join(waiting, ", ")
+	}
+
+	a.configMux.RLock()
+	format := a.config.TrayFormat
+	unit := a.config.TrayUnit
+	a.configMux.RUnlock()
+	if format == "" {
+		format = utils.DefaultTrayFormat
+	}
+
+	stats := a.monitor.GetStats()
+	var totalUp, totalDown int64
+	for _, stat := range stats {
+		totalUp += stat.UploadSpeed
+		totalDown += stat.DownloadSpeed
+	}
+
+	values := map[string]string{
+		"up":     utils.FormatSpeedAs(totalUp, unit),
+		"down":   utils.FormatSpeedAs(totalDown, unit),
+		"active": fmt.Sprintf("%d", len(stats)),
+	}
+
+	// Only hit the database when the template needs today's total
+	if strings.Contains(format, "{today}") {
+		var today int64
+		if summary, err := a.db.GetDailySummary(time.Now().Format("2006-01-02")); err == nil {
+			today = summary.TotalUpload + summary.TotalDownload
+		}
+		values["today"] = utils.FormatBytesAs(today, unit)
+	}
+
+	return utils.RenderTrayFormat(format, values)
+}
+
+// monitorWatchdog restarts the monitor if it stops producing updates while not paused
+func (a *App) monitorWatchdog() {
+	const (
+		checkInterval  = 30 * time.Second
+		staleThreshold = 60 * time.Second
+	)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			if a.monitor == nil {
+				continue
+			}
+			status := a.monitor.GetMonitorStatus()
+			// An unhealthy monitor is still running, it is just failing to collect
+			if status.Paused || !status.Healthy {
+				continue
+			}
+			if time.Since(status.LastUpdate) > staleThreshold {
+				log.Printf("Monitor has not updated since %v, restarting", status.LastUpdate)
+				a.monitor.Restart()
+			}
+		}
+	}
+}
+
+// screenLockWatcher polls the workstation lock state and hands it to the monitor, which stamps it
+// on collected records. Records are marked unknown while the state can't be read.
+func (a *App) screenLockWatcher() {
+	const checkInterval = 2 * time.Second
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			if a.monitor == nil {
+				continue
+			}
+			state := database.ScreenUnknown
+			if locked, ok := monitor.ScreenLocked(); ok {
+				state = database.ScreenUnlocked
+				if locked {
+					state = database.ScreenLocked
+				}
+			}
+			a.monitor.SetScreenState(state)
+		}
+	}
+}
+
+// processWatcher pauses monitoring while none of the ActiveWhenRunning apps are running
+// and resumes it when one starts. A manual pause is left alone.
+func (a *App) processWatcher() {
+	const checkInterval = 5 * time.Second
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			if a.monitor == nil {
+				continue
+			}
+			a.configMux.RLock()
+			watched := append([]string{}, a.config.ActiveWhenRunning...)
+			a.configMux.RUnlock()
+
+			var running map[string]bool
+			if len(watched) > 0 {
+				var err error
+				if running, err = monitor.RunningProcesses(); err != nil {
+					log.Printf("Failed to list running processes: %v", err)
+					continue
 				}
-				tooltip := fmt.Sprintf("Netpus\n↑ %s/s ↓ %s/s",
-					utils.FormatSpeed(totalUp),
-					utils.FormatSpeed(totalDown))
-				a.tray.UpdateTooltip(tooltip)
 			}
+			a.applyWatchedApps(watched, running)
+		}
+	}
+}
+
+// applyWatchedApps pauses monitoring when none of the watched apps is among the running
+// processes (canonical names) and resumes it once one is. A pause by the user is left alone.
+func (a *App) applyWatchedApps(watched []string, running map[string]bool) {
+	a.waitingMux.Lock()
+	waiting := len(a.waitingFor) > 0
+	pausedByUser := a.pausedByUser
+	a.waitingMux.Unlock()
+	if pausedByUser {
+		return
+	}
+
+	anyRunning := len(watched) == 0
+	for _, app := range watched {
+		if running[utils.CanonicalAppName(app)] {
+			anyRunning = true
+			break
 		}
 	}
+
+	switch {
+	case anyRunning && waiting:
+		log.Printf("Watched app is running, resuming monitoring")
+		a.resumeMonitoring()
+	case !anyRunning && !waiting && !a.monitor.GetMonitorStatus().Paused:
+		log.Printf("Waiting for %s before monitoring", strings.Join(watched, ", "))
+		a.pauseMonitoring()
+		a.waitingMux.Lock()
+		a.waitingFor = watched
+		a.waitingMux.Unlock()
+	}
 }
 
 // periodicCleanup performs daily database cleanup
@@ -363,23 +3057,79 @@ func (a *App) periodicCleanup() {
 			// Handle retention policy
 			a.configMux.RLock()
 			retention := a.config.DataRetention
+			summaryDays := a.config.SummaryRetentionDays
 			a.configMux.RUnlock()
 
-			if retention > 0 {
+			switch utils.ClassifyRetention(retention) {
+			case utils.RetentionModeDays:
 				cutoff := time.Now().AddDate(0, 0, -retention).Unix()
-				a.db.DeleteOldRecords(cutoff)
-			} else if retention == 0 {
+				a.pruneOldRecords(cutoff, summaryDays)
+			case utils.RetentionModeTest:
 				// 1-minute testing mode
 				cutoff := time.Now().Add(-1 * time.Minute).Unix()
-				a.db.DeleteOldRecords(cutoff)
+				a.pruneOldRecords(cutoff, summaryDays)
 			}
-			// -1 means forever, don't delete based on age
+			// Forever and "do not save" don't delete based on age
 
-			a.db.Vacuum()
+			if result, err := a.db.VacuumIfNeeded(); err != nil {
+				log.Printf("Failed to vacuum database: %v", err)
+			} else {
+				log.Printf("Database %s", result.Reason)
+			}
 		}
 	}
 }
 
+// summaryCutoff returns the timestamp before which daily summaries are deleted, or 0 to keep them forever
+func summaryCutoff(days int) int64 {
+	if days <= 0 {
+		return 0
+	}
+	return time.Now().AddDate(0, 0, -days).Unix()
+}
+
+// dataPrunedEvent is emitted to the frontend after retention cleanup removes records
+const dataPrunedEvent = "data:pruned"
+
+// DataPruned tells the frontend that retention cleanup removed old records, so charts can refresh
+type DataPruned struct {
+	Records int64 `json:"records"` // Usage records removed
+	Cutoff  int64 `json:"cutoff"`  // Unix timestamp records older than were removed
+}
+
+// pruneOldRecords applies retention with the given cutoff and emits a "data:pruned" event when
+// records were removed. Destination totals are pruned alongside, by their own retention if set.
+func (a *App) pruneOldRecords(cutoff int64, summaryDays int) error {
+	a.pruneDestinationUsage(cutoff)
+	deleted, err := a.db.DeleteOldRecords(cutoff, summaryCutoff(summaryDays))
+	if deleted > 0 && a.ctx != nil {
+		runtime.EventsEmit(a.ctx, dataPrunedEvent, DataPruned{Records: deleted, Cutoff: cutoff})
+	}
+	return err
+}
+
+// pruneDestinationUsage deletes destination totals past the connection retention, or older than
+// recordCutoff when no connection retention is set. A recordCutoff of 0 keeps them.
+func (a *App) pruneDestinationUsage(recordCutoff int64) {
+	a.configMux.RLock()
+	days := a.config.ConnectionRetentionDays
+	a.configMux.RUnlock()
+
+	cutoff := recordCutoff
+	if days > 0 {
+		cutoff = time.Now().AddDate(0, 0, -days).Unix()
+	}
+	if cutoff <= 0 {
+		return
+	}
+	deleted, err := a.db.DeleteOldDestinationUsage(cutoff)
+	if err != nil {
+		log.Printf("Failed to delete old destination usage: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d destination records older than %s", deleted, time.Unix(cutoff, 0).Format("2006-01-02 15:04"))
+	}
+}
+
 // hourlyCleanup runs every 30 minutes for retention enforcement
 func (a *App) hourlyCleanup() {
 	ticker := time.NewTicker(30 * time.Minute)
@@ -392,11 +3142,15 @@ func (a *App) hourlyCleanup() {
 		case <-ticker.C:
 			a.configMux.RLock()
 			retention := a.config.DataRetention
+			summaryDays := a.config.SummaryRetentionDays
 			a.configMux.RUnlock()
 
-			if retention > 0 {
+			if utils.ClassifyRetention(retention) == utils.RetentionModeDays {
 				cutoff := time.Now().AddDate(0, 0, -retention).Unix()
-				a.db.DeleteOldRecords(cutoff)
+				a.pruneOldRecords(cutoff, summaryDays)
+			} else {
+				// Destination totals can expire even when records are kept forever
+				a.pruneDestinationUsage(0)
 			}
 		}
 	}
@@ -405,15 +3159,15 @@ func (a *App) hourlyCleanup() {
 // minuteCleanup runs every minute for expiration and testing retention
 func (a *App) minuteCleanup() {
 	// Helper to get current retention setting safely
-	getRetention := func() int {
+	getRetention := func() (int, int) {
 		a.configMux.RLock()
 		defer a.configMux.RUnlock()
-		return a.config.DataRetention
+		return a.config.DataRetention, a.config.SummaryRetentionDays
 	}
 
 	// Helper to perform cleanup based on retention
 	doCleanup := func() {
-		retention := getRetention()
+		retention, summaryDays := getRetention()
 		log.Printf("Running cleanup with retention=%d", retention)
 
 		// Delete expired records (24-hour auto-deletion)
@@ -422,22 +3176,23 @@ func (a *App) minuteCleanup() {
 		}
 
 		// Handle retention based on setting
-		if retention == 0 {
+		switch utils.ClassifyRetention(retention) {
+		case utils.RetentionModeTest:
 			// 1-minute testing retention
 			cutoff := time.Now().Add(-1 * time.Minute).Unix()
-			if err := a.db.DeleteOldRecords(cutoff); err != nil {
+			if err := a.pruneOldRecords(cutoff, summaryDays); err != nil {
 				log.Printf("Failed to delete 1-minute old records: %v", err)
 			} else {
 				log.Printf("Cleaned records older than 1 minute")
 			}
-		} else if retention > 0 {
+		case utils.RetentionModeDays:
 			// N-day retention
 			cutoff := time.Now().AddDate(0, 0, -retention).Unix()
-			if err := a.db.DeleteOldRecords(cutoff); err != nil {
+			if err := a.pruneOldRecords(cutoff, summaryDays); err != nil {
 				log.Printf("Failed to delete old records: %v", err)
 			}
 		}
-		// retention == -1 (Forever) or -2 (Do not save): no age-based deletion
+		// Forever or "do not save": no age-based deletion
 	}
 
 	// Run cleanup immediately on startup
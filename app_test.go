@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"netpus/internal/monitor"
+	"netpus/internal/utils"
+)
+
+func TestManualPauseOverridesProcessWatcher(t *testing.T) {
+	a := &App{monitor: monitor.New(nil)}
+	watched := []string{"game.exe"}
+
+	// Nothing watched is running, so the watcher pauses and waits
+	a.applyWatchedApps(watched, map[string]bool{})
+	if len(a.GetWaitingFor()) == 0 || !a.monitor.GetMonitorStatus().Paused {
+		t.Fatal("watcher did not pause while waiting for game.exe")
+	}
+
+	a.PauseMonitoring()
+	if waiting := a.GetWaitingFor(); len(waiting) != 0 {
+		t.Errorf("still waiting for %v after a manual pause", waiting)
+	}
+
+	// The watched app starting must not end the user's pause
+	a.applyWatchedApps(watched, map[string]bool{"game": true})
+	if !a.monitor.GetMonitorStatus().Paused {
+		t.Error("watcher resumed monitoring over a manual pause")
+	}
+
+	a.ResumeMonitoring()
+	if a.monitor.GetMonitorStatus().Paused {
+		t.Error("monitoring still paused after ResumeMonitoring")
+	}
+}
+
+func TestRetentionChangeKeepsLiveOnlyMode(t *testing.T) {
+	a := &App{monitor: monitor.New(nil), config: utils.DefaultConfig()}
+
+	if err := a.SetLiveOnlyMode(true); err != nil {
+		t.Fatalf("SetLiveOnlyMode: %v", err)
+	}
+	a.applySaveEnabled(30)
+	if !a.IsLiveOnlyMode() || a.monitor.GetMonitorStatus().SaveEnabled {
+		t.Error("changing retention left live-only mode")
+	}
+
+	if err := a.SetLiveOnlyMode(false); err != nil {
+		t.Fatalf("SetLiveOnlyMode: %v", err)
+	}
+	if a.IsLiveOnlyMode() || !a.monitor.GetMonitorStatus().SaveEnabled {
+		t.Error("saving still off after leaving live-only mode")
+	}
+}
+
+func TestBackupsWithoutDatabase(t *testing.T) {
+	a := &App{config: utils.DefaultConfig()}
+	if backups := a.GetBackups(); backups == nil || len(backups) != 0 {
+		t.Errorf("GetBackups() = %v, want an empty list", backups)
+	}
+	if _, err := a.BackupNow(); err != errNoDatabase {
+		t.Errorf("BackupNow() error = %v, want errNoDatabase", err)
+	}
+}
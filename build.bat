@@ -4,6 +4,13 @@ setlocal enabledelayedexpansion
 REM Set PowerShell path
 set "PS=%SystemRoot%\System32\WindowsPowerShell\v1.0\powershell.exe"
 
+REM App identity, compiled into the executable (see internal/brand). Change these to rebrand a fork.
+set "APP_NAME=Netpus"
+set "PRODUCT_NAME=Netpus Network Monitor"
+set "VENDOR=Netpus"
+set "DATA_DIR=netpus"
+set "BRAND=netpus/internal/brand"
+
 echo.
 echo  ================================================
 echo       NETPUS - One-Click Build ^& Install
@@ -69,11 +76,11 @@ echo.
 REM =============================================
 REM Step 4: Build Production Executable
 REM =============================================
-echo [4/5] Building Netpus.exe...
+echo [4/5] Building %APP_NAME%.exe...
 echo       This may take 1-2 minutes on first build...
 echo.
 
-wails build -clean -o Netpus.exe
+wails build -clean -o "%APP_NAME%.exe" -ldflags "-X '%BRAND%.AppName=%APP_NAME%' -X '%BRAND%.ProductName=%PRODUCT_NAME%' -X '%BRAND%.Vendor=%VENDOR%' -X '%BRAND%.DataDirName=%DATA_DIR%'"
 if %errorlevel% neq 0 (
     echo.
     echo  ERROR: Build failed!
@@ -88,14 +95,14 @@ echo.
 REM =============================================
 REM Step 5: Install to Program Files & Create Shortcuts
 REM =============================================
-echo [5/5] Installing Netpus...
+echo [5/5] Installing %APP_NAME%...
 
 REM Create installation directory
-set "INSTALL_DIR=%LOCALAPPDATA%\Netpus"
+set "INSTALL_DIR=%LOCALAPPDATA%\%APP_NAME%"
 if not exist "%INSTALL_DIR%" mkdir "%INSTALL_DIR%"
 
 REM Copy executable to install directory
-copy /Y "build\bin\Netpus.exe" "%INSTALL_DIR%\Netpus.exe" >nul
+copy /Y "build\bin\%APP_NAME%.exe" "%INSTALL_DIR%\%APP_NAME%.exe" >nul
 if %errorlevel% neq 0 (
     echo       Warning: Could not copy to install directory.
     echo       Using build directory instead.
@@ -106,7 +113,7 @@ REM Create Start Menu shortcut (makes it searchable in Windows)
 set "STARTMENU=%APPDATA%\Microsoft\Windows\Start Menu\Programs"
 echo       Creating Start Menu shortcut...
 
-"%PS%" -NoProfile -Command "$WshShell = New-Object -ComObject WScript.Shell; $Shortcut = $WshShell.CreateShortcut('%STARTMENU%\Netpus.lnk'); $Shortcut.TargetPath = '%INSTALL_DIR%\Netpus.exe'; $Shortcut.WorkingDirectory = '%INSTALL_DIR%'; $Shortcut.Description = 'Network Bandwidth Monitor'; $Shortcut.Save()"
+"%PS%" -NoProfile -Command "$WshShell = New-Object -ComObject WScript.Shell; $Shortcut = $WshShell.CreateShortcut('%STARTMENU%\%APP_NAME%.lnk'); $Shortcut.TargetPath = '%INSTALL_DIR%\%APP_NAME%.exe'; $Shortcut.WorkingDirectory = '%INSTALL_DIR%'; $Shortcut.Description = 'Network Bandwidth Monitor'; $Shortcut.Save()"
 
 if %errorlevel% neq 0 (
     echo       Warning: Could not create Start Menu shortcut.
@@ -118,7 +125,7 @@ REM Create Desktop shortcut
 set "DESKTOP=%USERPROFILE%\Desktop"
 echo       Creating Desktop shortcut...
 
-"%PS%" -NoProfile -Command "$WshShell = New-Object -ComObject WScript.Shell; $Shortcut = $WshShell.CreateShortcut('%DESKTOP%\Netpus.lnk'); $Shortcut.TargetPath = '%INSTALL_DIR%\Netpus.exe'; $Shortcut.WorkingDirectory = '%INSTALL_DIR%'; $Shortcut.Description = 'Network Bandwidth Monitor'; $Shortcut.Save()"
+"%PS%" -NoProfile -Command "$WshShell = New-Object -ComObject WScript.Shell; $Shortcut = $WshShell.CreateShortcut('%DESKTOP%\%APP_NAME%.lnk'); $Shortcut.TargetPath = '%INSTALL_DIR%\%APP_NAME%.exe'; $Shortcut.WorkingDirectory = '%INSTALL_DIR%'; $Shortcut.Description = 'Network Bandwidth Monitor'; $Shortcut.Save()"
 
 if %errorlevel% neq 0 (
     echo       Warning: Could not create Desktop shortcut.
@@ -128,14 +135,14 @@ if %errorlevel% neq 0 (
 
 REM Register in Windows Apps & Features (Add/Remove Programs)
 echo       Registering in Apps ^& Features...
-set "UNINSTALL_KEY=HKCU\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\Netpus"
+set "UNINSTALL_KEY=HKCU\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\%APP_NAME%"
 set "REG=%SystemRoot%\System32\reg.exe"
-"%REG%" add "%UNINSTALL_KEY%" /v "DisplayName" /t REG_SZ /d "Netpus Network Monitor" /f >nul 2>nul
+"%REG%" add "%UNINSTALL_KEY%" /v "DisplayName" /t REG_SZ /d "%PRODUCT_NAME%" /f >nul 2>nul
 "%REG%" add "%UNINSTALL_KEY%" /v "DisplayVersion" /t REG_SZ /d "1.0.0" /f >nul 2>nul
-"%REG%" add "%UNINSTALL_KEY%" /v "Publisher" /t REG_SZ /d "Netpus" /f >nul 2>nul
+"%REG%" add "%UNINSTALL_KEY%" /v "Publisher" /t REG_SZ /d "%VENDOR%" /f >nul 2>nul
 "%REG%" add "%UNINSTALL_KEY%" /v "InstallLocation" /t REG_SZ /d "%INSTALL_DIR%" /f >nul 2>nul
-"%REG%" add "%UNINSTALL_KEY%" /v "UninstallString" /t REG_SZ /d "\"%INSTALL_DIR%\Netpus.exe\" --uninstall" /f >nul 2>nul
-"%REG%" add "%UNINSTALL_KEY%" /v "DisplayIcon" /t REG_SZ /d "%INSTALL_DIR%\Netpus.exe" /f >nul 2>nul
+"%REG%" add "%UNINSTALL_KEY%" /v "UninstallString" /t REG_SZ /d "\"%INSTALL_DIR%\%APP_NAME%.exe\" --uninstall" /f >nul 2>nul
+"%REG%" add "%UNINSTALL_KEY%" /v "DisplayIcon" /t REG_SZ /d "%INSTALL_DIR%\%APP_NAME%.exe" /f >nul 2>nul
 "%REG%" add "%UNINSTALL_KEY%" /v "NoModify" /t REG_DWORD /d 1 /f >nul 2>nul
 "%REG%" add "%UNINSTALL_KEY%" /v "NoRepair" /t REG_DWORD /d 1 /f >nul 2>nul
 "%REG%" add "%UNINSTALL_KEY%" /v "EstimatedSize" /t REG_DWORD /d 15000 /f >nul 2>nul
@@ -146,10 +153,10 @@ echo  ================================================
 echo       INSTALLATION COMPLETE!
 echo  ================================================
 echo.
-echo  Netpus has been installed and is ready to use!
+echo  %APP_NAME% has been installed and is ready to use!
 echo.
 echo  You can now:
-echo    - Search "Netpus" in Windows Start Menu
+echo    - Search "%APP_NAME%" in Windows Start Menu
 echo    - Use the Desktop shortcut
 echo    - Pin it to your Taskbar (right-click shortcut)
 echo.
@@ -159,7 +166,7 @@ echo  ================================================
 echo.
 
 REM Auto-launch the app
-echo Starting Netpus...
-start "" "%INSTALL_DIR%\Netpus.exe"
+echo Starting %APP_NAME%...
+start "" "%INSTALL_DIR%\%APP_NAME%.exe"
 
 echo.
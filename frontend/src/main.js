@@ -374,10 +374,14 @@ async function resumeMonitoring() {
     }
 }
 
+// Last settings loaded from the backend, so saves keep fields the UI doesn't edit
+let currentSettings = {};
+
 // Load settings
 async function loadSettings() {
     try {
         const settings = await window.go.main.App.GetSettings();
+        currentSettings = settings;
 
         document.getElementById('autoStartCheck').checked = settings.AutoStart || false;
         document.getElementById('themeSelect').value = settings.Theme || 'auto';
@@ -393,6 +397,7 @@ async function loadSettings() {
 async function saveSettings() {
     try {
         const settings = {
+            ...currentSettings,
             AutoStart: document.getElementById('autoStartCheck').checked,
             Theme: document.getElementById('themeSelect').value,
             DataRetention: parseInt(document.getElementById('retentionSelect').value),
@@ -400,6 +405,7 @@ async function saveSettings() {
         };
 
         await window.go.main.App.UpdateSettings(settings);
+        currentSettings = settings;
 
         applyTheme(settings.Theme);
     } catch (error) {
@@ -411,6 +417,7 @@ async function saveSettings() {
 async function autoSaveSettings() {
     try {
         const settings = {
+            ...currentSettings,
             AutoStart: document.getElementById('autoStartCheck').checked,
             Theme: document.getElementById('themeSelect').value,
             DataRetention: parseInt(document.getElementById('retentionSelect').value),
@@ -418,6 +425,7 @@ async function autoSaveSettings() {
         };
 
         await window.go.main.App.UpdateSettings(settings);
+        currentSettings = settings;
 
         applyTheme(settings.Theme);
     } catch (error) {
@@ -474,20 +482,42 @@ async function cleanupOldData() {
     }
 }
 
-// Format bytes to human-readable format
+// Format bytes to human-readable format, matching the backend's utils.FormatBytesAs
 function formatBytes(bytes) {
-    if (bytes === 0) return '0 B';
-    const k = 1024;
-    const sizes = ['B', 'KB', 'MB', 'GB', 'TB'];
-    const i = Math.floor(Math.log(bytes) / Math.log(k));
-    return Math.round(bytes / Math.pow(k, i) * 100) / 100 + ' ' + sizes[i];
+    const unit = currentSettings.displayUnit;
+    const si = unit === 'si' || unit === 'bits' || (unit !== 'binary' && currentSettings.useSIUnits);
+    if (si) return formatScaled(bytes, 1000, 'B', ['KB', 'MB', 'GB', 'TB', 'PB', 'EB']);
+    return formatScaled(bytes, 1024, 'B', ['KiB', 'MiB', 'GiB', 'TiB', 'PiB', 'EiB']);
 }
 
 // Format speed (bytes per second)
 function formatSpeed(bytesPerSecond) {
+    if (currentSettings.displayUnit === 'bits') {
+        return formatScaled(bytesPerSecond * 8, 1000, 'bps', ['Kbps', 'Mbps', 'Gbps', 'Tbps', 'Pbps', 'Ebps']);
+    }
     return formatBytes(bytesPerSecond) + '/s';
 }
 
+// Divide value by powers of base until it fits and append the matching unit (utils.formatScaled)
+function formatScaled(value, base, baseUnit, units) {
+    value = Math.floor(value);
+    if (value < base) return value + ' ' + baseUnit;
+
+    let div = base, exp = 0;
+    for (let n = Math.floor(value / base); n >= base && exp + 1 < units.length; n = Math.floor(n / base)) {
+        div *= base;
+        exp++;
+    }
+
+    // Just below the next unit the value rounds up to the base ("1024.0 KiB"); show it as that unit
+    let scaled = value / div;
+    if (Math.round(scaled * 10) / 10 >= base && exp + 1 < units.length) {
+        scaled /= base;
+        exp++;
+    }
+    return scaled.toFixed(1) + ' ' + units[exp];
+}
+
 // Format Unix timestamp
 function formatTimestamp(timestamp) {
     if (!timestamp) return 'Never';
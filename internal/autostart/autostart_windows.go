@@ -6,15 +6,18 @@ import (
 	"fmt"
 
 	"golang.org/x/sys/windows/registry"
-)
 
-const (
-	regPath = `Software\Microsoft\Windows\CurrentVersion\Run`
-	appName = "Netpus"
+	"netpus/internal/brand"
 )
 
-// Enable enables autostart on Windows via registry
-func Enable(execPath string) error {
+const regPath = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+// appName is the value the autostart command is stored under
+var appName = brand.AppName
+
+// Enable enables autostart on Windows via registry.
+// A positive delaySeconds is passed as --delay so boot launches wait for the network stack.
+func Enable(execPath string, delaySeconds int) error {
 	key, err := registry.OpenKey(registry.CURRENT_USER, regPath, registry.SET_VALUE)
 	if err != nil {
 		return fmt.Errorf("failed to open registry key: %w", err)
@@ -22,9 +25,12 @@ func Enable(execPath string) error {
 	defer key.Close()
 
 	// Quote the path to handle spaces
-	quotedPath := fmt.Sprintf(`"%s"`, execPath)
+	command := fmt.Sprintf(`"%s"`, execPath)
+	if delaySeconds > 0 {
+		command += fmt.Sprintf(" --delay %d", delaySeconds)
+	}
 
-	err = key.SetStringValue(appName, quotedPath)
+	err = key.SetStringValue(appName, command)
 	if err != nil {
 		return fmt.Errorf("failed to set registry value: %w", err)
 	}
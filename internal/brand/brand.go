@@ -0,0 +1,39 @@
+// Package brand holds the app's identity: the names it shows and the names of everything it
+// creates on the machine. Forked builds can rebrand without code changes by overriding the
+// variables at build time, e.g.
+//
+//	wails build -ldflags "-X netpus/internal/brand.AppName=Acme -X netpus/internal/brand.DataDirName=acme"
+package brand
+
+import "strings"
+
+var (
+	// AppName is the short name used for shortcuts, the autostart entry, the tray and notifications
+	AppName = "Netpus"
+	// ProductName is the full name used for the window title and in Apps & Features
+	ProductName = "Netpus Network Monitor"
+	// Vendor is the publisher shown in Apps & Features
+	Vendor = "Netpus"
+	// DataDirName names the data folder and the files Netpus creates in it
+	DataDirName = "netpus"
+)
+
+// DatabaseFileName is the name of the database file, e.g. netpus.db
+func DatabaseFileName() string {
+	return DataDirName + ".db"
+}
+
+// LockFileName is the name of the lock file kept in the temp folder while running, e.g. netpus.lock
+func LockFileName() string {
+	return DataDirName + ".lock"
+}
+
+// ShortcutName is the file name of the desktop and start menu shortcuts, e.g. Netpus.lnk
+func ShortcutName() string {
+	return AppName + ".lnk"
+}
+
+// MutexName is the system-wide mutex that keeps a single instance running, e.g. Global\NetpusNetworkMonitor
+func MutexName() string {
+	return `Global\` + strings.ReplaceAll(ProductName, " ", "")
+}
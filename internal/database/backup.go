@@ -0,0 +1,401 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+
+	"netpus/internal/brand"
+)
+
+// backupPrefix and backupLayout name backups made by Backup: netpus-backup-20060102_150405.db
+const (
+	backupLayout = "20060102_150405"
+	backupSuffix = ".db"
+)
+
+var backupPrefix = brand.DataDirName + "-backup-"
+
+// restoreTables lists the tables RestoreFrom copies out of a backup. lifetime_totals is merged
+// separately because the lifetime counters never go down.
+var restoreTables = []string{
+	"usage_records",
+	"daily_summaries",
+	"app_metadata",
+	"settings",
+	"system_usage",
+	"destination_usage",
+	"app_display",
+	"speed_tests",
+}
+
+// Backup describes a backup file made by Backup
+type Backup struct {
+	Path      string
+	Size      int64
+	CreatedAt int64 // Unix timestamp parsed from the file name
+}
+
+// Backup writes a consistent copy of the database into dir with VACUUM INTO, which reads a single
+// snapshot, so monitoring can keep writing while it runs. It waits for any vacuum in progress.
+func (db *DB) Backup(dir string) (Backup, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Backup{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	db.vacuumMux.Lock()
+	defer db.vacuumMux.Unlock()
+
+	createdAt := time.Now()
+	path := filepath.Join(dir, backupPrefix+createdAt.Format(backupLayout)+backupSuffix)
+	if _, err := os.Stat(path); err == nil {
+		return Backup{}, fmt.Errorf("backup already exists: %s", path)
+	}
+	if _, err := db.conn.Exec("VACUUM INTO ?", path); err != nil {
+		os.Remove(path)
+		return Backup{}, fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{Path: path, Size: info.Size(), CreatedAt: createdAt.Unix()}, nil
+}
+
+// onlineBackuper is implemented by the sqlite driver's connections
+type onlineBackuper interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+}
+
+// CopyTo writes a self-contained copy of the database to destPath with SQLite's online backup
+// API, which copies one consistent snapshot even while monitoring writes, unlike a file copy that
+// can catch a write half done. The copy is written beside destPath, switched to a rollback journal
+// so it needs no -wal file, and integrity-checked before it replaces destPath.
+func (db *DB) CopyTo(destPath string) error {
+	if destPath == "" {
+		return fmt.Errorf("no destination given")
+	}
+	if same, _ := sameFile(destPath, db.path); same {
+		return fmt.Errorf("cannot copy the database onto itself")
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	db.vacuumMux.Lock()
+	defer db.vacuumMux.Unlock()
+
+	// Move committed pages out of the WAL so the snapshot doesn't depend on it
+	if _, err := db.conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+
+	tmpPath := destPath + ".partial"
+	os.Remove(tmpPath)
+	if err := db.onlineBackup(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy database: %w", err)
+	}
+	if err := finishCopy(tmpPath); err != nil {
+		removeDatabaseFiles(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write %s: %w", destPath, err)
+	}
+	return nil
+}
+
+// onlineBackup copies every page of the database to path in a single step, so concurrent writes
+// can't restart it
+func (db *DB) onlineBackup(path string) error {
+	ctx := context.Background()
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		backuper, ok := driverConn.(onlineBackuper)
+		if !ok {
+			return fmt.Errorf("sqlite driver does not support online backup")
+		}
+		backup, err := backuper.NewBackup(path)
+		if err != nil {
+			return err
+		}
+		if _, err := backup.Step(-1); err != nil {
+			backup.Finish()
+			return err
+		}
+		return backup.Finish()
+	})
+}
+
+// finishCopy makes a fresh copy a single portable file and verifies it
+func finishCopy(path string) error {
+	copyConn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("cannot open copy: %w", err)
+	}
+	_, err = copyConn.Exec("PRAGMA journal_mode=DELETE")
+	copyConn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to finalize copy: %w", err)
+	}
+
+	if err := checkDatabaseIntegrity(path); err != nil {
+		return fmt.Errorf("copy failed verification: %w", err)
+	}
+	return nil
+}
+
+// removeDatabaseFiles deletes a database file along with any WAL and shared-memory files
+func removeDatabaseFiles(path string) {
+	os.Remove(path)
+	os.Remove(path + "-wal")
+	os.Remove(path + "-shm")
+}
+
+// sameFile reports whether two paths name the same existing file
+func sameFile(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+// ListBackups returns the backups in dir, newest first. A missing directory has no backups.
+func ListBackups(dir string) ([]Backup, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]Backup, 0, len(matches))
+	for _, path := range matches {
+		createdAt, ok := parseBackupName(path)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		backups = append(backups, Backup{
+			Path:      path,
+			Size:      info.Size(),
+			CreatedAt: createdAt.Unix(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt > backups[j].CreatedAt
+	})
+	return backups, nil
+}
+
+// PruneBackups deletes all but the newest keep backups in dir and returns how many were removed
+func PruneBackups(dir string, keep int) (int, error) {
+	if keep < 0 {
+		keep = 0
+	}
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(backups) <= keep {
+		return 0, nil
+	}
+
+	var removed int
+	var firstErr error
+	for _, backup := range backups[keep:] {
+		if err := os.Remove(backup.Path); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to delete backup: %w", err)
+			}
+			continue
+		}
+		removed++
+	}
+	return removed, firstErr
+}
+
+// parseBackupName returns the creation time encoded in a backup's file name
+func parseBackupName(path string) (time.Time, bool) {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	createdAt, err := time.ParseInLocation(backupLayout, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+// checkBackup verifies that path is an intact Netpus database
+func checkBackup(path string) error {
+	src, err := sql.Open("sqlite", readOnlyDSN(path))
+	if err != nil {
+		return fmt.Errorf("cannot open backup: %w", err)
+	}
+	defer src.Close()
+
+	var result string
+	if err := src.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return fmt.Errorf("cannot read backup: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup is damaged: %s", result)
+	}
+	var tables int
+	if err := src.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'usage_records'`).Scan(&tables); err != nil {
+		return fmt.Errorf("cannot read backup: %w", err)
+	}
+	if tables == 0 {
+		return fmt.Errorf("not a %s database: %s", brand.AppName, path)
+	}
+	return nil
+}
+
+// RestoreFrom replaces all stored data with the contents of a backup, in one transaction on the
+// open database, so readers and the monitor never see a missing file. Backups from older schema
+// versions are accepted; columns they lack take their defaults. Lifetime totals keep the larger of
+// the current and the backup's counters.
+func (db *DB) RestoreFrom(backupPath string) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	info, err := os.Stat(backupPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("backup not found: %s", backupPath)
+	}
+	if err := checkBackup(backupPath); err != nil {
+		return err
+	}
+
+	db.vacuumMux.Lock()
+	defer db.vacuumMux.Unlock()
+
+	// ATTACH only applies to one connection, so keep the whole restore on a single one
+	ctx := context.Background()
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS backup", backupPath); err != nil {
+		return fmt.Errorf("failed to attach backup: %w", err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE backup")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range restoreTables {
+		columns, err := sharedColumns(tx, table)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM main." + table); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+		if len(columns) == 0 {
+			continue // The backup predates this table
+		}
+		list := strings.Join(columns, ", ")
+		if _, err := tx.Exec(fmt.Sprintf("INSERT INTO main.%s (%s) SELECT %s FROM backup.%s", table, list, list, table)); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+	}
+
+	// Backups from before lifetime totals existed count their summaries, as at first start
+	saved := `SELECT COALESCE(SUM(total_upload), 0), COALESCE(SUM(total_download), 0) FROM main.daily_summaries`
+	columns, err := sharedColumns(tx, "lifetime_totals")
+	if err != nil {
+		return err
+	}
+	if len(columns) > 0 {
+		saved = `SELECT COALESCE(MAX(total_upload), 0), COALESCE(MAX(total_download), 0) FROM backup.lifetime_totals WHERE id = 1`
+	}
+	var upload, download int64
+	if err := tx.QueryRow(saved).Scan(&upload, &download); err != nil {
+		return fmt.Errorf("failed to read backup lifetime totals: %w", err)
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO lifetime_totals (id, total_upload, total_download) VALUES (1, 0, 0)`); err != nil {
+		return fmt.Errorf("failed to seed lifetime totals: %w", err)
+	}
+	_, err = tx.Exec(`UPDATE lifetime_totals SET total_upload = MAX(total_upload, ?), total_download = MAX(total_download, ?) WHERE id = 1`,
+		upload, download)
+	if err != nil {
+		return fmt.Errorf("failed to merge lifetime totals: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// sharedColumns returns the columns of table present in both the database and the attached backup.
+// It is empty when the backup has no such table.
+func sharedColumns(tx *sql.Tx, table string) ([]string, error) {
+	columnsOf := func(schema string) ([]string, error) {
+		rows, err := tx.Query(fmt.Sprintf("PRAGMA %s.table_info(%s)", schema, table))
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		var columns []string
+		for rows.Next() {
+			var cid, notNull, pk int
+			var name, colType string
+			var dflt sql.NullString
+			if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+				return nil, err
+			}
+			columns = append(columns, name)
+		}
+		return columns, rows.Err()
+	}
+
+	current, err := columnsOf("main")
+	if err != nil {
+		return nil, err
+	}
+	saved, err := columnsOf("backup")
+	if err != nil {
+		return nil, err
+	}
+	inBackup := make(map[string]bool, len(saved))
+	for _, name := range saved {
+		inBackup[name] = true
+	}
+	var shared []string
+	for _, name := range current {
+		if inBackup[name] {
+			shared = append(shared, name)
+		}
+	}
+	return shared, nil
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckBackupMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	if err := checkBackup(path); err == nil {
+		t.Fatal("checkBackup accepted a missing file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("checkBackup created %s", path)
+	}
+}
+
+func TestRestoreKeepsLifetimeTotals(t *testing.T) {
+	db := newTestDB(t)
+	record := UsageRecord{AppName: "app.exe", UploadBytes: 100, DownloadBytes: 200, Timestamp: time.Now().Unix()}
+	if err := db.BatchInsertUsageRecords([]UsageRecord{record}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	backup, err := db.Backup(t.TempDir())
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := db.BatchInsertUsageRecords([]UsageRecord{record}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	if err := db.RestoreFrom(backup.Path); err != nil {
+		t.Fatalf("RestoreFrom: %v", err)
+	}
+	upload, download, err := db.GetLifetimeTotals()
+	if err != nil {
+		t.Fatalf("GetLifetimeTotals: %v", err)
+	}
+	if upload != 200 || download != 400 {
+		t.Errorf("lifetime totals after restore = %d/%d, want 200/400", upload, download)
+	}
+}
@@ -2,18 +2,72 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+// schemaVersion is the current schema version stored in PRAGMA user_version
+const schemaVersion = 5
+
+// MaxCorruptedBackups is how many corrupted-database backups are kept after a recovery
+const MaxCorruptedBackups = 5
+
+// corruptedBackupLayout is the timestamp suffix of corrupted-database backups
+const corruptedBackupLayout = "20060102_150405"
+
+// ErrReadOnly is returned by write methods on a database opened with OpenReadOnly
+var ErrReadOnly = errors.New("database is open read-only")
+
 // DB represents the database connection
 type DB struct {
-	conn *sql.DB
-	path string
+	conn     *sql.DB
+	path     string
+	report   StartupReport
+	readOnly bool // Opened with OpenReadOnly; write methods fail with ErrReadOnly
+
+	lastVacuum VacuumResult
+	vacuumMux  sync.Mutex
+
+	cleanupHooks []CleanupHook
+	cleanupMux   sync.Mutex // Guards cleanupHooks and serializes DeleteOldRecords runs
+}
+
+// VacuumThreshold is the share of free pages above which VacuumIfNeeded reclaims space
+const VacuumThreshold = 0.10
+
+// VacuumResult describes what VacuumIfNeeded decided and why
+type VacuumResult struct {
+	Mode          string  // "full", "incremental" or "skipped"
+	Fragmentation float64 // Free pages as a fraction of all pages, before vacuuming
+	PageCount     int64
+	FreePages     int64
+	Reason        string
+	CheckedAt     int64 // Unix timestamp
+}
+
+// StartupReport describes what happened while the database was being opened
+type StartupReport struct {
+	RecoveredFromCorruption bool
+	BackupPath              string
+	SalvagedRecords         int64
+	MigrationsApplied       []string
+	SchemaVersion           int
+}
+
+// CorruptedBackup describes a copy of a corrupted database set aside during recovery
+type CorruptedBackup struct {
+	Path      string
+	Size      int64
+	CreatedAt int64 // Unix timestamp parsed from the file name
 }
 
 // UsageRecord represents a network usage record
@@ -21,13 +75,25 @@ type UsageRecord struct {
 	ID            int64
 	AppName       string
 	ProcessID     int
+	Protocol      string // "tcp" or "udp"; empty for records stored before protocols were tracked
+	AddressFamily string // "ipv4" or "ipv6"; empty for records stored before families were tracked
 	UploadBytes   int64
 	DownloadBytes int64
 	Timestamp     int64
 	IsTemporary   bool
 	ExpiresAt     int64
+	SessionLabel  string // Label of the session the record was collected in; empty outside labeled sessions
+	ScreenState   string // ScreenLocked, ScreenUnlocked or ScreenUnknown while the record was collected
+	RecordKey     string // Unique key of a journaled record, so replaying the journal stores it once; may be empty
 }
 
+// Screen states of a UsageRecord. usage_records.screen_locked stores them as 1, 0 and NULL.
+const (
+	ScreenUnknown  = ""
+	ScreenLocked   = "locked"
+	ScreenUnlocked = "unlocked"
+)
+
 // DailySummary represents daily aggregated statistics
 type DailySummary struct {
 	ID            int64
@@ -50,26 +116,81 @@ type AppUsageStat struct {
 	TotalUpload   int64
 	TotalDownload int64
 	LastSeen      int64
+	Color         string // Chart color, filled in from app display settings
+	DisplayLabel  string // Name shown in the UI, filled in from app display settings
+	Tier          string // Usage tier (utils.TierLight, TierMedium or TierHeavy), filled in where classified
+}
+
+// AppDisplay holds user-chosen presentation for an app, keyed by canonical app name
+type AppDisplay struct {
+	AppName      string
+	Color        string // Hex color such as "#3fa7d6"; empty means the derived default
+	DisplayLabel string // Empty means the app name
+}
+
+// ProtocolStat represents aggregated usage for one transport protocol
+type ProtocolStat struct {
+	Protocol      string
+	TotalUpload   int64
+	TotalDownload int64
+}
+
+// AddressFamilyStat represents aggregated usage for one IP address family
+type AddressFamilyStat struct {
+	Family        string
+	TotalUpload   int64
+	TotalDownload int64
+}
+
+// DestinationStat represents aggregated usage for one destination label (e.g. a CDN)
+type DestinationStat struct {
+	Label         string
+	TotalUpload   int64
+	TotalDownload int64
+}
+
+// SpeedTest is one throughput self-test result
+type SpeedTest struct {
+	ID             int64
+	Timestamp      int64 // Unix timestamp the test started
+	URL            string
+	Bytes          int64  // Payload bytes received
+	DurationMs     int64  // Time spent downloading
+	DownloadSpeed  int64  // Bytes per second achieved by the test download
+	InterfaceSpeed int64  // Bytes per second received by all interfaces meanwhile; 0 if unknown
+	Error          string // Why the test failed; empty on success
+}
+
+// HourStat represents aggregated usage for an hour of the day (0-23, local time)
+type HourStat struct {
+	Hour          int
+	TotalUpload   int64
+	TotalDownload int64
 }
 
 // New creates a new database connection
 func New(dbPath string) (*DB, error) {
 	// Ensure directory exists
-	dir := dbPath[:len(dbPath)-len("/netpus.db")]
+	dir := filepath.Dir(dbPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
+	var report StartupReport
+
 	// Check for existing database corruption before opening
 	if _, err := os.Stat(dbPath); err == nil {
 		// Database exists, try to check integrity
 		if err := checkDatabaseIntegrity(dbPath); err != nil {
 			// Database is corrupted, backup and recreate
-			backupPath := dbPath + ".corrupted." + time.Now().Format("20060102_150405")
+			backupPath := dbPath + ".corrupted." + time.Now().Format(corruptedBackupLayout)
 			fmt.Printf("⚠ Database corruption detected! Backing up to: %s\n", backupPath)
 			if copyErr := os.Rename(dbPath, backupPath); copyErr != nil {
 				fmt.Printf("Warning: Could not backup corrupted database: %v\n", copyErr)
+			} else {
+				report.BackupPath = backupPath
 			}
+			report.RecoveredFromCorruption = true
 			// Remove WAL and SHM files if they exist
 			os.Remove(dbPath + "-wal")
 			os.Remove(dbPath + "-shm")
@@ -104,8 +225,9 @@ func New(dbPath string) (*DB, error) {
 	}
 
 	db := &DB{
-		conn: conn,
-		path: dbPath,
+		conn:   conn,
+		path:   dbPath,
+		report: report,
 	}
 
 	// Initialize schema
@@ -114,9 +236,178 @@ func New(dbPath string) (*DB, error) {
 		return nil, err
 	}
 
+	// Try to rescue whatever is still readable from the corrupted copy
+	if report.BackupPath != "" {
+		salvaged, err := db.salvageFrom(report.BackupPath)
+		if err != nil {
+			fmt.Printf("Warning: Could not salvage corrupted database: %v\n", err)
+		}
+		db.report.SalvagedRecords = salvaged
+		if salvaged > 0 {
+			fmt.Printf("✓ Salvaged %d records from corrupted database\n", salvaged)
+		}
+
+		// Repeated recoveries shouldn't fill the disk with old backups
+		if _, err := db.PruneCorruptedBackups(MaxCorruptedBackups); err != nil {
+			fmt.Printf("Warning: Could not prune old corrupted backups: %v\n", err)
+		}
+	}
+
 	return db, nil
 }
 
+// OpenReadOnly opens an existing database without writing to it, for the CLI and external tools
+// that read alongside a running instance. It skips corruption recovery (which renames files) and
+// schema migration, so an older schema is read as-is.
+func OpenReadOnly(dbPath string) (*DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite", readOnlyDSN(dbPath)+"&_pragma=busy_timeout(10000)&_pragma=query_only(1)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn.SetMaxOpenConns(2)
+	conn.SetConnMaxLifetime(time.Hour)
+
+	var version int
+	if err := conn.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+
+	return &DB{
+		conn:     conn,
+		path:     dbPath,
+		report:   StartupReport{SchemaVersion: version},
+		readOnly: true,
+	}, nil
+}
+
+// readOnlyDSN returns a file: URI that opens path read-only and never creates it.
+// The driver only hands query parameters to SQLite for file: URIs; a bare path with
+// ?mode=ro is opened read-write.
+func readOnlyDSN(path string) string {
+	uri := filepath.ToSlash(path)
+	if strings.HasPrefix(uri, "//") {
+		// UNC path: keep the server name out of the URI authority
+		uri = "//" + uri
+	}
+	uri = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(uri)
+	return "file:" + uri + "?mode=ro"
+}
+
+// writable returns ErrReadOnly for databases opened with OpenReadOnly
+func (db *DB) writable() error {
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// salvageFrom copies readable rows from a corrupted database into this one.
+// Rows are read one at a time so everything before the first damaged page is kept.
+func (db *DB) salvageFrom(backupPath string) (int64, error) {
+	src, err := sql.Open("sqlite", readOnlyDSN(backupPath))
+	if err != nil {
+		return 0, fmt.Errorf("cannot open backup: %w", err)
+	}
+	defer src.Close()
+
+	tables := []struct {
+		query  string
+		insert string
+		cols   int
+	}{
+		{
+			query: `SELECT app_name, process_id, upload_bytes, download_bytes, timestamp FROM usage_records`,
+			insert: `INSERT INTO usage_records (app_name, process_id, upload_bytes, download_bytes, timestamp, is_temporary, expires_at)
+			         VALUES (?, ?, ?, ?, ?, 0, 0)`,
+			cols: 5,
+		},
+		{
+			query:  `SELECT date, total_upload, total_download FROM daily_summaries`,
+			insert: `INSERT OR IGNORE INTO daily_summaries (date, total_upload, total_download) VALUES (?, ?, ?)`,
+			cols:   3,
+		},
+		{
+			query:  `SELECT app_name, executable_path, first_seen, last_seen FROM app_metadata`,
+			insert: `INSERT OR IGNORE INTO app_metadata (app_name, executable_path, first_seen, last_seen) VALUES (?, ?, ?, ?)`,
+			cols:   4,
+		},
+		{
+			query:  `SELECT key, value FROM settings`,
+			insert: `INSERT OR IGNORE INTO settings (key, value) VALUES (?, ?)`,
+			cols:   2,
+		},
+	}
+
+	var salvaged int64
+	var firstErr error
+	for _, t := range tables {
+		n, err := db.copyRows(src, t.query, t.insert, t.cols)
+		salvaged += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Lifetime totals were seeded before the summaries came back; never let them fall below those
+	_, err = db.conn.Exec(`UPDATE lifetime_totals SET
+		total_upload = MAX(total_upload, (SELECT COALESCE(SUM(total_upload), 0) FROM daily_summaries)),
+		total_download = MAX(total_download, (SELECT COALESCE(SUM(total_download), 0) FROM daily_summaries))
+		WHERE id = 1`)
+	if err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return salvaged, firstErr
+}
+
+// copyRows copies rows from src into db until the end of the table or the first read error
+func (db *DB) copyRows(src *sql.DB, query, insert string, cols int) (int64, error) {
+	rows, err := src.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(insert)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var copied int64
+	values := make([]interface{}, cols)
+	ptrs := make([]interface{}, cols)
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			break
+		}
+		if _, err := stmt.Exec(values...); err != nil {
+			continue
+		}
+		copied++
+	}
+	readErr := rows.Err()
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return copied, readErr
+}
+
 // checkDatabaseIntegrity checks if database file is corrupted
 func checkDatabaseIntegrity(dbPath string) error {
 	conn, err := sql.Open("sqlite", dbPath)
@@ -175,6 +466,50 @@ func (db *DB) initSchema() error {
 		key TEXT PRIMARY KEY,
 		value TEXT NOT NULL
 	);
+
+	CREATE TABLE IF NOT EXISTS system_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		upload_bytes INTEGER NOT NULL,
+		download_bytes INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_system_timestamp ON system_usage(timestamp);
+
+	CREATE TABLE IF NOT EXISTS lifetime_totals (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		total_upload INTEGER NOT NULL,
+		total_download INTEGER NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS destination_usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		upload_bytes INTEGER NOT NULL,
+		download_bytes INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_destination_timestamp ON destination_usage(timestamp);
+
+	CREATE TABLE IF NOT EXISTS app_display (
+		app_name TEXT PRIMARY KEY,
+		color TEXT NOT NULL DEFAULT '',
+		display_label TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS speed_tests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		bytes INTEGER NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		download_speed INTEGER NOT NULL,
+		interface_speed INTEGER NOT NULL,
+		error TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_speed_tests_timestamp ON speed_tests(timestamp);
 	`
 
 	_, err := db.conn.Exec(schema)
@@ -182,6 +517,13 @@ func (db *DB) initSchema() error {
 		return err
 	}
 
+	// Seed lifetime totals from existing summaries the first time; afterwards only batches add to it
+	seed := `INSERT OR IGNORE INTO lifetime_totals (id, total_upload, total_download)
+	         SELECT 1, COALESCE(SUM(total_upload), 0), COALESCE(SUM(total_download), 0) FROM daily_summaries`
+	if _, err := db.conn.Exec(seed); err != nil {
+		return fmt.Errorf("failed to seed lifetime totals: %w", err)
+	}
+
 	// Perform schema migration to add missing columns
 	if err := db.migrateSchema(); err != nil {
 		return err
@@ -191,9 +533,25 @@ func (db *DB) initSchema() error {
 	indexSchema := `
 	CREATE INDEX IF NOT EXISTS idx_usage_expires ON usage_records(expires_at);
 	CREATE INDEX IF NOT EXISTS idx_usage_temporary ON usage_records(is_temporary);
+	CREATE INDEX IF NOT EXISTS idx_usage_session_label ON usage_records(session_label);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_record_key ON usage_records(record_key);
 	`
-	_, err = db.conn.Exec(indexSchema)
-	return err
+	if _, err = db.conn.Exec(indexSchema); err != nil {
+		return err
+	}
+
+	// Record the schema version so future migrations can be detected
+	if _, err := db.conn.Exec(fmt.Sprintf("PRAGMA user_version = %d", schemaVersion)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+	db.report.SchemaVersion = schemaVersion
+
+	return nil
+}
+
+// StartupReport returns the corruption recovery and migration results from opening the database
+func (db *DB) StartupReport() StartupReport {
+	return db.report
 }
 
 // migrateSchema handles database migrations
@@ -224,6 +582,7 @@ func (db *DB) migrateSchema() error {
 			return fmt.Errorf("failed to add expires_at column: %w", err)
 		}
 		fmt.Println("✓ Database migrated: added expires_at column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add expires_at column")
 	}
 
 	// Add is_temporary column if it doesn't exist
@@ -233,6 +592,57 @@ func (db *DB) migrateSchema() error {
 			return fmt.Errorf("failed to add is_temporary column: %w", err)
 		}
 		fmt.Println("✓ Database migrated: added is_temporary column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add is_temporary column")
+	}
+
+	// Add protocol column if it doesn't exist
+	if !existingColumns["protocol"] {
+		_, err := db.conn.Exec("ALTER TABLE usage_records ADD COLUMN protocol TEXT DEFAULT ''")
+		if err != nil {
+			return fmt.Errorf("failed to add protocol column: %w", err)
+		}
+		fmt.Println("✓ Database migrated: added protocol column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add protocol column")
+	}
+
+	// Add address_family column if it doesn't exist
+	if !existingColumns["address_family"] {
+		_, err := db.conn.Exec("ALTER TABLE usage_records ADD COLUMN address_family TEXT DEFAULT ''")
+		if err != nil {
+			return fmt.Errorf("failed to add address_family column: %w", err)
+		}
+		fmt.Println("✓ Database migrated: added address_family column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add address_family column")
+	}
+
+	// Add session_label column if it doesn't exist; NULL outside labeled sessions
+	if !existingColumns["session_label"] {
+		_, err := db.conn.Exec("ALTER TABLE usage_records ADD COLUMN session_label TEXT")
+		if err != nil {
+			return fmt.Errorf("failed to add session_label column: %w", err)
+		}
+		fmt.Println("✓ Database migrated: added session_label column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add session_label column")
+	}
+
+	// Add screen_locked column if it doesn't exist; NULL when the lock state wasn't known
+	if !existingColumns["screen_locked"] {
+		_, err := db.conn.Exec("ALTER TABLE usage_records ADD COLUMN screen_locked INTEGER")
+		if err != nil {
+			return fmt.Errorf("failed to add screen_locked column: %w", err)
+		}
+		fmt.Println("✓ Database migrated: added screen_locked column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add screen_locked column")
+	}
+
+	// Add record_key column if it doesn't exist; NULL for records stored without a key
+	if !existingColumns["record_key"] {
+		_, err := db.conn.Exec("ALTER TABLE usage_records ADD COLUMN record_key TEXT")
+		if err != nil {
+			return fmt.Errorf("failed to add record_key column: %w", err)
+		}
+		fmt.Println("✓ Database migrated: added record_key column")
+		db.report.MigrationsApplied = append(db.report.MigrationsApplied, "add record_key column")
 	}
 
 	return nil
@@ -240,8 +650,11 @@ func (db *DB) migrateSchema() error {
 
 // InsertUsageRecord inserts a single usage record with retry logic
 func (db *DB) InsertUsageRecord(record UsageRecord) error {
-	query := `INSERT INTO usage_records (app_name, process_id, upload_bytes, download_bytes, timestamp, is_temporary, expires_at)
-	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if err := db.writable(); err != nil {
+		return err
+	}
+	query := `INSERT INTO usage_records (app_name, process_id, protocol, address_family, upload_bytes, download_bytes, timestamp, is_temporary, expires_at, session_label, screen_locked)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	isTemp := 0
 	if record.IsTemporary {
@@ -251,8 +664,9 @@ func (db *DB) InsertUsageRecord(record UsageRecord) error {
 	// Retry with exponential backoff for database lock errors
 	maxRetries := 5
 	for i := 0; i < maxRetries; i++ {
-		_, err := db.conn.Exec(query, record.AppName, record.ProcessID,
-			record.UploadBytes, record.DownloadBytes, record.Timestamp, isTemp, record.ExpiresAt)
+		_, err := db.conn.Exec(query, record.AppName, record.ProcessID, record.Protocol, record.AddressFamily,
+			record.UploadBytes, record.DownloadBytes, record.Timestamp, isTemp, record.ExpiresAt, nullableString(record.SessionLabel),
+			screenLockedValue(record.ScreenState))
 		if err == nil {
 			return nil
 		}
@@ -270,6 +684,25 @@ func (db *DB) InsertUsageRecord(record UsageRecord) error {
 	return fmt.Errorf("failed to insert usage record after retries")
 }
 
+// nullableString stores an empty string as NULL
+func nullableString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// screenLockedValue converts a screen state to its screen_locked value
+func screenLockedValue(state string) interface{} {
+	switch state {
+	case ScreenLocked:
+		return 1
+	case ScreenUnlocked:
+		return 0
+	}
+	return nil
+}
+
 // Helper function to check if string contains substring
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) &&
@@ -288,6 +721,9 @@ func findSubstring(s, substr string) bool {
 
 // BatchInsertUsageRecords inserts multiple usage records in a transaction
 func (db *DB) BatchInsertUsageRecords(records []UsageRecord) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	if len(records) == 0 {
 		return nil
 	}
@@ -306,30 +742,134 @@ func (db *DB) BatchInsertUsageRecords(records []UsageRecord) error {
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`INSERT INTO usage_records
-		(app_name, process_id, upload_bytes, download_bytes, timestamp, is_temporary, expires_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+		(app_name, process_id, protocol, address_family, upload_bytes, download_bytes, timestamp, is_temporary, expires_at, session_label, screen_locked, record_key)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	var upload, download int64
 	for _, record := range records {
 		isTemp := 0
 		if record.IsTemporary {
 			isTemp = 1
 		}
-		_, err := stmt.Exec(record.AppName, record.ProcessID,
-			record.UploadBytes, record.DownloadBytes, record.Timestamp, isTemp, record.ExpiresAt)
+		_, err := stmt.Exec(record.AppName, record.ProcessID, record.Protocol, record.AddressFamily,
+			record.UploadBytes, record.DownloadBytes, record.Timestamp, isTemp, record.ExpiresAt, nullableString(record.SessionLabel),
+			screenLockedValue(record.ScreenState), nullableString(record.RecordKey))
 		if err != nil {
 			return err
 		}
+		upload += record.UploadBytes
+		download += record.DownloadBytes
+	}
+
+	// Lifetime totals move with the batch, so they can't drift from what was stored
+	_, err = tx.Exec(`UPDATE lifetime_totals SET total_upload = total_upload + ?, total_download = total_download + ? WHERE id = 1`,
+		upload, download)
+	if err != nil {
+		return fmt.Errorf("failed to update lifetime totals: %w", err)
 	}
 
 	return tx.Commit()
 }
 
+// InsertMissingUsageRecords inserts only records that aren't already stored and returns the ones it
+// inserted. Records are matched by RecordKey; records without one (journaled by older versions)
+// fall back to matching app, process, timestamp and byte counts. Replaying the same records twice
+// is therefore harmless.
+func (db *DB) InsertMissingUsageRecords(records []UsageRecord) ([]UsageRecord, error) {
+	if err := db.writable(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	keyed, err := tx.Prepare(`INSERT INTO usage_records
+		(app_name, process_id, protocol, address_family, upload_bytes, download_bytes, timestamp, is_temporary, expires_at, session_label, screen_locked, record_key)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM usage_records WHERE record_key = ?)`)
+	if err != nil {
+		return nil, err
+	}
+	defer keyed.Close()
+
+	unkeyed, err := tx.Prepare(`INSERT INTO usage_records
+		(app_name, process_id, protocol, address_family, upload_bytes, download_bytes, timestamp, is_temporary, expires_at, session_label, screen_locked)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM usage_records
+			WHERE app_name = ? AND process_id = ? AND timestamp = ? AND upload_bytes = ? AND download_bytes = ?
+		)`)
+	if err != nil {
+		return nil, err
+	}
+	defer unkeyed.Close()
+
+	var inserted []UsageRecord
+	var upload, download int64
+	for _, record := range records {
+		isTemp := 0
+		if record.IsTemporary {
+			isTemp = 1
+		}
+		var result sql.Result
+		if record.RecordKey != "" {
+			result, err = keyed.Exec(record.AppName, record.ProcessID, record.Protocol, record.AddressFamily,
+				record.UploadBytes, record.DownloadBytes, record.Timestamp, isTemp, record.ExpiresAt, nullableString(record.SessionLabel),
+				screenLockedValue(record.ScreenState), record.RecordKey, record.RecordKey)
+		} else {
+			result, err = unkeyed.Exec(record.AppName, record.ProcessID, record.Protocol, record.AddressFamily,
+				record.UploadBytes, record.DownloadBytes, record.Timestamp, isTemp, record.ExpiresAt, nullableString(record.SessionLabel),
+				screenLockedValue(record.ScreenState),
+				record.AppName, record.ProcessID, record.Timestamp, record.UploadBytes, record.DownloadBytes)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+		inserted = append(inserted, record)
+		upload += record.UploadBytes
+		download += record.DownloadBytes
+	}
+
+	_, err = tx.Exec(`UPDATE lifetime_totals SET total_upload = total_upload + ?, total_download = total_download + ? WHERE id = 1`,
+		upload, download)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update lifetime totals: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// GetLifetimeTotals returns every byte ever stored. Unlike GetAllTimeTotals it survives retention
+// cleanup and clearing data, since it is a running counter.
+func (db *DB) GetLifetimeTotals() (upload, download int64, err error) {
+	err = db.conn.QueryRow(`SELECT total_upload, total_download FROM lifetime_totals WHERE id = 1`).Scan(&upload, &download)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return upload, download, err
+}
+
 // UpdateDailySummary updates or inserts daily summary
 func (db *DB) UpdateDailySummary(date string, upload, download int64) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	query := `INSERT INTO daily_summaries (date, total_upload, total_download)
 	          VALUES (?, ?, ?)
 	          ON CONFLICT(date) DO UPDATE SET
@@ -342,10 +882,14 @@ func (db *DB) UpdateDailySummary(date string, upload, download int64) error {
 
 // UpsertAppMetadata updates or inserts app metadata
 func (db *DB) UpsertAppMetadata(metadata AppMetadata) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	query := `INSERT INTO app_metadata (app_name, executable_path, first_seen, last_seen)
 	          VALUES (?, ?, ?, ?)
 	          ON CONFLICT(app_name) DO UPDATE SET
 	          executable_path = excluded.executable_path,
+	          first_seen = MIN(first_seen, excluded.first_seen),
 	          last_seen = excluded.last_seen`
 
 	_, err := db.conn.Exec(query, metadata.AppName, metadata.ExecutablePath,
@@ -353,73 +897,668 @@ func (db *DB) UpsertAppMetadata(metadata AppMetadata) error {
 	return err
 }
 
-// GetDailySummary retrieves a daily summary for a specific date
-func (db *DB) GetDailySummary(date string) (*DailySummary, error) {
-	query := `SELECT id, date, total_upload, total_download
-	          FROM daily_summaries WHERE date = ?`
+// InsertSystemUsage stores a raw system-wide upload/download delta
+func (db *DB) InsertSystemUsage(timestamp, upload, download int64) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	query := `INSERT INTO system_usage (timestamp, upload_bytes, download_bytes) VALUES (?, ?, ?)`
+	_, err := db.conn.Exec(query, timestamp, upload, download)
+	return err
+}
 
-	var summary DailySummary
-	err := db.conn.QueryRow(query, date).Scan(
-		&summary.ID, &summary.Date, &summary.TotalUpload, &summary.TotalDownload)
-	if err == sql.ErrNoRows {
-		return &DailySummary{Date: date, TotalUpload: 0, TotalDownload: 0}, nil
+// InsertDestinationUsage stores per-destination byte totals for one flush
+func (db *DB) InsertDestinationUsage(timestamp int64, stats []DestinationStat) error {
+	if err := db.writable(); err != nil {
+		return err
 	}
-	if err != nil {
-		return nil, err
+	if len(stats) == 0 {
+		return nil
 	}
-	return &summary, nil
-}
 
-// GetRecentSummaries retrieves the last N days of summaries
-func (db *DB) GetRecentSummaries(days int) ([]DailySummary, error) {
-	query := `SELECT id, date, total_upload, total_download
-	          FROM daily_summaries
-	          ORDER BY date DESC
-	          LIMIT ?`
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	rows, err := db.conn.Query(query, days)
+	stmt, err := tx.Prepare(`INSERT INTO destination_usage (timestamp, label, upload_bytes, download_bytes) VALUES (?, ?, ?, ?)`)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer stmt.Close()
 
-	var summaries []DailySummary
-	for rows.Next() {
-		var s DailySummary
-		if err := rows.Scan(&s.ID, &s.Date, &s.TotalUpload, &s.TotalDownload); err != nil {
-			return nil, err
+	for _, stat := range stats {
+		if _, err := stmt.Exec(timestamp, stat.Label, stat.TotalUpload, stat.TotalDownload); err != nil {
+			return err
 		}
-		summaries = append(summaries, s)
 	}
-	return summaries, rows.Err()
+	return tx.Commit()
 }
 
-// GetAppMetadata retrieves metadata for a specific app
-func (db *DB) GetAppMetadata(appName string) (*AppMetadata, error) {
-	query := `SELECT app_name, executable_path, first_seen, last_seen
+// GetUsageByDestination retrieves total usage per destination label over the last N days (0 means all time)
+func (db *DB) GetUsageByDestination(days int) ([]DestinationStat, error) {
+	var startTime int64
+	if days > 0 {
+		startTime = time.Now().AddDate(0, 0, -days).Unix()
+	}
+
+	query := `SELECT label,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download
+	          FROM destination_usage
+	          WHERE timestamp >= ?
+	          GROUP BY label
+	          ORDER BY (total_upload + total_download) DESC`
+
+	rows, err := db.conn.Query(query, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []DestinationStat
+	for rows.Next() {
+		var s DestinationStat
+		if err := rows.Scan(&s.Label, &s.TotalUpload, &s.TotalDownload); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// InsertSpeedTest stores a speed test result
+func (db *DB) InsertSpeedTest(test SpeedTest) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	query := `INSERT INTO speed_tests (timestamp, url, bytes, duration_ms, download_speed, interface_speed, error)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.conn.Exec(query, test.Timestamp, test.URL, test.Bytes, test.DurationMs,
+		test.DownloadSpeed, test.InterfaceSpeed, test.Error)
+	return err
+}
+
+// GetSpeedTests retrieves the most recent speed test results, newest first
+func (db *DB) GetSpeedTests(limit int) ([]SpeedTest, error) {
+	query := `SELECT id, timestamp, url, bytes, duration_ms, download_speed, interface_speed, error
+	          FROM speed_tests
+	          ORDER BY timestamp DESC, id DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tests []SpeedTest
+	for rows.Next() {
+		var t SpeedTest
+		if err := rows.Scan(&t.ID, &t.Timestamp, &t.URL, &t.Bytes, &t.DurationMs,
+			&t.DownloadSpeed, &t.InterfaceSpeed, &t.Error); err != nil {
+			return nil, err
+		}
+		tests = append(tests, t)
+	}
+	return tests, rows.Err()
+}
+
+// GetSystemUsage retrieves raw system-wide totals within a time range
+func (db *DB) GetSystemUsage(startTime, endTime int64) (map[string]int64, error) {
+	query := `SELECT SUM(upload_bytes), SUM(download_bytes)
+	          FROM system_usage
+	          WHERE timestamp BETWEEN ? AND ?`
+
+	var upload, download sql.NullInt64
+	err := db.conn.QueryRow(query, startTime, endTime).Scan(&upload, &download)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]int64{
+		"upload":   upload.Int64,
+		"download": download.Int64,
+	}, nil
+}
+
+// GetDailySummary retrieves a daily summary for a specific date
+func (db *DB) GetDailySummary(date string) (*DailySummary, error) {
+	query := `SELECT id, date, total_upload, total_download
+	          FROM daily_summaries WHERE date = ?`
+
+	var summary DailySummary
+	err := db.conn.QueryRow(query, date).Scan(
+		&summary.ID, &summary.Date, &summary.TotalUpload, &summary.TotalDownload)
+	if err == sql.ErrNoRows {
+		return &DailySummary{Date: date, TotalUpload: 0, TotalDownload: 0}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// GetRecentSummaries retrieves summaries for the last N calendar days (including today),
+// newest first, with zero-filled entries for days without traffic
+func (db *DB) GetRecentSummaries(days int) ([]DailySummary, error) {
+	if days <= 0 {
+		return []DailySummary{}, nil
+	}
+
+	today := time.Now()
+	startDate := today.AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+
+	query := `SELECT id, date, total_upload, total_download
+	          FROM daily_summaries
+	          WHERE date >= ?
+	          ORDER BY date DESC`
+
+	rows, err := db.conn.Query(query, startDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byDate := make(map[string]DailySummary)
+	for rows.Next() {
+		var s DailySummary
+		if err := rows.Scan(&s.ID, &s.Date, &s.TotalUpload, &s.TotalDownload); err != nil {
+			return nil, err
+		}
+		byDate[s.Date] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summaries := make([]DailySummary, 0, days)
+	for i := 0; i < days; i++ {
+		date := today.AddDate(0, 0, -i).Format("2006-01-02")
+		if s, ok := byDate[date]; ok {
+			summaries = append(summaries, s)
+		} else {
+			summaries = append(summaries, DailySummary{Date: date})
+		}
+	}
+	return summaries, nil
+}
+
+// GetSummaryTotals sums daily summaries for dates in [startDate, endDate] ("2006-01-02")
+func (db *DB) GetSummaryTotals(startDate, endDate string) (map[string]int64, error) {
+	query := `SELECT SUM(total_upload), SUM(total_download)
+	          FROM daily_summaries
+	          WHERE date BETWEEN ? AND ?`
+
+	var upload, download sql.NullInt64
+	err := db.conn.QueryRow(query, startDate, endDate).Scan(&upload, &download)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]int64{
+		"upload":   upload.Int64,
+		"download": download.Int64,
+	}, nil
+}
+
+// GetAllTimeTotals sums every daily summary. It reads one row per day instead of scanning
+// usage_records, so it stays fast on large databases.
+func (db *DB) GetAllTimeTotals() (upload, download int64, err error) {
+	var up, down sql.NullInt64
+	err = db.conn.QueryRow(`SELECT SUM(total_upload), SUM(total_download) FROM daily_summaries`).Scan(&up, &down)
+	if err != nil {
+		return 0, 0, err
+	}
+	return up.Int64, down.Int64, nil
+}
+
+// GetAppMetadata retrieves metadata for a specific app
+func (db *DB) GetAppMetadata(appName string) (*AppMetadata, error) {
+	query := `SELECT app_name, executable_path, first_seen, last_seen
 	          FROM app_metadata WHERE app_name = ?`
 
-	var meta AppMetadata
-	err := db.conn.QueryRow(query, appName).Scan(
-		&meta.AppName, &meta.ExecutablePath, &meta.FirstSeen, &meta.LastSeen)
+	var meta AppMetadata
+	err := db.conn.QueryRow(query, appName).Scan(
+		&meta.AppName, &meta.ExecutablePath, &meta.FirstSeen, &meta.LastSeen)
+	if err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// MergeApps folds every record of app from into app into, in one transaction: usage records are
+// reassigned and metadata is combined (earliest first_seen, latest last_seen). Daily summaries
+// hold per-day totals across all apps, so a merge leaves them unchanged. Both apps must exist.
+func (db *DB) MergeApps(from, into string) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	if from == "" || into == "" {
+		return fmt.Errorf("both app names are required")
+	}
+	if from == into {
+		return fmt.Errorf("cannot merge %q into itself", from)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists := func(app string) (bool, error) {
+		var found int
+		err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM app_metadata WHERE app_name = ?)
+		                    OR EXISTS(SELECT 1 FROM usage_records WHERE app_name = ?)`, app, app).Scan(&found)
+		return found == 1, err
+	}
+	for _, app := range []string{from, into} {
+		found, err := exists(app)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("app not found: %s", app)
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE usage_records SET app_name = ? WHERE app_name = ?`, into, from); err != nil {
+		return fmt.Errorf("failed to reassign usage records: %w", err)
+	}
+
+	// Combine metadata: copy from's row into into (creating it if missing), then drop from's
+	_, err = tx.Exec(`INSERT INTO app_metadata (app_name, executable_path, first_seen, last_seen)
+		SELECT ?, executable_path, first_seen, last_seen FROM app_metadata WHERE app_name = ?
+		ON CONFLICT(app_name) DO UPDATE SET
+		first_seen = MIN(first_seen, excluded.first_seen),
+		last_seen = MAX(last_seen, excluded.last_seen)`, into, from)
+	if err != nil {
+		return fmt.Errorf("failed to merge app metadata: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM app_metadata WHERE app_name = ?`, from); err != nil {
+		return fmt.Errorf("failed to remove merged app metadata: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ResetAppStats deletes every usage record of an app, in one transaction, and returns how many were
+// removed. Its share is subtracted from the daily summaries of the days it covered, while its
+// metadata is kept with first_seen restarted at now. Lifetime totals are a running counter and
+// are left unchanged. The app must exist.
+func (db *DB) ResetAppStats(appName string) (int64, error) {
+	if err := db.writable(); err != nil {
+		return 0, err
+	}
+	if appName == "" {
+		return 0, fmt.Errorf("app name is required")
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM app_metadata WHERE app_name = ?)
+	                   OR EXISTS(SELECT 1 FROM usage_records WHERE app_name = ?)`, appName, appName).Scan(&found)
+	if err != nil {
+		return 0, err
+	}
+	if found != 1 {
+		return 0, fmt.Errorf("app not found: %s", appName)
+	}
+
+	// Summaries are keyed by each record's local date, as flushBatch credits them
+	_, err = tx.Exec(`UPDATE daily_summaries SET
+		total_upload = MAX(0, total_upload - share.upload),
+		total_download = MAX(0, total_download - share.download)
+		FROM (SELECT date(timestamp, 'unixepoch', 'localtime') as day,
+		             SUM(upload_bytes) as upload, SUM(download_bytes) as download
+		      FROM usage_records WHERE app_name = ?
+		      GROUP BY day) AS share
+		WHERE daily_summaries.date = share.day`, appName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update daily summaries: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM usage_records WHERE app_name = ?`, appName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete usage records: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`UPDATE app_metadata SET first_seen = ?, last_seen = ? WHERE app_name = ?`, now, now, appName); err != nil {
+		return 0, fmt.Errorf("failed to reset app metadata: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// SetAppDisplay stores an app's color and label. Clearing both removes the entry.
+func (db *DB) SetAppDisplay(display AppDisplay) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	if display.Color == "" && display.DisplayLabel == "" {
+		_, err := db.conn.Exec(`DELETE FROM app_display WHERE app_name = ?`, display.AppName)
+		return err
+	}
+	query := `INSERT INTO app_display (app_name, color, display_label) VALUES (?, ?, ?)
+	          ON CONFLICT(app_name) DO UPDATE SET
+	          color = excluded.color,
+	          display_label = excluded.display_label`
+	_, err := db.conn.Exec(query, display.AppName, display.Color, display.DisplayLabel)
+	return err
+}
+
+// GetAppDisplays retrieves all stored app display settings, keyed by app name
+func (db *DB) GetAppDisplays() (map[string]AppDisplay, error) {
+	rows, err := db.conn.Query(`SELECT app_name, color, display_label FROM app_display`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	displays := make(map[string]AppDisplay)
+	for rows.Next() {
+		var d AppDisplay
+		if err := rows.Scan(&d.AppName, &d.Color, &d.DisplayLabel); err != nil {
+			return nil, err
+		}
+		displays[d.AppName] = d
+	}
+	return displays, rows.Err()
+}
+
+// GetAppsFirstSeenSince retrieves apps whose first network activity was at or after the given timestamp
+func (db *DB) GetAppsFirstSeenSince(since int64) ([]AppMetadata, error) {
+	query := `SELECT app_name, COALESCE(executable_path, ''), first_seen, last_seen
+	          FROM app_metadata
+	          WHERE first_seen >= ?
+	          ORDER BY first_seen DESC`
+
+	rows, err := db.conn.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []AppMetadata
+	for rows.Next() {
+		var meta AppMetadata
+		if err := rows.Scan(&meta.AppName, &meta.ExecutablePath, &meta.FirstSeen, &meta.LastSeen); err != nil {
+			return nil, err
+		}
+		apps = append(apps, meta)
+	}
+	return apps, rows.Err()
+}
+
+// GetAppUsageStats retrieves aggregated usage statistics for all apps
+func (db *DB) GetAppUsageStats(startTime, endTime int64) ([]AppUsageStat, error) {
+	query := `SELECT app_name,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download,
+	          MAX(timestamp) as last_seen
+	          FROM usage_records
+	          WHERE timestamp BETWEEN ? AND ?
+	          GROUP BY app_name
+	          ORDER BY (total_upload + total_download) DESC`
+
+	rows, err := db.conn.Query(query, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []AppUsageStat
+	for rows.Next() {
+		var s AppUsageStat
+		if err := rows.Scan(&s.AppName, &s.TotalUpload, &s.TotalDownload, &s.LastSeen); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetAppUsageForApps retrieves usage statistics within a time range for only the given apps.
+// names are canonical app names (lowercase, without ".exe"); no names yields no statistics.
+func (db *DB) GetAppUsageForApps(names []string, startTime, endTime int64) ([]AppUsageStat, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{startTime, endTime}
+	for _, name := range names {
+		args = append(args, name)
+	}
+	query := `SELECT app_name,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download,
+	          MAX(timestamp) as last_seen
+	          FROM usage_records
+	          WHERE timestamp BETWEEN ? AND ?
+	          AND ` + canonicalAppNameSQL + ` IN (?` + strings.Repeat(", ?", len(names)-1) + `)
+	          GROUP BY app_name
+	          ORDER BY (total_upload + total_download) DESC`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []AppUsageStat
+	for rows.Next() {
+		var s AppUsageStat
+		if err := rows.Scan(&s.AppName, &s.TotalUpload, &s.TotalDownload, &s.LastSeen); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetUsageByLabel retrieves per-app usage for records collected during sessions with the given label
+func (db *DB) GetUsageByLabel(label string) ([]AppUsageStat, error) {
+	query := `SELECT app_name,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download,
+	          MAX(timestamp) as last_seen
+	          FROM usage_records
+	          WHERE session_label = ?
+	          GROUP BY app_name
+	          ORDER BY (total_upload + total_download) DESC`
+
+	rows, err := db.conn.Query(query, label)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []AppUsageStat
+	for rows.Next() {
+		var s AppUsageStat
+		if err := rows.Scan(&s.AppName, &s.TotalUpload, &s.TotalDownload, &s.LastSeen); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// ScreenUsageStat splits an app's usage by whether the screen was locked while it was collected
+type ScreenUsageStat struct {
+	AppName         string
+	LockedUpload    int64
+	LockedDownload  int64
+	ActiveUpload    int64
+	ActiveDownload  int64
+	UnknownUpload   int64 // Collected before lock tracking, or while the state couldn't be read
+	UnknownDownload int64
+}
+
+// GetUsageByScreenState retrieves per-app usage within a time range split by screen lock state,
+// ordered by traffic while locked
+func (db *DB) GetUsageByScreenState(startTime, endTime int64) ([]ScreenUsageStat, error) {
+	query := `SELECT app_name,
+	          SUM(CASE WHEN screen_locked = 1 THEN upload_bytes ELSE 0 END) as locked_upload,
+	          SUM(CASE WHEN screen_locked = 1 THEN download_bytes ELSE 0 END) as locked_download,
+	          SUM(CASE WHEN screen_locked = 0 THEN upload_bytes ELSE 0 END) as active_upload,
+	          SUM(CASE WHEN screen_locked = 0 THEN download_bytes ELSE 0 END) as active_download,
+	          SUM(CASE WHEN screen_locked IS NULL THEN upload_bytes ELSE 0 END) as unknown_upload,
+	          SUM(CASE WHEN screen_locked IS NULL THEN download_bytes ELSE 0 END) as unknown_download
+	          FROM usage_records
+	          WHERE timestamp >= ? AND timestamp <= ?
+	          GROUP BY app_name
+	          ORDER BY (locked_upload + locked_download) DESC, (active_upload + active_download) DESC`
+
+	rows, err := db.conn.Query(query, startTime, endTime)
 	if err != nil {
 		return nil, err
 	}
-	return &meta, nil
+	defer rows.Close()
+
+	var stats []ScreenUsageStat
+	for rows.Next() {
+		var s ScreenUsageStat
+		if err := rows.Scan(&s.AppName, &s.LockedUpload, &s.LockedDownload, &s.ActiveUpload, &s.ActiveDownload,
+			&s.UnknownUpload, &s.UnknownDownload); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
 }
 
-// GetAppUsageStats retrieves aggregated usage statistics for all apps
-func (db *DB) GetAppUsageStats(startTime, endTime int64) ([]AppUsageStat, error) {
+// canonicalAppNameSQL mirrors utils.CanonicalAppName (lowercase, trimmed, ".exe" optional) in SQL
+const canonicalAppNameSQL = `CASE WHEN LOWER(TRIM(app_name)) LIKE '%.exe'
+	THEN SUBSTR(LOWER(TRIM(app_name)), 1, LENGTH(TRIM(app_name)) - 4)
+	ELSE LOWER(TRIM(app_name)) END`
+
+// GetUsageBreakdownWithOther retrieves the top apps within a time range plus an aggregate of all
+// remaining apps, so the top entries and the other bucket always add up to the grand total.
+// excluded lists canonical app names left out of both. otherApps is how many apps the bucket covers.
+func (db *DB) GetUsageBreakdownWithOther(startTime, endTime int64, limit int, excluded []string) (top []AppUsageStat, other AppUsageStat, otherApps int, err error) {
+	args := []interface{}{startTime, endTime}
+	exclusion := ""
+	if len(excluded) > 0 {
+		exclusion = " AND " + canonicalAppNameSQL + " NOT IN (?" + strings.Repeat(", ?", len(excluded)-1) + ")"
+		for _, name := range excluded {
+			args = append(args, name)
+		}
+	}
+	args = append(args, limit, limit)
+
+	query := `WITH per_app AS (
+	              SELECT app_name,
+	              SUM(upload_bytes) as total_upload,
+	              SUM(download_bytes) as total_download,
+	              MAX(timestamp) as last_seen
+	              FROM usage_records
+	              WHERE timestamp BETWEEN ? AND ?` + exclusion + `
+	              GROUP BY app_name
+	          ), ranked AS (
+	              SELECT *, ROW_NUMBER() OVER (ORDER BY (total_upload + total_download) DESC, app_name) as app_rank
+	              FROM per_app
+	          )
+	          SELECT CASE WHEN app_rank <= ? THEN app_rank ELSE ? + 1 END as bucket,
+	          MIN(app_name), SUM(total_upload), SUM(total_download), MAX(last_seen), COUNT(*)
+	          FROM ranked
+	          GROUP BY bucket
+	          ORDER BY bucket`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, AppUsageStat{}, 0, err
+	}
+	defer rows.Close()
+
+	other = AppUsageStat{AppName: "Other"}
+	for rows.Next() {
+		var bucket, count int
+		var s AppUsageStat
+		if err := rows.Scan(&bucket, &s.AppName, &s.TotalUpload, &s.TotalDownload, &s.LastSeen, &count); err != nil {
+			return nil, AppUsageStat{}, 0, err
+		}
+		if bucket > limit {
+			other.TotalUpload = s.TotalUpload
+			other.TotalDownload = s.TotalDownload
+			other.LastSeen = s.LastSeen
+			otherApps = count
+			continue
+		}
+		top = append(top, s)
+	}
+	return top, other, otherApps, rows.Err()
+}
+
+// SearchApps retrieves aggregated usage statistics for apps whose name matches the query
+func (db *DB) SearchApps(query string, limit int) ([]AppUsageStat, error) {
+	// Escape LIKE wildcards so user input is matched literally
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query)
+	pattern := "%" + escaped + "%"
+
+	sqlQuery := `SELECT app_name,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download,
+	          MAX(timestamp) as last_seen
+	          FROM usage_records
+	          WHERE app_name LIKE ? ESCAPE '\'
+	          GROUP BY app_name
+	          ORDER BY (total_upload + total_download) DESC
+	          LIMIT ?`
+
+	rows, err := db.conn.Query(sqlQuery, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []AppUsageStat
+	for rows.Next() {
+		var s AppUsageStat
+		if err := rows.Scan(&s.AppName, &s.TotalUpload, &s.TotalDownload, &s.LastSeen); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetTopByDirection retrieves the top apps over the last N days ordered by upload or download alone.
+// direction must be "upload" or "download".
+func (db *DB) GetTopByDirection(days, limit int, direction string) ([]AppUsageStat, error) {
+	var orderBy string
+	switch direction {
+	case "upload":
+		orderBy = "total_upload"
+	case "download":
+		orderBy = "total_download"
+	default:
+		return nil, fmt.Errorf("invalid direction: %q (expected \"upload\" or \"download\")", direction)
+	}
+
+	startTime := time.Now().AddDate(0, 0, -days).Unix()
 	query := `SELECT app_name,
 	          SUM(upload_bytes) as total_upload,
 	          SUM(download_bytes) as total_download,
 	          MAX(timestamp) as last_seen
 	          FROM usage_records
-	          WHERE timestamp BETWEEN ? AND ?
+	          WHERE timestamp >= ?
 	          GROUP BY app_name
-	          ORDER BY (total_upload + total_download) DESC`
+	          ORDER BY ` + orderBy + ` DESC
+	          LIMIT ?`
 
-	rows, err := db.conn.Query(query, startTime, endTime)
+	rows, err := db.conn.Query(query, startTime, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -436,6 +1575,73 @@ func (db *DB) GetAppUsageStats(startTime, endTime int64) ([]AppUsageStat, error)
 	return stats, rows.Err()
 }
 
+// GetUsageByAppAndProtocol retrieves an app's totals per protocol over the last N days (0 means all time).
+// Only protocols the app actually used are returned; records stored before protocols were tracked
+// are grouped under an empty protocol.
+func (db *DB) GetUsageByAppAndProtocol(appName string, days int) ([]ProtocolStat, error) {
+	var startTime int64
+	if days > 0 {
+		startTime = time.Now().AddDate(0, 0, -days).Unix()
+	}
+
+	query := `SELECT COALESCE(protocol, '') as proto,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download
+	          FROM usage_records
+	          WHERE app_name = ? AND timestamp >= ?
+	          GROUP BY proto
+	          ORDER BY (total_upload + total_download) DESC`
+
+	rows, err := db.conn.Query(query, appName, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []ProtocolStat
+	for rows.Next() {
+		var s ProtocolStat
+		if err := rows.Scan(&s.Protocol, &s.TotalUpload, &s.TotalDownload); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetUsageByAddressFamily retrieves total usage per IP address family over the last N days
+// (0 means all time). Records stored before families were tracked are grouped under an empty family.
+func (db *DB) GetUsageByAddressFamily(days int) ([]AddressFamilyStat, error) {
+	var startTime int64
+	if days > 0 {
+		startTime = time.Now().AddDate(0, 0, -days).Unix()
+	}
+
+	query := `SELECT COALESCE(address_family, '') as family,
+	          SUM(upload_bytes) as total_upload,
+	          SUM(download_bytes) as total_download
+	          FROM usage_records
+	          WHERE timestamp >= ?
+	          GROUP BY family
+	          ORDER BY (total_upload + total_download) DESC`
+
+	rows, err := db.conn.Query(query, startTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []AddressFamilyStat
+	for rows.Next() {
+		var s AddressFamilyStat
+		if err := rows.Scan(&s.Family, &s.TotalUpload, &s.TotalDownload); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
 // GetAppUsageWithRetention retrieves app usage stats based on retention period
 func (db *DB) GetAppUsageWithRetention(days int) ([]AppUsageStat, error) {
 	var startTime int64
@@ -448,9 +1654,23 @@ func (db *DB) GetAppUsageWithRetention(days int) ([]AppUsageStat, error) {
 	return db.GetAppUsageStats(startTime, endTime)
 }
 
+// GetAppUsageHours retrieves app usage stats for a rolling window of the last N hours
+func (db *DB) GetAppUsageHours(hours int) ([]AppUsageStat, error) {
+	if hours <= 0 {
+		return nil, fmt.Errorf("invalid window: %d hours", hours)
+	}
+	endTime := time.Now().Unix()
+	startTime := endTime - int64(hours)*3600
+	return db.GetAppUsageStats(startTime, endTime)
+}
+
 // Get24HourUsage retrieves total usage for the last 24 hours
 func (db *DB) Get24HourUsage() (map[string]int64, error) {
-	startTime := time.Now().Add(-24 * time.Hour).Unix()
+	return db.GetUsageSince(time.Now().Add(-24 * time.Hour).Unix())
+}
+
+// GetUsageSince retrieves total upload and download since the given timestamp
+func (db *DB) GetUsageSince(startTime int64) (map[string]int64, error) {
 	query := `SELECT SUM(upload_bytes), SUM(download_bytes)
 	          FROM usage_records
 	          WHERE timestamp >= ?`
@@ -467,9 +1687,62 @@ func (db *DB) Get24HourUsage() (map[string]int64, error) {
 	}, nil
 }
 
+// GetBusiestPeriods returns the hour of day and the day with the most traffic over the last N days.
+// Zero-value stats are returned when there is no data.
+func (db *DB) GetBusiestPeriods(days int) (HourStat, DailySummary, error) {
+	var busiestHour HourStat
+	var busiestDay DailySummary
+
+	startTime := time.Now().AddDate(0, 0, -days).Unix()
+	hourQuery := `SELECT CAST(strftime('%H', timestamp, 'unixepoch', 'localtime') AS INTEGER) as hour,
+	              SUM(upload_bytes) as total_upload,
+	              SUM(download_bytes) as total_download
+	              FROM usage_records
+	              WHERE timestamp >= ?
+	              GROUP BY hour
+	              ORDER BY (total_upload + total_download) DESC
+	              LIMIT 1`
+
+	err := db.conn.QueryRow(hourQuery, startTime).Scan(
+		&busiestHour.Hour, &busiestHour.TotalUpload, &busiestHour.TotalDownload)
+	if err != nil && err != sql.ErrNoRows {
+		return HourStat{}, DailySummary{}, err
+	}
+
+	startDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	dayQuery := `SELECT id, date, total_upload, total_download
+	             FROM daily_summaries
+	             WHERE date >= ?
+	             ORDER BY (total_upload + total_download) DESC
+	             LIMIT 1`
+
+	err = db.conn.QueryRow(dayQuery, startDate).Scan(
+		&busiestDay.ID, &busiestDay.Date, &busiestDay.TotalUpload, &busiestDay.TotalDownload)
+	if err != nil && err != sql.ErrNoRows {
+		return HourStat{}, DailySummary{}, err
+	}
+
+	return busiestHour, busiestDay, nil
+}
+
+// usageRecordColumns selects every UsageRecord field, in scanUsageRecord's order
+const usageRecordColumns = `id, app_name, process_id, COALESCE(protocol, ''), COALESCE(address_family, ''),
+	upload_bytes, download_bytes, timestamp, is_temporary, COALESCE(expires_at, 0), COALESCE(session_label, ''),
+	CASE screen_locked WHEN 1 THEN 'locked' WHEN 0 THEN 'unlocked' ELSE '' END`
+
+// scanUsageRecord reads one row selected with usageRecordColumns
+func scanUsageRecord(rows *sql.Rows) (UsageRecord, error) {
+	var r UsageRecord
+	var isTemp int
+	err := rows.Scan(&r.ID, &r.AppName, &r.ProcessID, &r.Protocol, &r.AddressFamily,
+		&r.UploadBytes, &r.DownloadBytes, &r.Timestamp, &isTemp, &r.ExpiresAt, &r.SessionLabel, &r.ScreenState)
+	r.IsTemporary = isTemp == 1
+	return r, err
+}
+
 // GetUsageByTimeRange retrieves records within a specific time range
 func (db *DB) GetUsageByTimeRange(startTime, endTime int64) ([]UsageRecord, error) {
-	query := `SELECT id, app_name, process_id, upload_bytes, download_bytes, timestamp, is_temporary, COALESCE(expires_at, 0)
+	query := `SELECT ` + usageRecordColumns + `
 	          FROM usage_records
 	          WHERE timestamp BETWEEN ? AND ?
 	          ORDER BY timestamp DESC`
@@ -482,40 +1755,138 @@ func (db *DB) GetUsageByTimeRange(startTime, endTime int64) ([]UsageRecord, erro
 
 	var records []UsageRecord
 	for rows.Next() {
-		var r UsageRecord
-		var isTemp int
-		if err := rows.Scan(&r.ID, &r.AppName, &r.ProcessID,
-			&r.UploadBytes, &r.DownloadBytes, &r.Timestamp, &isTemp, &r.ExpiresAt); err != nil {
+		r, err := scanUsageRecord(rows)
+		if err != nil {
 			return nil, err
 		}
-		r.IsTemporary = isTemp == 1
 		records = append(records, r)
 	}
 	return records, rows.Err()
 }
 
-// DeleteOldRecords deletes records older than the specified timestamp
-func (db *DB) DeleteOldRecords(beforeTimestamp int64) error {
+// IterateUsageRecords streams records with timestamps in [startTime, endTime], oldest first,
+// calling fn for each one without loading them all into memory. It stops at and returns the
+// first error from fn.
+func (db *DB) IterateUsageRecords(startTime, endTime int64, fn func(UsageRecord) error) error {
+	query := `SELECT ` + usageRecordColumns + `
+	          FROM usage_records
+	          WHERE timestamp BETWEEN ? AND ?
+	          ORDER BY timestamp, id`
+
+	rows, err := db.conn.Query(query, startTime, endTime)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanUsageRecord(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// CleanupHook runs before retention cleanup deletes records older than cutoff (a Unix timestamp),
+// e.g. to archive them first
+type CleanupHook func(cutoff int64) error
+
+// RegisterCleanupHook adds a hook to every later DeleteOldRecords run. Hooks run one at a time in
+// registration order, after the previous run has finished and before anything is deleted. A hook
+// that fails is logged and the remaining hooks and the cleanup still run. Hooks must not call
+// DeleteOldRecords or RegisterCleanupHook.
+func (db *DB) RegisterCleanupHook(hook CleanupHook) {
+	db.cleanupMux.Lock()
+	db.cleanupHooks = append(db.cleanupHooks, hook)
+	db.cleanupMux.Unlock()
+}
+
+// runCleanupHooks runs the registered hooks in order. Called with cleanupMux held.
+func (db *DB) runCleanupHooks(cutoff int64) {
+	for i, hook := range db.cleanupHooks {
+		if err := hook(cutoff); err != nil {
+			fmt.Printf("Cleanup hook %d failed: %v\n", i+1, err)
+		}
+	}
+}
+
+// DeleteOldRecords deletes usage records and system totals older than beforeTimestamp and daily
+// summaries older than summariesBefore, returning how many usage records were removed. A
+// summariesBefore of 0 keeps summaries forever. Destination totals have their own retention; see
+// DeleteOldDestinationUsage. Registered cleanup hooks run first, with beforeTimestamp as their cutoff.
+func (db *DB) DeleteOldRecords(beforeTimestamp, summariesBefore int64) (int64, error) {
+	if err := db.writable(); err != nil {
+		return 0, err
+	}
+	db.cleanupMux.Lock()
+	defer db.cleanupMux.Unlock()
+	db.runCleanupHooks(beforeTimestamp)
+
 	// Delete old usage records
 	query := `DELETE FROM usage_records WHERE timestamp < ? AND is_temporary = 0`
-	_, err := db.conn.Exec(query, beforeTimestamp)
+	result, err := db.conn.Exec(query, beforeTimestamp)
 	if err != nil {
-		return fmt.Errorf("failed to delete old usage records: %w", err)
+		return 0, fmt.Errorf("failed to delete old usage records: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	// Delete old raw system totals
+	if _, err := db.conn.Exec(`DELETE FROM system_usage WHERE timestamp < ?`, beforeTimestamp); err != nil {
+		return deleted, fmt.Errorf("failed to delete old system usage: %w", err)
 	}
 
-	// Also delete old daily summaries based on the same cutoff date
-	cutoffDate := time.Unix(beforeTimestamp, 0).Format("2006-01-02")
+	// Daily summaries have their own cutoff, so long-term history can outlive detailed records
+	if summariesBefore <= 0 {
+		return deleted, nil
+	}
+	cutoffDate := time.Unix(summariesBefore, 0).Format("2006-01-02")
 	summaryQuery := `DELETE FROM daily_summaries WHERE date < ?`
 	_, err = db.conn.Exec(summaryQuery, cutoffDate)
 	if err != nil {
-		return fmt.Errorf("failed to delete old daily summaries: %w", err)
+		return deleted, fmt.Errorf("failed to delete old daily summaries: %w", err)
 	}
 
-	return nil
+	return deleted, nil
+}
+
+// DeleteOldDestinationUsage deletes destination totals older than beforeTimestamp and returns how
+// many were removed. The delete is driven by idx_destination_timestamp.
+func (db *DB) DeleteOldDestinationUsage(beforeTimestamp int64) (int64, error) {
+	if err := db.writable(); err != nil {
+		return 0, err
+	}
+	result, err := db.conn.Exec(`DELETE FROM destination_usage WHERE timestamp < ?`, beforeTimestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old destination usage: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// GetDestinationRecordCount returns the number of stored destination totals
+func (db *DB) GetDestinationRecordCount() (int64, error) {
+	var count int64
+	err := db.conn.QueryRow("SELECT COUNT(*) FROM destination_usage").Scan(&count)
+	return count, err
+}
+
+// CountOldRecords returns how many records DeleteOldRecords would remove for the given cutoff
+func (db *DB) CountOldRecords(beforeTimestamp int64) (int64, error) {
+	var count int64
+	query := `SELECT COUNT(*) FROM usage_records WHERE timestamp < ? AND is_temporary = 0`
+	err := db.conn.QueryRow(query, beforeTimestamp).Scan(&count)
+	return count, err
 }
 
 // ClearAllData clears all usage records and daily summaries from the database
 func (db *DB) ClearAllData() error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	// Clear all usage records
 	if _, err := db.conn.Exec("DELETE FROM usage_records"); err != nil {
 		return fmt.Errorf("failed to clear usage records: %w", err)
@@ -526,11 +1897,24 @@ func (db *DB) ClearAllData() error {
 		return fmt.Errorf("failed to clear daily summaries: %w", err)
 	}
 
+	// Clear all raw system totals
+	if _, err := db.conn.Exec("DELETE FROM system_usage"); err != nil {
+		return fmt.Errorf("failed to clear system usage: %w", err)
+	}
+
+	// Clear all destination totals
+	if _, err := db.conn.Exec("DELETE FROM destination_usage"); err != nil {
+		return fmt.Errorf("failed to clear destination usage: %w", err)
+	}
+
 	return nil
 }
 
 // DeleteExpiredRecords deletes records that have passed their expiration time
 func (db *DB) DeleteExpiredRecords() error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	now := time.Now().Unix()
 	query := `DELETE FROM usage_records WHERE expires_at > 0 AND expires_at < ?`
 	result, err := db.conn.Exec(query, now)
@@ -548,6 +1932,9 @@ func (db *DB) DeleteExpiredRecords() error {
 
 // DeleteTemporaryRecords deletes all temporary records (24-hour data)
 func (db *DB) DeleteTemporaryRecords() error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	query := `DELETE FROM usage_records WHERE is_temporary = 1`
 	_, err := db.conn.Exec(query)
 	return err
@@ -555,10 +1942,172 @@ func (db *DB) DeleteTemporaryRecords() error {
 
 // Vacuum performs database vacuum to reclaim space
 func (db *DB) Vacuum() error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	_, err := db.conn.Exec("VACUUM")
 	return err
 }
 
+// VacuumIfNeeded reclaims free pages only when they exceed VacuumThreshold of the file. With
+// incremental auto_vacuum it frees pages in place; otherwise it runs a full VACUUM, which rewrites
+// the file and blocks writers while it runs.
+func (db *DB) VacuumIfNeeded() (VacuumResult, error) {
+	if err := db.writable(); err != nil {
+		return VacuumResult{}, err
+	}
+	db.vacuumMux.Lock()
+	defer db.vacuumMux.Unlock()
+
+	result := VacuumResult{Mode: "skipped", CheckedAt: time.Now().Unix()}
+	var autoVacuum int
+	if err := db.conn.QueryRow("PRAGMA page_count").Scan(&result.PageCount); err != nil {
+		return result, err
+	}
+	if err := db.conn.QueryRow("PRAGMA freelist_count").Scan(&result.FreePages); err != nil {
+		return result, err
+	}
+	if err := db.conn.QueryRow("PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return result, err
+	}
+	if result.PageCount > 0 {
+		result.Fragmentation = float64(result.FreePages) / float64(result.PageCount)
+	}
+
+	switch {
+	case result.Fragmentation < VacuumThreshold:
+		result.Reason = fmt.Sprintf("vacuum skipped — %.0f%% fragmentation", result.Fragmentation*100)
+	case autoVacuum == 2: // INCREMENTAL
+		if _, err := db.conn.Exec("PRAGMA incremental_vacuum"); err != nil {
+			return result, err
+		}
+		result.Mode = "incremental"
+		result.Reason = fmt.Sprintf("freed %d pages incrementally", result.FreePages)
+	default:
+		if _, err := db.conn.Exec("VACUUM"); err != nil {
+			return result, err
+		}
+		result.Mode = "full"
+		result.Reason = fmt.Sprintf("vacuumed — %.0f%% fragmentation", result.Fragmentation*100)
+	}
+
+	db.lastVacuum = result
+	return result, nil
+}
+
+// LastVacuum returns the most recent VacuumIfNeeded decision; zero if none was made yet
+func (db *DB) LastVacuum() VacuumResult {
+	db.vacuumMux.Lock()
+	defer db.vacuumMux.Unlock()
+	return db.lastVacuum
+}
+
+// ListCorruptedBackups returns the corrupted-database backups next to the database, newest first
+func (db *DB) ListCorruptedBackups() ([]CorruptedBackup, error) {
+	matches, err := filepath.Glob(db.path + ".corrupted.*")
+	if err != nil {
+		return nil, err
+	}
+
+	backups := make([]CorruptedBackup, 0, len(matches))
+	for _, path := range matches {
+		createdAt, ok := db.parseCorruptedBackup(path)
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		backups = append(backups, CorruptedBackup{
+			Path:      path,
+			Size:      info.Size(),
+			CreatedAt: createdAt.Unix(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt > backups[j].CreatedAt
+	})
+	return backups, nil
+}
+
+// DeleteCorruptedBackup removes one corrupted-database backup.
+// The path must sit next to the database and follow the backup naming pattern.
+func (db *DB) DeleteCorruptedBackup(path string) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid backup path: %w", err)
+	}
+	if _, ok := db.parseCorruptedBackup(path); !ok {
+		return fmt.Errorf("not a corrupted database backup: %s", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete backup: %w", err)
+	}
+	return nil
+}
+
+// PruneCorruptedBackups deletes all but the newest keep backups and returns how many were removed
+func (db *DB) PruneCorruptedBackups(keep int) (int, error) {
+	if err := db.writable(); err != nil {
+		return 0, err
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	backups, err := db.ListCorruptedBackups()
+	if err != nil {
+		return 0, err
+	}
+	if len(backups) <= keep {
+		return 0, nil
+	}
+
+	var removed int
+	var firstErr error
+	for _, backup := range backups[keep:] {
+		if err := db.DeleteCorruptedBackup(backup.Path); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		removed++
+	}
+	return removed, firstErr
+}
+
+// parseCorruptedBackup checks that path is a backup of this database and returns its creation time
+func (db *DB) parseCorruptedBackup(path string) (time.Time, bool) {
+	dbPath, err := filepath.Abs(db.path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if filepath.Dir(filepath.Clean(path)) != filepath.Dir(dbPath) {
+		return time.Time{}, false
+	}
+
+	prefix := filepath.Base(dbPath) + ".corrupted."
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, prefix) {
+		return time.Time{}, false
+	}
+	createdAt, err := time.ParseInLocation(corruptedBackupLayout, strings.TrimPrefix(name, prefix), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
+
+// Path returns the database file path
+func (db *DB) Path() string {
+	return db.path
+}
+
 // GetSize returns the database file size in bytes
 func (db *DB) GetSize() (int64, error) {
 	info, err := os.Stat(db.path)
@@ -597,6 +2146,9 @@ func (db *DB) GetSetting(key string) (string, error) {
 
 // SetSetting stores a setting value
 func (db *DB) SetSetting(key, value string) error {
+	if err := db.writable(); err != nil {
+		return err
+	}
 	query := `INSERT INTO settings (key, value) VALUES (?, ?)
 	          ON CONFLICT(key) DO UPDATE SET value = excluded.value`
 	_, err := db.conn.Exec(query, key, value)
@@ -633,7 +2185,7 @@ func (db *DB) Close() error {
 // checkDiskSpace checks if there's enough disk space for database operations
 func (db *DB) checkDiskSpace() error {
 	// Get database directory
-	dir := db.path[:len(db.path)-len("/netpus.db")]
+	dir := filepath.Dir(db.path)
 
 	// Check if database file size is reasonable (< 10GB as a safety limit)
 	if info, err := os.Stat(db.path); err == nil {
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB creates a fresh database in a temporary directory
+func newTestDB(t testing.TB) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "netpus.db"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpenReadOnlyMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.db")
+	if db, err := OpenReadOnly(path); err == nil {
+		db.Close()
+		t.Fatal("OpenReadOnly succeeded on a missing file")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("OpenReadOnly created %s", path)
+	}
+}
+
+func TestOpenReadOnlyRejectsWrites(t *testing.T) {
+	path := newTestDB(t).path
+
+	ro, err := OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer ro.Close()
+
+	if _, err := ro.conn.Exec(`INSERT INTO settings (key, value) VALUES ('probe', '1')`); err == nil {
+		t.Fatal("write succeeded on a read-only handle")
+	}
+	if _, _, err := ro.GetLifetimeTotals(); err != nil {
+		t.Fatalf("read failed on a read-only handle: %v", err)
+	}
+}
+
+func TestUsageBreakdownOtherAddsUp(t *testing.T) {
+	db := newTestDB(t)
+
+	// Twelve apps with several records each, some tied, plus one excluded app
+	var records []UsageRecord
+	var wantUp, wantDown int64
+	for app := 0; app < 12; app++ {
+		for i := 0; i < 3; i++ {
+			up, down := int64(100*(app%5)+i), int64(1000*(app%7)+i)
+			records = append(records, UsageRecord{
+				AppName: fmt.Sprintf("app%02d.exe", app), UploadBytes: up, DownloadBytes: down, Timestamp: int64(1000 + app*10 + i),
+			})
+			wantUp += up
+			wantDown += down
+		}
+	}
+	records = append(records, UsageRecord{AppName: "Blocked.EXE", UploadBytes: 1 << 30, DownloadBytes: 1 << 30, Timestamp: 1005})
+	if _, err := db.InsertMissingUsageRecords(records); err != nil {
+		t.Fatalf("InsertMissingUsageRecords: %v", err)
+	}
+
+	for _, limit := range []int{0, 1, 5, 11, 12, 20} {
+		top, other, otherApps, err := db.GetUsageBreakdownWithOther(0, 2000, limit, []string{"blocked"})
+		if err != nil {
+			t.Fatalf("limit %d: %v", limit, err)
+		}
+		up, down := other.TotalUpload, other.TotalDownload
+		for _, s := range top {
+			up += s.TotalUpload
+			down += s.TotalDownload
+		}
+		if up != wantUp || down != wantDown {
+			t.Errorf("limit %d: top + Other = %d up / %d down, want %d / %d", limit, up, down, wantUp, wantDown)
+		}
+		wantTop := limit
+		if wantTop > 12 {
+			wantTop = 12
+		}
+		if len(top) != wantTop || otherApps != 12-wantTop {
+			t.Errorf("limit %d: %d top apps and %d in Other, want %d and %d", limit, len(top), otherApps, wantTop, 12-wantTop)
+		}
+	}
+}
+
+func TestInsertMissingUsageRecordsReplayTwice(t *testing.T) {
+	db := newTestDB(t)
+
+	// Identical traffic collected twice in one second is two records, told apart only by key
+	records := []UsageRecord{
+		{AppName: "app.exe", ProcessID: 7, Protocol: "tcp", UploadBytes: 10, DownloadBytes: 100, Timestamp: 1000, RecordKey: "run-1"},
+		{AppName: "app.exe", ProcessID: 7, Protocol: "tcp", UploadBytes: 10, DownloadBytes: 100, Timestamp: 1000, RecordKey: "run-2"},
+		{AppName: "app.exe", ProcessID: 7, Protocol: "udp", UploadBytes: 10, DownloadBytes: 100, Timestamp: 1000, RecordKey: "run-3"},
+	}
+	for replay := 1; replay <= 2; replay++ {
+		inserted, err := db.InsertMissingUsageRecords(records)
+		if err != nil {
+			t.Fatalf("replay %d: %v", replay, err)
+		}
+		want := len(records)
+		if replay > 1 {
+			want = 0
+		}
+		if len(inserted) != want {
+			t.Errorf("replay %d inserted %d records, want %d", replay, len(inserted), want)
+		}
+	}
+
+	stored, err := db.GetUsageByTimeRange(0, 2000)
+	if err != nil {
+		t.Fatalf("GetUsageByTimeRange: %v", err)
+	}
+	if len(stored) != len(records) {
+		t.Errorf("stored %d records, want %d", len(stored), len(records))
+	}
+	if up, down, err := db.GetLifetimeTotals(); err != nil || up != 30 || down != 300 {
+		t.Errorf("lifetime totals = %d/%d (%v), want 30/300", up, down, err)
+	}
+}
+
+// seedUsage fills db with a year of records for apps, recordsPerDay each per day, and the
+// matching daily summaries
+func seedUsage(b *testing.B, db *DB, apps, recordsPerDay int) {
+	b.Helper()
+	start := time.Now().AddDate(-1, 0, 0)
+	for day := 0; day < 365; day++ {
+		date := start.AddDate(0, 0, day)
+		records := make([]UsageRecord, 0, apps*recordsPerDay)
+		var up, down int64
+		for app := 0; app < apps; app++ {
+			for i := 0; i < recordsPerDay; i++ {
+				r := UsageRecord{
+					AppName:       fmt.Sprintf("app%03d.exe", app),
+					UploadBytes:   int64(1000 + app + i),
+					DownloadBytes: int64(10000 + app*i),
+					Timestamp:     date.Add(time.Duration(i) * time.Hour).Unix(),
+				}
+				records = append(records, r)
+				up += r.UploadBytes
+				down += r.DownloadBytes
+			}
+		}
+		if _, err := db.InsertMissingUsageRecords(records); err != nil {
+			b.Fatalf("InsertMissingUsageRecords: %v", err)
+		}
+		if err := db.UpdateDailySummary(date.Format("2006-01-02"), up, down); err != nil {
+			b.Fatalf("UpdateDailySummary: %v", err)
+		}
+	}
+}
+
+// BenchmarkAllTimeTotals compares reading all-time totals from the daily summaries with summing
+// every app's all-time usage records, which the all-time view did before
+func BenchmarkAllTimeTotals(b *testing.B) {
+	db := newTestDB(b)
+	seedUsage(b, db, 100, 4)
+
+	b.Run("summaries", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, _, err := db.GetAllTimeTotals(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("records", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			stats, err := db.GetAppUsageWithRetention(0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var up, down int64
+			for _, s := range stats {
+				up += s.TotalUpload
+				down += s.TotalDownload
+			}
+		}
+	})
+}
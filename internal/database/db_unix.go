@@ -0,0 +1,14 @@
+//go:build !windows
+
+package database
+
+import "syscall"
+
+// getAvailableDiskSpace returns available disk space in bytes for Unix-like systems
+func getAvailableDiskSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -10,27 +10,35 @@ import (
 	"github.com/go-ole/go-ole"
 	"github.com/go-ole/go-ole/oleutil"
 	"golang.org/x/sys/windows/registry"
-)
 
-const (
-	uninstallKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\Netpus`
-	appVersion   = "1.0.0"
+	"netpus/internal/brand"
 )
 
+const appVersion = "1.0.0"
+
+// uninstallKey is the Apps & Features entry for this app
+var uninstallKey = `SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall\` + brand.AppName
+
 // installWindows creates desktop and start menu shortcuts on Windows
 func installWindows(execPath string) error {
 	ole.CoInitialize(0)
 	defer ole.CoUninitialize()
 
 	// Create desktop shortcut
-	desktop := os.Getenv("USERPROFILE") + "\\Desktop"
-	if err := createShortcut(execPath, filepath.Join(desktop, "Netpus.lnk")); err != nil {
+	desktop, err := userFolder("USERPROFILE", "Desktop")
+	if err != nil {
+		return fmt.Errorf("failed to create desktop shortcut: %w", err)
+	}
+	if err := createShortcut(execPath, filepath.Join(desktop, brand.ShortcutName())); err != nil {
 		return fmt.Errorf("failed to create desktop shortcut: %w", err)
 	}
 
 	// Create start menu shortcut
-	startMenu := os.Getenv("APPDATA") + "\\Microsoft\\Windows\\Start Menu\\Programs"
-	if err := createShortcut(execPath, filepath.Join(startMenu, "Netpus.lnk")); err != nil {
+	startMenu, err := userFolder("APPDATA", "Microsoft", "Windows", "Start Menu", "Programs")
+	if err != nil {
+		return fmt.Errorf("failed to create start menu shortcut: %w", err)
+	}
+	if err := createShortcut(execPath, filepath.Join(startMenu, brand.ShortcutName())); err != nil {
 		return fmt.Errorf("failed to create start menu shortcut: %w", err)
 	}
 
@@ -43,7 +51,7 @@ func installWindows(execPath string) error {
 	return nil
 }
 
-// addUninstallEntry adds Netpus to Windows "Apps & Features"
+// addUninstallEntry adds the app to Windows "Apps & Features"
 func addUninstallEntry(execPath string) error {
 	key, _, err := registry.CreateKey(registry.CURRENT_USER, uninstallKey, registry.ALL_ACCESS)
 	if err != nil {
@@ -55,13 +63,13 @@ func addUninstallEntry(execPath string) error {
 	uninstallCmd := fmt.Sprintf(`"%s" --uninstall`, execPath)
 
 	// Set required values for Apps & Features
-	if err := key.SetStringValue("DisplayName", "Netpus Network Monitor"); err != nil {
+	if err := key.SetStringValue("DisplayName", brand.ProductName); err != nil {
 		return err
 	}
 	if err := key.SetStringValue("DisplayVersion", appVersion); err != nil {
 		return err
 	}
-	if err := key.SetStringValue("Publisher", "Netpus"); err != nil {
+	if err := key.SetStringValue("Publisher", brand.Vendor); err != nil {
 		return err
 	}
 	if err := key.SetStringValue("InstallLocation", installDir); err != nil {
@@ -91,11 +99,13 @@ func addUninstallEntry(execPath string) error {
 
 // uninstallWindows removes shortcuts, registry entry, and optionally app data on Windows
 func uninstallWindows() error {
-	desktop := os.Getenv("USERPROFILE") + "\\Desktop\\Netpus.lnk"
-	startMenu := os.Getenv("APPDATA") + "\\Microsoft\\Windows\\Start Menu\\Programs\\Netpus.lnk"
-
-	os.Remove(desktop)
-	os.Remove(startMenu)
+	// Folders whose variable is unset are skipped rather than resolved against the drive root
+	if desktop, err := userFolder("USERPROFILE", "Desktop"); err == nil {
+		os.Remove(filepath.Join(desktop, brand.ShortcutName()))
+	}
+	if startMenu, err := userFolder("APPDATA", "Microsoft", "Windows", "Start Menu", "Programs"); err == nil {
+		os.Remove(filepath.Join(startMenu, brand.ShortcutName()))
+	}
 
 	// Remove from Apps & Features
 	registry.DeleteKey(registry.CURRENT_USER, uninstallKey)
@@ -103,20 +113,28 @@ func uninstallWindows() error {
 	// Remove autostart registry entry
 	autorunKey, err := registry.OpenKey(registry.CURRENT_USER, `SOFTWARE\Microsoft\Windows\CurrentVersion\Run`, registry.ALL_ACCESS)
 	if err == nil {
-		autorunKey.DeleteValue("Netpus")
+		autorunKey.DeleteValue(brand.AppName)
 		autorunKey.Close()
 	}
 
 	// Remove app data folder
-	localAppData := os.Getenv("LOCALAPPDATA")
-	if localAppData != "" {
-		appDataDir := filepath.Join(localAppData, "Netpus")
+	if appDataDir, err := userFolder("LOCALAPPDATA", brand.AppName); err == nil {
 		os.RemoveAll(appDataDir)
 	}
 
 	return nil
 }
 
+// userFolder joins elem onto the folder named by an environment variable, failing when the
+// variable is unset or not an absolute path
+func userFolder(env string, elem ...string) (string, error) {
+	base := os.Getenv(env)
+	if base == "" || !filepath.IsAbs(base) {
+		return "", fmt.Errorf("%s is not set to a folder", env)
+	}
+	return filepath.Join(append([]string{base}, elem...)...), nil
+}
+
 // createShortcut creates a Windows shortcut file
 func createShortcut(targetPath, shortcutPath string) error {
 	oleShellObject, err := oleutil.CreateObject("WScript.Shell")
@@ -139,7 +157,7 @@ func createShortcut(targetPath, shortcutPath string) error {
 	defer idispatch.Release()
 
 	oleutil.PutProperty(idispatch, "TargetPath", targetPath)
-	oleutil.PutProperty(idispatch, "Description", "Netpus Network Monitor")
+	oleutil.PutProperty(idispatch, "Description", brand.ProductName)
 	oleutil.PutProperty(idispatch, "WorkingDirectory", filepath.Dir(targetPath))
 
 	// Set icon if it exists
@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// Attribution weight of each connection kind. An established TCP connection is likely moving
+// data; a UDP socket may only be listening, so it counts for less.
+const (
+	TCP_CONNECTION_WEIGHT = 1.0
+	UDP_CONNECTION_WEIGHT = 0.3
+)
+
+// connection is one row of the connection tables, reduced to what attribution needs
+type connection struct {
+	pid      uint32
+	protocol string     // "tcp" or "udp"
+	family   string     // "ipv4" or "ipv6"
+	remote   netip.Addr // Remote address of TCP connections; invalid for UDP, whose table has none
+}
+
+// trafficKind is the transport and address family a share of an app's traffic was seen on
+type trafficKind struct {
+	protocol string
+	family   string
+}
+
+// traffic is a pair of byte counts
+type traffic struct {
+	upload   int64
+	download int64
+}
+
+// processData is an app's attributed bytes for one collection
+type processData struct {
+	processID     int
+	uploadBytes   int64                   // Sum over shares
+	downloadBytes int64                   // Sum over shares
+	shares        map[trafficKind]traffic // Bytes per transport and address family; nil if unknown
+	remotes       map[netip.Addr]float64  // TCP connection weight per remote address, for destination grouping
+	firstSeen     time.Time               // Earliest first-seen time of the app's processes; zero if unknown
+}
+
+// kindShare is one entry of processData.shares
+type kindShare struct {
+	kind trafficKind
+	traffic
+}
+
+// sortedShares returns the app's shares ordered by protocol and family. An app without shares
+// (from a source that doesn't track them) has a single share of unknown kind.
+func (d processData) sortedShares() []kindShare {
+	if len(d.shares) == 0 {
+		return []kindShare{{traffic: traffic{upload: d.uploadBytes, download: d.downloadBytes}}}
+	}
+	shares := make([]kindShare, 0, len(d.shares))
+	for kind, t := range d.shares {
+		shares = append(shares, kindShare{kind: kind, traffic: t})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].kind.protocol != shares[j].kind.protocol {
+			return shares[i].kind.protocol < shares[j].kind.protocol
+		}
+		return shares[i].kind.family < shares[j].kind.family
+	})
+	return shares
+}
+
+// scaled returns the app's bytes with scale applied to each share, keeping the totals in step
+func (d processData) scaled(scale func(int64) int64) processData {
+	if len(d.shares) == 0 {
+		d.uploadBytes = scale(d.uploadBytes)
+		d.downloadBytes = scale(d.downloadBytes)
+		return d
+	}
+	shares := make(map[trafficKind]traffic, len(d.shares))
+	d.uploadBytes, d.downloadBytes = 0, 0
+	for kind, t := range d.shares {
+		t = traffic{upload: scale(t.upload), download: scale(t.download)}
+		shares[kind] = t
+		d.uploadBytes += t.upload
+		d.downloadBytes += t.download
+	}
+	d.shares = shares
+	return d
+}
+
+// connectionWeight returns a connection's attribution weight
+func connectionWeight(conn connection) float64 {
+	if conn.protocol == "tcp" {
+		return TCP_CONNECTION_WEIGHT
+	}
+	return UDP_CONNECTION_WEIGHT
+}
+
+// attribute distributes a cycle's system delta over the processes owning conns, in proportion to
+// connection weight. Each app's bytes are kept per transport and address family, so an app using
+// both TCP and UDP has a share of each. With skipLAN, connections to LAN addresses keep their part
+// of the total weight but credit it to no app, so their bytes drop out instead of being spread
+// over internet traffic. Shares of processes whose name couldn't be resolved go to UNKNOWN_APP, so
+// the attributed bytes still add up to the delta.
+func attribute(conns []connection, names map[uint32]string, upload, download int64, skipLAN bool, cache *processCache) map[string]processData {
+	type unit struct {
+		pid  uint32
+		kind trafficKind
+	}
+	index := make(map[unit]uint32)
+	var units []unit
+	weights := make(map[uint32]float64)                // Keyed by unit index
+	remotes := make(map[uint32]map[netip.Addr]float64) // Keyed by unit index
+	var totalWeight float64
+
+	for _, conn := range conns {
+		weight := connectionWeight(conn)
+		totalWeight += weight
+		if skipLAN && conn.remote.IsValid() && isLANAddress(conn.remote.AsSlice()) {
+			continue
+		}
+		u := unit{conn.pid, trafficKind{conn.protocol, conn.family}}
+		i, ok := index[u]
+		if !ok {
+			i = uint32(len(units))
+			index[u] = i
+			units = append(units, u)
+		}
+		weights[i] += weight
+		if conn.remote.IsValid() {
+			if remotes[i] == nil {
+				remotes[i] = make(map[netip.Addr]float64)
+			}
+			remotes[i][conn.remote] += weight
+		}
+	}
+
+	uploads := distributeBytes(upload, weights, totalWeight)
+	downloads := distributeBytes(download, weights, totalWeight)
+
+	result := make(map[string]processData)
+	for i, u := range units {
+		up, down := uploads[uint32(i)], downloads[uint32(i)]
+		if up == 0 && down == 0 {
+			continue
+		}
+		name := names[u.pid]
+		if name == "" {
+			name = UNKNOWN_APP
+		}
+
+		// Several processes can share an executable name, so accumulate
+		data := result[name]
+		if data.shares == nil {
+			data.shares = make(map[trafficKind]traffic)
+			if name != UNKNOWN_APP {
+				data.processID = int(u.pid)
+			}
+		}
+		share := data.shares[u.kind]
+		share.upload += up
+		share.download += down
+		data.shares[u.kind] = share
+		data.uploadBytes += up
+		data.downloadBytes += down
+		for addr, weight := range remotes[uint32(i)] {
+			if data.remotes == nil {
+				data.remotes = make(map[netip.Addr]float64)
+			}
+			data.remotes[addr] += weight
+		}
+		if cache != nil {
+			if seen, ok := cache.firstSeen(u.pid); ok && (data.firstSeen.IsZero() || seen.Before(data.firstSeen)) {
+				data.firstSeen = seen
+			}
+		}
+		result[name] = data
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestAttributeSplitsProtocols(t *testing.T) {
+	remote := netip.MustParseAddr("203.0.113.10")
+	conns := []connection{
+		{pid: 10, protocol: "tcp", family: "ipv4", remote: remote},
+		{pid: 10, protocol: "tcp", family: "ipv4", remote: remote},
+		{pid: 10, protocol: "udp", family: "ipv4"},
+		{pid: 20, protocol: "udp", family: "ipv4"},
+	}
+	names := map[uint32]string{10: "discord.exe", 20: "game.exe"}
+
+	// Total weight is 2.6, so 2600 bytes split 2000/300/300
+	result := attribute(conns, names, 0, 2600, false, nil)
+
+	discord := result["discord.exe"]
+	if got := discord.shares[trafficKind{"tcp", "ipv4"}].download; got != 2000 {
+		t.Errorf("discord tcp download = %d, want 2000", got)
+	}
+	if got := discord.shares[trafficKind{"udp", "ipv4"}].download; got != 300 {
+		t.Errorf("discord udp download = %d, want 300", got)
+	}
+	if discord.downloadBytes != 2300 {
+		t.Errorf("discord download = %d, want 2300", discord.downloadBytes)
+	}
+	if got := result["game.exe"].shares[trafficKind{"udp", "ipv4"}].download; got != 300 {
+		t.Errorf("game udp download = %d, want 300", got)
+	}
+}
+
+func TestAttributeCreditsUnnamedProcesses(t *testing.T) {
+	conns := []connection{
+		{pid: 10, protocol: "tcp", family: "ipv4", remote: netip.MustParseAddr("203.0.113.10")},
+		{pid: 20, protocol: "tcp", family: "ipv6", remote: netip.MustParseAddr("2001:db8::1")},
+		{pid: 30, protocol: "udp", family: "ipv4"},
+		{pid: 40, protocol: "udp", family: "ipv4"},
+	}
+	// PIDs 30 and 40 exited or couldn't be opened
+	names := map[uint32]string{10: "browser.exe", 20: "mail.exe"}
+
+	const UPLOAD, DOWNLOAD = 12345, 987654
+	result := attribute(conns, names, UPLOAD, DOWNLOAD, false, nil)
+
+	var up, down int64
+	for _, data := range result {
+		up += data.uploadBytes
+		down += data.downloadBytes
+	}
+	if up != UPLOAD || down != DOWNLOAD {
+		t.Errorf("attributed %d up / %d down, want %d / %d", up, down, UPLOAD, DOWNLOAD)
+	}
+	if _, ok := result[UNKNOWN_APP]; !ok {
+		t.Errorf("unnamed processes were not credited to %s", UNKNOWN_APP)
+	}
+}
+
+func TestDistributeBytesSumsToTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int64
+		weights map[uint32]float64
+	}{
+		{"single", 1000, map[uint32]float64{1: 1}},
+		{"equal thirds", 1000, map[uint32]float64{1: 1, 2: 1, 3: 1}},
+		{"tcp and udp", 7, map[uint32]float64{1: 1, 2: 0.3, 3: 0.3}},
+		{"fewer bytes than units", 2, map[uint32]float64{1: 1, 2: 1, 3: 1, 4: 1, 5: 1}},
+		{"large total", 1 << 40, map[uint32]float64{1: 0.3, 2: 0.3, 3: 0.3, 4: 1, 5: 2.6}},
+		{"one byte", 1, map[uint32]float64{1: 0.3, 2: 0.3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var totalWeight float64
+			for _, w := range tt.weights {
+				totalWeight += w
+			}
+			var sum int64
+			for pid, share := range distributeBytes(tt.total, tt.weights, totalWeight) {
+				if share < 0 {
+					t.Errorf("pid %d got negative share %d", pid, share)
+				}
+				sum += share
+			}
+			if sum != tt.total {
+				t.Errorf("shares sum to %d, want %d", sum, tt.total)
+			}
+		})
+	}
+}
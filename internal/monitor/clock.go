@@ -0,0 +1,72 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// ClockJump describes a wall-clock change noticed between two collections
+type ClockJump struct {
+	DetectedAt    int64 `json:"detectedAt"`    // Unix timestamp (after the jump)
+	OffsetSeconds int64 `json:"offsetSeconds"` // How far the wall clock moved beyond real elapsed time; negative when it went back
+}
+
+// elapsedClocks returns the time between prev and now by the monotonic and by the wall clock.
+// Both must come from time.Now() so they carry a monotonic reading.
+func elapsedClocks(prev, now time.Time) (monotonic, wall time.Duration) {
+	return now.Sub(prev), now.Round(0).Sub(prev.Round(0))
+}
+
+// clockOffset returns how much further the wall clock moved than the monotonic clock
+func clockOffset(monotonic, wall time.Duration) time.Duration {
+	return wall - monotonic
+}
+
+// checkClock records a wall-clock jump since the previous collection. Called with statsMux held.
+func (m *Monitor) checkClock(prev, now time.Time) {
+	if prev.IsZero() {
+		return
+	}
+	m.recordClockOffset(now, clockOffset(elapsedClocks(prev, now)))
+}
+
+// recordClockOffset counts a wall-clock offset beyond the tolerance as a jump. Called with statsMux held.
+func (m *Monitor) recordClockOffset(now time.Time, offset time.Duration) {
+	if offset > -CLOCK_JUMP_TOLERANCE && offset < CLOCK_JUMP_TOLERANCE {
+		return
+	}
+	m.clockJumps++
+	m.lastClockJump = ClockJump{
+		DetectedAt:    now.Unix(),
+		OffsetSeconds: int64(offset.Round(time.Second).Seconds()),
+	}
+	if offset < 0 {
+		// Records stored from here on carry earlier timestamps than the ones before them
+		fmt.Printf("System clock moved back %v; recent records may be out of order\n", -offset.Round(time.Second))
+	} else {
+		fmt.Printf("System clock moved forward %v\n", offset.Round(time.Second))
+	}
+}
+
+// resumedAfterGap reports whether far more time passed since the previous collection than any
+// update interval allows. Both clocks are checked because the monotonic clock may not advance
+// while the machine sleeps, but the wall clock does.
+func resumedAfterGap(monotonic, wall time.Duration) bool {
+	return monotonic > RESUME_GAP || wall > RESUME_GAP
+}
+
+// rebaseline discards a collection spanning a sleep/resume gap of the given elapsed times: live
+// speeds drop to zero and the next collection measures from now. A gap caused by the wall clock
+// being set forward is still recorded as a clock jump.
+func (m *Monitor) rebaseline(now time.Time, monotonic, wall time.Duration) {
+	fmt.Printf("Collection gap of %v (sleep/resume?); discarding its delta\n", wall.Round(time.Second))
+
+	m.statsMux.Lock()
+	m.recordClockOffset(now, clockOffset(monotonic, wall))
+	for _, stat := range m.stats {
+		stat.UploadSpeed = 0
+		stat.DownloadSpeed = 0
+	}
+	m.lastUpdate = now
+	m.statsMux.Unlock()
+}
@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockJumps(t *testing.T) {
+	tests := []struct {
+		name       string
+		monotonic  time.Duration
+		wall       time.Duration
+		wantOffset time.Duration
+		wantGap    bool
+	}{
+		{"steady", 500 * time.Millisecond, 500 * time.Millisecond, 0, false},
+		{"small drift", 500 * time.Millisecond, 700 * time.Millisecond, 200 * time.Millisecond, false},
+		{"clock set forward", time.Second, time.Hour + time.Second, time.Hour, true},
+		{"clock set back", time.Second, -time.Hour + time.Second, -time.Hour, false},
+		{"sleep with monotonic paused", 500 * time.Millisecond, 8 * time.Hour, 8*time.Hour - 500*time.Millisecond, true},
+		{"sleep with monotonic running", 8 * time.Hour, 8 * time.Hour, 0, true},
+		{"just under the gap", RESUME_GAP, RESUME_GAP, 0, false},
+		{"just over the gap", RESUME_GAP + time.Millisecond, RESUME_GAP + time.Millisecond, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clockOffset(tt.monotonic, tt.wall); got != tt.wantOffset {
+				t.Errorf("clockOffset = %v, want %v", got, tt.wantOffset)
+			}
+			if got := resumedAfterGap(tt.monotonic, tt.wall); got != tt.wantGap {
+				t.Errorf("resumedAfterGap = %v, want %v", got, tt.wantGap)
+			}
+		})
+	}
+}
+
+func TestElapsedClocks(t *testing.T) {
+	prev := time.Now()
+	now := prev.Add(3 * time.Second)
+	monotonic, wall := elapsedClocks(prev, now)
+	if monotonic != 3*time.Second || wall != 3*time.Second {
+		t.Errorf("elapsedClocks = %v, %v; want 3s, 3s", monotonic, wall)
+	}
+}
+
+func TestRebaselineRecordsClockSetForward(t *testing.T) {
+	m := New(nil)
+	now := time.Now()
+
+	// The wall clock moved an hour further than real time, which also trips the gap check
+	m.rebaseline(now, time.Second, time.Hour+time.Second)
+
+	status := m.GetMonitorStatus()
+	if status.ClockJumps != 1 {
+		t.Fatalf("ClockJumps = %d, want 1", status.ClockJumps)
+	}
+	if status.LastClockJump.OffsetSeconds != 3600 || status.LastClockJump.DetectedAt != now.Unix() {
+		t.Errorf("LastClockJump = %+v, want a 3600s jump at %d", status.LastClockJump, now.Unix())
+	}
+
+	// A gap the monotonic clock also measured is a plain sleep, not a clock change
+	m.rebaseline(now, 8*time.Hour, 8*time.Hour)
+	if got := m.GetMonitorStatus().ClockJumps; got != 1 {
+		t.Errorf("ClockJumps = %d after a sleep gap, want 1", got)
+	}
+}
@@ -0,0 +1,168 @@
+package monitor
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"netpus/internal/database"
+	"netpus/internal/utils"
+)
+
+// destinationRule labels every address in one IP range
+type destinationRule struct {
+	network *net.IPNet
+	label   string
+}
+
+// parseDestinationRules converts configured rules, skipping any that don't parse
+func parseDestinationRules(rules []utils.DestinationRule) []destinationRule {
+	parsed := make([]destinationRule, 0, len(rules))
+	for _, rule := range rules {
+		_, network, err := net.ParseCIDR(rule.CIDR)
+		if err != nil || rule.Label == "" {
+			continue
+		}
+		parsed = append(parsed, destinationRule{network: network, label: rule.Label})
+	}
+	return parsed
+}
+
+// lanNetworks are the private and link-local ranges left out of attribution when LAN traffic isn't tracked
+var lanNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fe80::/10")
+
+// parseNetworks parses fixed CIDR ranges, panicking on a malformed one
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// isLANAddress reports whether ip is in a private (RFC 1918) or IPv6 link-local range
+func isLANAddress(ip net.IP) bool {
+	for _, network := range lanNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDestinationRules replaces the IP ranges used to group traffic by destination
+func (m *Monitor) SetDestinationRules(rules []utils.DestinationRule) {
+	parsed := parseDestinationRules(rules)
+	m.destinationMux.Lock()
+	m.destinationRules = parsed
+	m.destinationMux.Unlock()
+}
+
+// matchDestination returns the label of the first rule containing addr, or utils.DestinationOther
+func matchDestination(rules []destinationRule, addr netip.Addr) string {
+	ip := net.IP(addr.Unmap().AsSlice())
+	for _, rule := range rules {
+		if rule.network.Contains(ip) {
+			return rule.label
+		}
+	}
+	return utils.DestinationOther
+}
+
+// addDestinations adds the collected apps' bytes to the pending destination totals. Each app's
+// bytes are split over the labels of its TCP remote addresses by connection weight, normalized by
+// that app's TCP weight, so its UDP share follows its TCP destinations. An app with no TCP
+// connection has no known destination and counts as utils.DestinationOther. Apps in skip (paused)
+// are left out, as excluded apps already are, so only stored traffic is grouped.
+func (m *Monitor) addDestinations(processes map[string]processData, skip map[string]bool) {
+	m.destinationMux.RLock()
+	rules := m.destinationRules
+	m.destinationMux.RUnlock()
+
+	labeled := make(map[string]*systemDelta)
+	add := func(label string, upload, download int64) {
+		total, ok := labeled[label]
+		if !ok {
+			total = &systemDelta{}
+			labeled[label] = total
+		}
+		total.upload += upload
+		total.download += download
+	}
+	for appName, data := range processes {
+		if skip[appName] || (data.uploadBytes == 0 && data.downloadBytes == 0) {
+			continue
+		}
+		if len(data.remotes) == 0 {
+			add(utils.DestinationOther, data.uploadBytes, data.downloadBytes)
+			continue
+		}
+
+		index := make(map[string]uint32)
+		var labels []string
+		weights := make(map[uint32]float64)
+		var tcpWeight float64
+		for addr, weight := range data.remotes {
+			label := matchDestination(rules, addr)
+			i, ok := index[label]
+			if !ok {
+				i = uint32(len(labels))
+				index[label] = i
+				labels = append(labels, label)
+			}
+			weights[i] += weight
+			tcpWeight += weight
+		}
+		uploads := distributeBytes(data.uploadBytes, weights, tcpWeight)
+		downloads := distributeBytes(data.downloadBytes, weights, tcpWeight)
+		for i, label := range labels {
+			add(label, uploads[uint32(i)], downloads[uint32(i)])
+		}
+	}
+	if len(labeled) == 0 {
+		return
+	}
+
+	m.batchMux.Lock()
+	defer m.batchMux.Unlock()
+	if m.pendingDestinations == nil {
+		m.pendingDestinations = make(map[string]*systemDelta)
+	}
+	for label, bytes := range labeled {
+		total, ok := m.pendingDestinations[label]
+		if !ok {
+			total = &systemDelta{}
+			m.pendingDestinations[label] = total
+		}
+		total.upload += bytes.upload
+		total.download += bytes.download
+	}
+}
+
+// flushDestinations writes pending destination totals to the database
+func (m *Monitor) flushDestinations(destinations map[string]*systemDelta) {
+	if len(destinations) == 0 {
+		return
+	}
+	stats := make([]database.DestinationStat, 0, len(destinations))
+	for label, bytes := range destinations {
+		if bytes.upload == 0 && bytes.download == 0 {
+			continue
+		}
+		stats = append(stats, database.DestinationStat{
+			Label:         label,
+			TotalUpload:   bytes.upload,
+			TotalDownload: bytes.download,
+		})
+	}
+	if db, ok := m.db.(*database.DB); ok {
+		if err := db.InsertDestinationUsage(time.Now().Unix(), stats); err != nil {
+			fmt.Printf("Failed to insert destination usage: %v\n", err)
+		}
+	}
+}
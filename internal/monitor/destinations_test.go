@@ -0,0 +1,80 @@
+package monitor
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"netpus/internal/utils"
+)
+
+func TestDestinationsFollowStoredApps(t *testing.T) {
+	tcp := trafficKind{"tcp", "ipv4"}
+	udp := trafficKind{"udp", "ipv4"}
+	m := newTestMonitor(nil, fakeSource(map[string]processData{
+		"browser.exe": {
+			uploadBytes: 100, downloadBytes: 1000,
+			shares: map[trafficKind]traffic{
+				tcp: {upload: 70, download: 700},
+				udp: {upload: 30, download: 300},
+			},
+			remotes: map[netip.Addr]float64{
+				netip.MustParseAddr("203.0.113.5"):  1,
+				netip.MustParseAddr("198.51.100.7"): 1,
+			},
+		},
+		"vpn.exe": {
+			uploadBytes: 50, downloadBytes: 500,
+			remotes: map[netip.Addr]float64{netip.MustParseAddr("203.0.113.6"): 1},
+		},
+		"voice.exe": {uploadBytes: 5, downloadBytes: 8},
+	}))
+	m.SetDestinationRules([]utils.DestinationRule{{CIDR: "203.0.113.0/24", Label: "Example"}})
+	m.SetExcludedApps([]string{"vpn.exe"})
+
+	mustCollect(t, m)
+
+	example := m.pendingDestinations["Example"]
+	if example == nil || example.upload != 50 || example.download != 500 {
+		t.Errorf("Example = %+v, want half of browser.exe's 100/1000 bytes", example)
+	}
+	// The other half of browser.exe goes to an unlabeled remote, voice.exe has no TCP remote
+	other := m.pendingDestinations[utils.DestinationOther]
+	if other == nil || other.upload != 55 || other.download != 508 {
+		t.Errorf("Other = %+v, want 55/508", other)
+	}
+}
+
+func TestIsLANAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"10.255.255.255", true},
+		{"11.0.0.1", false},
+		{"172.15.255.255", false},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.0.1", true},
+		{"192.168.255.255", true},
+		{"192.169.0.1", false},
+		{"8.8.8.8", false},
+		{"203.0.113.10", false},
+		{"fe80::1", true},
+		{"febf:ffff::1", true},
+		{"fec0::1", false},
+		{"2001:db8::1", false},
+		{"::ffff:192.168.1.1", true},
+	}
+	for _, tt := range tests {
+		if got := isLANAddress(net.ParseIP(tt.addr)); got != tt.want {
+			t.Errorf("isLANAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+		// Attribution passes netip addresses as 4- or 16-byte slices
+		if got := isLANAddress(netip.MustParseAddr(tt.addr).Unmap().AsSlice()); got != tt.want {
+			t.Errorf("isLANAddress(%s as slice) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
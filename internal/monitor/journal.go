@@ -0,0 +1,253 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"netpus/internal/database"
+)
+
+// journalEntry is one batch record as written to the crash journal
+type journalEntry struct {
+	AppName     string `json:"app"`
+	ProcessID   int    `json:"pid"`
+	Protocol    string `json:"proto,omitempty"`
+	Family      string `json:"family,omitempty"`
+	Upload      int64  `json:"up"`
+	Download    int64  `json:"down"`
+	Timestamp   int64  `json:"ts"`
+	IsTemporary bool   `json:"temp,omitempty"`
+	ExpiresAt   int64  `json:"exp"`
+	Label       string `json:"label,omitempty"`
+	Screen      string `json:"screen,omitempty"`
+	Key         string `json:"key,omitempty"`
+}
+
+// batchJournal is an append-only file mirroring the in-memory batch, so records collected since the
+// last flush survive a crash. Writes are not fsynced; the OS keeps them if only the process dies.
+// Callers serialize access (the monitor holds batchMux).
+type batchJournal struct {
+	path string
+	file *os.File
+}
+
+// openJournal opens (or creates) the journal at path for appending
+func openJournal(path string) (*batchJournal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch journal: %w", err)
+	}
+	return &batchJournal{path: path, file: file}, nil
+}
+
+// append writes records as JSON lines in a single write
+func (j *batchJournal) append(records []batchRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, rec := range records {
+		if err := encoder.Encode(journalEntry{
+			AppName:     rec.appName,
+			ProcessID:   rec.processID,
+			Protocol:    rec.protocol,
+			Family:      rec.family,
+			Upload:      rec.upload,
+			Download:    rec.download,
+			Timestamp:   rec.timestamp,
+			IsTemporary: rec.isTemporary,
+			ExpiresAt:   rec.expiresAt,
+			Label:       rec.sessionLabel,
+			Screen:      rec.screenState,
+			Key:         rec.key,
+		}); err != nil {
+			return err
+		}
+	}
+	_, err := j.file.Write(buf.Bytes())
+	return err
+}
+
+// rotate moves the current journal aside for a flush in progress and starts an empty one.
+// The returned file should be removed once its records are in the database.
+func (j *batchJournal) rotate() (string, error) {
+	if err := j.file.Close(); err != nil {
+		return "", err
+	}
+	rotated := j.path + ".flushing-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	renameErr := os.Rename(j.path, rotated)
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to reopen batch journal: %w", err)
+	}
+	j.file = file
+	if renameErr != nil {
+		return "", renameErr
+	}
+	return rotated, nil
+}
+
+// reset discards everything journaled so far
+func (j *batchJournal) reset() error {
+	return j.file.Truncate(0)
+}
+
+// close closes the journal file
+func (j *batchJournal) close() error {
+	return j.file.Close()
+}
+
+// journalFiles returns the journal and any flushes left unfinished by a crash, oldest first
+func journalFiles(path string) []string {
+	rotated, _ := filepath.Glob(path + ".flushing-*")
+	sort.Strings(rotated)
+	if _, err := os.Stat(path); err == nil {
+		rotated = append(rotated, path)
+	}
+	return rotated
+}
+
+// readJournal parses a journal file. A torn final line from a crash mid-write is skipped.
+func readJournal(path string) ([]batchRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []batchRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		records = append(records, batchRecord{
+			appName:      entry.AppName,
+			processID:    entry.ProcessID,
+			protocol:     entry.Protocol,
+			family:       entry.Family,
+			upload:       entry.Upload,
+			download:     entry.Download,
+			timestamp:    entry.Timestamp,
+			isTemporary:  entry.IsTemporary,
+			expiresAt:    entry.ExpiresAt,
+			sessionLabel: entry.Label,
+			screenState:  entry.Screen,
+			key:          entry.Key,
+		})
+	}
+	return records, scanner.Err()
+}
+
+// EnableJournal replays any records a previous run left in the journal at path, then starts
+// journaling the batch there. It returns the number of replayed records that were not yet stored.
+func (m *Monitor) EnableJournal(path string) (int, error) {
+	replayed, err := m.replayJournal(path)
+	if err != nil {
+		return replayed, err
+	}
+
+	m.batchMux.Lock()
+	defer m.batchMux.Unlock()
+	if m.journal != nil {
+		return replayed, nil
+	}
+	journal, err := openJournal(path)
+	if err != nil {
+		return replayed, err
+	}
+	// Records already batched would otherwise be missing from the journal
+	if len(m.batch) > 0 {
+		if err := journal.append(m.batch); err != nil {
+			fmt.Printf("Failed to write batch journal: %v\n", err)
+		}
+	}
+	m.journal = journal
+	return replayed, nil
+}
+
+// DisableJournal stops journaling and removes the journal file. Batched records are still
+// flushed as usual.
+func (m *Monitor) DisableJournal() {
+	m.batchMux.Lock()
+	defer m.batchMux.Unlock()
+	if m.journal == nil {
+		return
+	}
+	m.journal.close()
+	os.Remove(m.journal.path)
+	m.journal = nil
+}
+
+// replayJournal stores every journaled record not already in the database and removes the files.
+// Each file mirrors the batch a flush took, so it is aggregated the way that flush did: a bucket
+// that was already stored carries the same key as the replayed one and is skipped.
+func (m *Monitor) replayJournal(path string) (int, error) {
+	db, ok := m.db.(*database.DB)
+	if !ok {
+		return 0, nil
+	}
+	m.saveMux.RLock()
+	granularity := m.storageGranularity
+	m.saveMux.RUnlock()
+
+	replayed := 0
+	for _, file := range journalFiles(path) {
+		batch, err := readJournal(file)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read batch journal: %w", err)
+		}
+		inserted, err := db.InsertMissingUsageRecords(toUsageRecords(aggregateBatch(batch, granularity)))
+		if err != nil {
+			return replayed, fmt.Errorf("failed to replay batch journal: %w", err)
+		}
+		updateSummaries(db, inserted)
+		replayed += len(inserted)
+		if err := os.Remove(file); err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
+
+// rotateJournal sets the journaled records aside for the flush that just took the batch.
+// Called with batchMux held; returns "" when journaling is off.
+func (m *Monitor) rotateJournal() string {
+	if m.journal == nil {
+		return ""
+	}
+	rotated, err := m.journal.rotate()
+	if err != nil {
+		fmt.Printf("Failed to rotate batch journal: %v\n", err)
+		return ""
+	}
+	return rotated
+}
+
+// discardJournal removes a rotated journal once its records are stored. A failed flush keeps
+// its file, so the records are retried at the next startup.
+func (m *Monitor) discardJournal(rotated string) {
+	if rotated == "" {
+		return
+	}
+	if err := os.Remove(rotated); err != nil {
+		fmt.Printf("Failed to remove batch journal: %v\n", err)
+	}
+}
+
+// resetJournal empties the journal along with the batch. Called with batchMux held.
+func (m *Monitor) resetJournal() {
+	if m.journal == nil {
+		return
+	}
+	if err := m.journal.reset(); err != nil {
+		fmt.Printf("Failed to reset batch journal: %v\n", err)
+	}
+}
@@ -2,28 +2,83 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"netpus/internal/database"
+	"netpus/internal/utils"
 )
 
 const (
 	UPDATE_INTERVAL   = 500 * time.Millisecond // Fast 500ms collection for responsive real-time UI
 	BATCH_INTERVAL    = 10 * time.Second       // Database write interval (zero data loss)
-	CLEANUP_THRESHOLD = 3 * time.Second        // Inactive process cleanup time (3-second timeout)
+	CLEANUP_THRESHOLD = 3 * time.Second        // Inactivity after which an app is marked inactive (3-second timeout)
+	INACTIVE_GRACE    = 60 * time.Second       // How long an inactive app keeps its totals before removal
+	FAILURE_THRESHOLD = 5                      // Consecutive collection errors before reporting degraded
+	WARMUP_SAMPLES    = 2                      // Collections needed before stats are considered meaningful
+	SPEED_HISTORY     = 120                    // Speed samples kept for the live graph (1 minute at 500ms)
+	MAX_TRACKED_APPS  = 500                    // Default cap on apps held in memory
+	BATCH_SIZE_LIMIT  = 5000                   // Pending records that trigger a flush before BATCH_INTERVAL
+
+	CLOCK_JUMP_TOLERANCE = 2 * time.Second  // Wall-clock drift from real elapsed time reported as a clock change
+	RESUME_GAP           = 60 * time.Second // Gap between collections treated as a sleep/resume rather than one interval
+
+	TRANSIENT_APP = "Transient" // App name stored for traffic of processes younger than the minimum lifetime
+	UNKNOWN_APP   = "Unknown"   // App name credited with traffic of processes whose name couldn't be resolved
+)
+
+// Timing controls the monitor's collection and write cadence
+type Timing struct {
+	UpdateInterval   time.Duration // Time between collections
+	BatchInterval    time.Duration // Time between database writes
+	CleanupThreshold time.Duration // Inactivity after which an app is marked inactive
+	InactiveGrace    time.Duration // Further inactivity after which an inactive app leaves the live stats
+	BatchSizeLimit   int           // Pending records that trigger an early write; 0 disables
+}
+
+// DefaultTiming returns the built-in cadence
+func DefaultTiming() Timing {
+	return Timing{
+		UpdateInterval:   UPDATE_INTERVAL,
+		BatchInterval:    BATCH_INTERVAL,
+		CleanupThreshold: CLEANUP_THRESHOLD,
+		InactiveGrace:    INACTIVE_GRACE,
+		BatchSizeLimit:   BATCH_SIZE_LIMIT,
+	}
+}
+
+var (
+	// ErrTableUnavailable means a system network table or API could not be loaded
+	ErrTableUnavailable = errors.New("network table unavailable")
+	// ErrPermissionDenied means the OS refused access to network or process information
+	ErrPermissionDenied = errors.New("permission denied")
 )
 
 // NetworkStat represents network statistics for a single application
 type NetworkStat struct {
 	AppName       string
 	ProcessID     int
-	UploadSpeed   int64 // Bytes per second
-	DownloadSpeed int64 // Bytes per second
-	TotalUpload   int64 // Total bytes uploaded
-	TotalDownload int64 // Total bytes downloaded
-	LastUpdate    time.Time
+	UploadSpeed   int64     // Bytes per second
+	DownloadSpeed int64     // Bytes per second
+	TotalUpload   int64     // Total bytes uploaded
+	TotalDownload int64     // Total bytes downloaded
+	LastUpdate    time.Time // Last activity; holds a monotonic reading, so use it for elapsed-time math
+	UpdatedAt     int64     // Wall-clock Unix time of LastUpdate, for display and storage
+	Inactive      bool      // Idle past the cleanup threshold; kept (with its totals) during the grace period
+	Color         string    // Chart color, filled in by the app from display settings
+	DisplayLabel  string    // Name shown in the UI, filled in by the app from display settings
+}
+
+// SpeedSample represents total upload/download speed at one collection
+type SpeedSample struct {
+	Timestamp     int64 `json:"timestamp"`     // Unix milliseconds
+	UploadSpeed   int64 `json:"uploadSpeed"`   // Bytes per second
+	DownloadSpeed int64 `json:"downloadSpeed"` // Bytes per second
 }
 
 // MonitorStatus represents the current monitor state
@@ -32,82 +87,344 @@ type MonitorStatus struct {
 	Paused         bool      `json:"paused"`
 	UpdateInterval int       `json:"updateInterval"` // Seconds
 	LastUpdate     time.Time `json:"lastUpdate"`
+	Healthy        bool      `json:"healthy"`       // False when collection keeps failing
+	LastError      string    `json:"lastError"`     // Most recent collection error, if any
+	Temporary      bool      `json:"temporary"`     // True while recording a temporary session
+	Warming        bool      `json:"warming"`       // True until enough samples exist for meaningful speeds
+	SaveEnabled    bool      `json:"saveEnabled"`   // False in live-only mode: stats update but nothing is stored
+	Elevated       bool      `json:"elevated"`      // Running as administrator; otherwise some processes may be missing
+	TrackedApps    int       `json:"trackedApps"`   // Apps currently held in memory
+	Interfaces     []string  `json:"interfaces"`    // Aliases of the network interfaces being counted
+	ProxyDetected  bool      `json:"proxyDetected"` // A known proxy/VPN is active, so traffic may be attributed to it instead of the real apps
+	ProxyApp       string    `json:"proxyApp"`      // The busiest active proxy/VPN process
+	ClockJumps     int       `json:"clockJumps"`    // System clock changes noticed since the monitor started
+	SuspectCycles  int       `json:"suspectCycles"` // Collections over the MaxCycleBytes cap, discarded or clamped
+	LastClockJump  ClockJump `json:"lastClockJump"` // Most recent clock change; OffsetSeconds < 0 means records may be out of order
+}
+
+// ConnectionInfo is one row of the TCP/UDP tables used to attribute traffic to processes
+type ConnectionInfo struct {
+	Protocol    string `json:"protocol"`    // "tcp" or "udp"
+	Family      string `json:"family"`      // "ipv4" or "ipv6"
+	LocalAddr   string `json:"localAddr"`   // ip:port
+	RemoteAddr  string `json:"remoteAddr"`  // ip:port; empty for UDP, which has no remote end in the table
+	State       string `json:"state"`       // TCP state such as "ESTABLISHED"; empty for UDP
+	ProcessID   int    `json:"processId"`   // Owning process
+	ProcessName string `json:"processName"` // Empty when the process couldn't be resolved
+}
+
+// InterfaceInfo describes one network interface and its cumulative byte counters
+type InterfaceInfo struct {
+	LUID          uint64 `json:"luid,string"`   // Locally unique identifier, stable while the adapter exists
+	Alias         string `json:"alias"`         // Name shown in Windows, e.g. "Wi-Fi"
+	Description   string `json:"description"`   // Adapter description
+	Up            bool   `json:"up"`            // Operationally up
+	BytesSent     uint64 `json:"bytesSent"`     // Since the adapter started
+	BytesReceived uint64 `json:"bytesReceived"` // Since the adapter started
+}
+
+// FlushStats describes how the batch write pipeline is behaving
+type FlushStats struct {
+	Flushes         int64  `json:"flushes"`         // Batches written successfully
+	FailedFlushes   int64  `json:"failedFlushes"`   // Batches the database rejected
+	RecordsFlushed  int64  `json:"recordsFlushed"`  // Records written successfully
+	LastDurationMs  int64  `json:"lastDurationMs"`  // Time the most recent flush took
+	TotalDurationMs int64  `json:"totalDurationMs"` // Time spent in all flushes
+	LastFlushAt     int64  `json:"lastFlushAt"`     // Unix timestamp of the most recent successful flush
+	LastError       string `json:"lastError"`       // Most recent flush error, if any
+	LastFailed      bool   `json:"lastFailed"`      // The most recent flush failed; LastError is its error
+	PendingRecords  int    `json:"pendingRecords"`  // Records waiting in the current batch
 }
 
 // Monitor represents the network monitoring system
 type Monitor struct {
+	parentCtx   context.Context
 	ctx         context.Context
 	cancel      context.CancelFunc
+	runMux      sync.Mutex
+	stopped     bool       // Set by Stop; loops are never started again afterwards
+	restartMux  sync.Mutex // Serializes restarts so only one set of loops runs
+	loops       sync.WaitGroup
+	collectMux  sync.Mutex // Serializes collections from the ticker and manual refreshes
 	db          interface{}
 	stats       map[string]*NetworkStat
 	statsMux    sync.RWMutex
+	statsGen    uint64 // Bumped whenever entries are pruned or marked inactive outside collect (guarded by statsMux)
 	batch       []batchRecord
 	batchMux    sync.Mutex
+	keyPrefix   string        // Makes record keys unique across runs
+	keySeq      uint64        // Last record key issued (guarded by batchMux)
+	journal     *batchJournal // Crash journal mirroring batch; nil when disabled (guarded by batchMux)
 	paused      bool
 	pauseMux    sync.RWMutex
 	lastUpdate  time.Time
 	saveEnabled bool
 	saveMux     sync.RWMutex
+
+	// Canonical names of apps that are never tracked
+	excludedApps map[string]bool
+	excludeMux   sync.RWMutex
+
+	// Canonical names of proxy/VPN processes whose traffic really belongs to other apps (guarded by excludeMux)
+	proxyApps map[string]bool
+
+	// Apps temporarily kept out of the batch (still shown live), keyed by canonical name (guarded by excludeMux)
+	pausedApps map[string]*pausedApp
+
+	// PID to process name cache reused across collections
+	procCache *processCache
+
+	// Where collections get per-app deltas from; the platform collector unless replaced (e.g. in tests)
+	source collectSource
+
+	// Fixed-size ring buffer of recent total speeds
+	samples     [SPEED_HISTORY]SpeedSample
+	sampleNext  int
+	sampleCount int
+	samplesMux  sync.Mutex
+
+	// Whether the process runs with administrator privileges (checked once at creation)
+	elevated bool
+
+	// Upper bound on entries in stats; least recently updated apps are evicted first
+	maxTrackedApps int
+
+	// Warmup: stats are flagged as warming until warmupSamples collections succeeded
+	warmupSamples    int
+	samplesCollected int
+
+	// Wall-clock jumps seen between collections (guarded by statsMux)
+	clockJumps    int
+	lastClockJump ClockJump
+
+	// Collections whose bytes exceeded maxCycleBytes (guarded by statsMux)
+	suspectCycles int
+
+	// Collection health tracking
+	consecutiveFailures int
+	lastError           string
+	healthMux           sync.RWMutex
+
+	// Temporary session: records are marked temporary and expire after tempTTL
+	tempSession bool
+	tempTTL     time.Duration
+	tempMux     sync.RWMutex
+
+	// Label stamped on records while a labeled session is open (guarded by tempMux)
+	sessionLabel string
+	// Screen lock state stamped on records, set by the caller's lock watcher (guarded by tempMux)
+	screenState string
+
+	// Raw system-wide bytes waiting to be written to system_usage (guarded by batchMux)
+	pendingSystem systemDelta
+	// Bytes per destination label waiting to be written to destination_usage (guarded by batchMux)
+	pendingDestinations map[string]*systemDelta
+	storeSystemTotals   bool
+
+	// Sanity cap on one collection's bytes, 0 to disable; over it the cycle is clamped or discarded (guarded by saveMux)
+	maxCycleBytes int64
+	clampCycles   bool
+
+	// Processes younger than this are stored under TRANSIENT_APP instead of their own name (guarded by saveMux)
+	minProcessLifetime time.Duration
+	// Width of the buckets records are summed into before storing, 0 to store each collection (guarded by saveMux)
+	storageGranularity time.Duration
+
+	// Cumulative bytes observed since the monitor was created
+	sessionUpload   int64
+	sessionDownload int64
+
+	// Batch write counters (PendingRecords is filled in on read)
+	flushStats FlushStats
+	flushMux   sync.Mutex
+
+	// Collection and write cadence
+	timing    Timing
+	timingMux sync.RWMutex
+
+	// Signals the batch write loop to flush early once the batch reaches BatchSizeLimit
+	flushNow chan struct{}
+
+	// IP ranges that label destinations (e.g. a CDN), checked in order
+	destinationRules []destinationRule
+	destinationMux   sync.RWMutex
+}
+
+// collectSource returns per-app byte deltas since its previous call, plus the raw system-wide delta
+type collectSource func(cache *processCache) (map[string]processData, systemDelta, error)
+
+// pausedApp is a temporary tracking pause that lifts itself when its timer fires
+type pausedApp struct {
+	until time.Time
+	timer *time.Timer
+}
+
+// systemDelta is the raw system-wide byte delta for one collection, before attribution
+type systemDelta struct {
+	upload   int64
+	download int64
 }
 
 type batchRecord struct {
-	appName     string
-	processID   int
-	upload      int64
-	download    int64
-	timestamp   int64
-	isTemporary bool
-	expiresAt   int64
+	appName      string
+	processID    int
+	protocol     string
+	family       string
+	upload       int64
+	download     int64
+	timestamp    int64
+	isTemporary  bool
+	expiresAt    int64
+	sessionLabel string
+	screenState  string
+	key          string // Unique per collected record; an aggregated record keeps its first record's key
 }
 
 // New creates a new Monitor instance
 func New(db interface{}) *Monitor {
 	return &Monitor{
-		db:          db,
-		stats:       make(map[string]*NetworkStat),
-		batch:       make([]batchRecord, 0),
-		saveEnabled: true,
+		db:               db,
+		stats:            make(map[string]*NetworkStat),
+		batch:            make([]batchRecord, 0),
+		saveEnabled:      true,
+		warmupSamples:    WARMUP_SAMPLES,
+		maxTrackedApps:   MAX_TRACKED_APPS,
+		elevated:         IsElevated(),
+		procCache:        newProcessCache(),
+		source:           getNetworkProcesses,
+		excludedApps:     make(map[string]bool),
+		pausedApps:       make(map[string]*pausedApp),
+		proxyApps:        canonicalSet(utils.DefaultProxyApps),
+		timing:           DefaultTiming(),
+		destinationRules: parseDestinationRules(utils.DefaultDestinationRules),
+		flushNow:         make(chan struct{}, 1),
+		keyPrefix:        strconv.FormatInt(time.Now().UnixNano(), 36),
+	}
+}
+
+// canonicalSet builds a set of canonical app names
+func canonicalSet(apps []string) map[string]bool {
+	set := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		set[utils.CanonicalAppName(app)] = true
 	}
+	return set
 }
 
 // Start begins network monitoring
 func (m *Monitor) Start(ctx context.Context) error {
+	m.runMux.Lock()
+	m.parentCtx = ctx
 	m.ctx, m.cancel = context.WithCancel(ctx)
+	runCtx := m.ctx
+	m.runMux.Unlock()
 
-	// Initialize monitoring with 2 collections
+	// Take the first sample to establish a baseline; the loop collects the rest of the warmup.
+	// When starting paused, the first collection after Resume takes it instead.
 	fmt.Println("Initializing network monitor...")
-	if err := m.collect(); err != nil {
-		return fmt.Errorf("initial collection failed: %w", err)
-	}
-	time.Sleep(UPDATE_INTERVAL)
-	if err := m.collect(); err != nil {
-		return fmt.Errorf("second collection failed: %w", err)
+	m.pauseMux.RLock()
+	paused := m.paused
+	m.pauseMux.RUnlock()
+	if !paused {
+		if err := m.collect(); err != nil {
+			return fmt.Errorf("initial collection failed: %w", err)
+		}
 	}
 
-	// Start monitoring loops
-	go m.monitorLoop()
-	go m.batchWriteLoop()
+	// Start monitoring loops, unless Stop ran during the first collection
+	m.runMux.Lock()
+	if !m.stopped {
+		m.startLoops(runCtx)
+	}
+	m.runMux.Unlock()
 
 	fmt.Println("Network monitor started successfully")
 	return nil
 }
 
-// Stop stops network monitoring and flushes remaining data
+// Restart stops the monitoring loops, if any, waits for them to exit and starts fresh ones.
+// It does nothing once Stop has been called.
+func (m *Monitor) Restart() {
+	m.restartMux.Lock()
+	defer m.restartMux.Unlock()
+
+	m.runMux.Lock()
+	if m.stopped {
+		m.runMux.Unlock()
+		return
+	}
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.runMux.Unlock()
+
+	m.loops.Wait()
+
+	// Stop may have run while the old loops were exiting. Loops are only added under runMux
+	// while not stopped, so they are always added before Stop starts waiting.
+	m.runMux.Lock()
+	if m.stopped {
+		m.runMux.Unlock()
+		return
+	}
+	parent := m.parentCtx
+	if parent == nil {
+		parent = context.Background()
+		m.parentCtx = parent
+	}
+	m.ctx, m.cancel = context.WithCancel(parent)
+	m.startLoops(m.ctx)
+	m.runMux.Unlock()
+
+	fmt.Println("Network monitor restarted")
+}
+
+// startLoops launches the collection and batch write loops
+func (m *Monitor) startLoops(ctx context.Context) {
+	m.loops.Add(2)
+	go func() {
+		defer m.loops.Done()
+		m.monitorLoop(ctx)
+	}()
+	go func() {
+		defer m.loops.Done()
+		m.batchWriteLoop(ctx)
+	}()
+}
+
+// Stop stops network monitoring and flushes remaining data.
+// It blocks until the loops have exited and the final batch is written, so the
+// database can be closed safely afterwards.
 func (m *Monitor) Stop() {
+	m.runMux.Lock()
+	m.stopped = true
 	if m.cancel != nil {
 		m.cancel()
 	}
-	m.flushBatch()
+	m.runMux.Unlock()
+
+	m.loops.Wait()
+
+	// Pending resume timers would otherwise fire after shutdown
+	m.excludeMux.Lock()
+	for name, paused := range m.pausedApps {
+		paused.timer.Stop()
+		delete(m.pausedApps, name)
+	}
+	m.excludeMux.Unlock()
+
+	m.flush(true)
+	fmt.Println("Network monitor stopped, final batch flushed")
 }
 
 // monitorLoop is the main monitoring loop
-func (m *Monitor) monitorLoop() {
-	ticker := time.NewTicker(UPDATE_INTERVAL)
+func (m *Monitor) monitorLoop(ctx context.Context) {
+	ticker := time.NewTicker(m.GetTiming().UpdateInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-m.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			m.pauseMux.RLock()
@@ -115,32 +432,115 @@ func (m *Monitor) monitorLoop() {
 			m.pauseMux.RUnlock()
 
 			if !paused {
-				if err := m.collect(); err != nil {
+				err := m.collect()
+				if err != nil {
 					fmt.Printf("Collection error: %v\n", err)
 				}
+				m.recordCollectResult(err)
 				m.cleanupInactive()
 			}
 		}
 	}
 }
 
+// CollectNow runs a collection immediately (unless paused) and returns the updated stats
+func (m *Monitor) CollectNow() map[string]*NetworkStat {
+	m.pauseMux.RLock()
+	paused := m.paused
+	m.pauseMux.RUnlock()
+
+	if !paused {
+		err := m.collect()
+		if err != nil {
+			fmt.Printf("Collection error: %v\n", err)
+		}
+		m.recordCollectResult(err)
+	}
+	return m.GetStats()
+}
+
+// recordCollectResult updates health tracking after a collection attempt
+func (m *Monitor) recordCollectResult(err error) {
+	m.healthMux.Lock()
+	defer m.healthMux.Unlock()
+
+	if err == nil {
+		m.consecutiveFailures = 0
+		m.lastError = ""
+		return
+	}
+
+	// Missing system tables won't recover on their own, so degrade immediately
+	if errors.Is(err, ErrTableUnavailable) {
+		m.consecutiveFailures = FAILURE_THRESHOLD
+	} else {
+		m.consecutiveFailures++
+	}
+	m.lastError = err.Error()
+}
+
+// isHealthy reports whether collection is working
+func (m *Monitor) isHealthy() (bool, string) {
+	m.healthMux.RLock()
+	defer m.healthMux.RUnlock()
+	return m.consecutiveFailures < FAILURE_THRESHOLD, m.lastError
+}
+
 // batchWriteLoop handles periodic database writes
-func (m *Monitor) batchWriteLoop() {
-	ticker := time.NewTicker(BATCH_INTERVAL)
+func (m *Monitor) batchWriteLoop(ctx context.Context) {
+	ticker := time.NewTicker(m.GetTiming().BatchInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-m.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			m.flushBatch()
+		case <-m.flushNow:
+			m.flushBatch()
 		}
 	}
 }
 
+// GetTiming returns the current collection and write cadence
+func (m *Monitor) GetTiming() Timing {
+	m.timingMux.RLock()
+	defer m.timingMux.RUnlock()
+	return m.timing
+}
+
+// SetTiming changes the collection and write cadence. Running loops are restarted when an
+// interval changes, so the new tickers take effect immediately.
+func (m *Monitor) SetTiming(timing Timing) {
+	m.timingMux.Lock()
+	previous := m.timing
+	m.timing = timing
+	m.timingMux.Unlock()
+
+	if timing.UpdateInterval == previous.UpdateInterval && timing.BatchInterval == previous.BatchInterval {
+		return
+	}
+	m.runMux.Lock()
+	running := !m.stopped && m.ctx != nil && m.ctx.Err() == nil
+	m.runMux.Unlock()
+	if running {
+		m.Restart()
+	}
+}
+
 // collect gathers network statistics (platform-specific implementation)
-func (m *Monitor) collect() error {
+func (m *Monitor) collect() (err error) {
+	m.collectMux.Lock()
+	defer m.collectMux.Unlock()
+
+	// A panic in a syscall wrapper must not kill the monitoring loop
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("collection panic: %v", r)
+		}
+	}()
+
 	m.pauseMux.RLock()
 	if m.paused {
 		m.pauseMux.RUnlock()
@@ -148,17 +548,90 @@ func (m *Monitor) collect() error {
 	}
 	m.pauseMux.RUnlock()
 
-	// Get network data from platform-specific implementation
-	// NOTE: getNetworkProcesses() now returns DELTA bytes (bytes transferred since last call)
+	// Get network data from the collection source (the platform-specific implementation by default)
+	// NOTE: the source returns DELTA bytes (bytes transferred since last call)
 	// distributed proportionally to processes with active connections
-	processes, err := getNetworkProcesses()
+	processes, sysDelta, err := m.source(m.procCache)
+	now := time.Now()
+
+	// After a sleep/resume the delta covers the whole gap; reading it as one interval would show a
+	// bogus multi-GB/s spike, so drop it and start measuring again from here
+	m.statsMux.RLock()
+	prevUpdate := m.lastUpdate
+	m.statsMux.RUnlock()
+	if !prevUpdate.IsZero() {
+		if monotonic, wall := elapsedClocks(prevUpdate, now); resumedAfterGap(monotonic, wall) {
+			m.rebaseline(now, monotonic, wall)
+			return err
+		}
+	}
+
+	// A counter reset can report gigabytes in one cycle; keep it out of live speeds and stored totals
+	var elapsed time.Duration
+	if !prevUpdate.IsZero() {
+		elapsed, _ = elapsedClocks(prevUpdate, now)
+	}
+	var keep bool
+	if processes, sysDelta, keep = m.capCycle(processes, sysDelta, elapsed); !keep {
+		return err
+	}
+
+	// Keep the raw system delta even if attribution failed
+	m.batchMux.Lock()
+	m.pendingSystem.upload += sysDelta.upload
+	m.pendingSystem.download += sysDelta.download
+	m.batchMux.Unlock()
+
 	if err != nil {
 		return err
 	}
 
-	now := time.Now()
-	m.statsMux.Lock()
-	defer m.statsMux.Unlock()
+	// Drop excluded apps before they reach stats or the batch
+	// Temporarily paused apps still get live stats but nothing is batched for them
+	m.excludeMux.RLock()
+	pausedNow := make(map[string]bool)
+	for appName := range processes {
+		canonical := utils.CanonicalAppName(appName)
+		if m.excludedApps[canonical] {
+			delete(processes, appName)
+		} else if _, paused := m.pausedApps[canonical]; paused {
+			pausedNow[appName] = true
+		}
+	}
+	m.excludeMux.RUnlock()
+	m.addDestinations(processes, pausedNow)
+
+	// Records expire after 24 hours unless a temporary session sets its own TTL
+	m.tempMux.RLock()
+	isTemporary := m.tempSession
+	ttl := 24 * time.Hour
+	if isTemporary {
+		ttl = m.tempTTL
+	}
+	sessionLabel := m.sessionLabel
+	screenState := m.screenState
+	m.tempMux.RUnlock()
+
+	expiresAt := now.Add(ttl).Unix()
+
+	// Build the next stats map from a snapshot so readers are only blocked by the final swap
+	m.statsMux.RLock()
+	stats := make(map[string]*NetworkStat, len(m.stats))
+	for k, v := range m.stats {
+		statCopy := *v
+		stats[k] = &statCopy
+	}
+	gen := m.statsGen
+	m.statsMux.RUnlock()
+
+	m.saveMux.RLock()
+	minLifetime := m.minProcessLifetime
+	m.saveMux.RUnlock()
+
+	var newRecords []batchRecord
+	var sessionUp, sessionDown int64
+	transient := make(map[trafficKind]*batchRecord)
+	var transientKinds []trafficKind
 
 	// Update stats with delta values directly
 	for appName, data := range processes {
@@ -172,16 +645,17 @@ func (m *Monitor) collect() error {
 		}
 
 		// Get or create stat entry
-		stat, exists := m.stats[appName]
+		stat, exists := stats[appName]
 		if !exists {
 			stat = &NetworkStat{
 				AppName:   appName,
 				ProcessID: data.processID,
 			}
-			m.stats[appName] = stat
+			stats[appName] = stat
 		}
 
-		// Calculate time delta for speed calculation
+		// Calculate time delta for speed calculation. LastUpdate holds a time.Now() reading, so Sub
+		// uses the monotonic clock and wall-clock changes can't skew the speed.
 		timeDelta := 1.0 // Default 1 second
 		if !stat.LastUpdate.IsZero() {
 			timeDelta = now.Sub(stat.LastUpdate).Seconds()
@@ -196,36 +670,189 @@ func (m *Monitor) collect() error {
 		stat.TotalUpload += uploadDelta
 		stat.TotalDownload += downloadDelta
 		stat.LastUpdate = now
+		stat.UpdatedAt = now.Unix()
+		stat.Inactive = false
 
-		// Add to batch for database storage
-		m.batchMux.Lock()
-		expiresAt := now.Add(24 * time.Hour).Unix()
-		m.batch = append(m.batch, batchRecord{
-			appName:     appName,
-			processID:   data.processID,
-			upload:      uploadDelta,
-			download:    downloadDelta,
-			timestamp:   now.Unix(),
-			isTemporary: false,
-			expiresAt:   expiresAt,
-		})
-		m.batchMux.Unlock()
+		sessionUp += uploadDelta
+		sessionDown += downloadDelta
+
+		if pausedNow[appName] {
+			continue
+		}
+		// Short-lived processes still show live, but are stored in one bucket so they don't clutter history
+		if minLifetime > 0 && !data.firstSeen.IsZero() && now.Sub(data.firstSeen) < minLifetime {
+			for _, share := range data.sortedShares() {
+				rec, ok := transient[share.kind]
+				if !ok {
+					rec = &batchRecord{
+						appName:      TRANSIENT_APP,
+						protocol:     share.kind.protocol,
+						family:       share.kind.family,
+						timestamp:    now.Unix(),
+						isTemporary:  isTemporary,
+						expiresAt:    expiresAt,
+						sessionLabel: sessionLabel,
+						screenState:  screenState,
+					}
+					transient[share.kind] = rec
+					transientKinds = append(transientKinds, share.kind)
+				}
+				rec.upload += share.upload
+				rec.download += share.download
+			}
+			continue
+		}
+		// One record per transport and family, so stored usage keeps the app's TCP/UDP split
+		for _, share := range data.sortedShares() {
+			if share.upload == 0 && share.download == 0 {
+				continue
+			}
+			newRecords = append(newRecords, batchRecord{
+				appName:      appName,
+				processID:    data.processID,
+				protocol:     share.kind.protocol,
+				family:       share.kind.family,
+				upload:       share.upload,
+				download:     share.download,
+				timestamp:    now.Unix(),
+				isTemporary:  isTemporary,
+				expiresAt:    expiresAt,
+				sessionLabel: sessionLabel,
+				screenState:  screenState,
+			})
+		}
+	}
+
+	for _, kind := range transientKinds {
+		newRecords = append(newRecords, *transient[kind])
 	}
 
 	// Reset speeds for apps that didn't have activity this cycle
-	for appName, stat := range m.stats {
+	var totalUpSpeed, totalDownSpeed int64
+	for appName, stat := range stats {
 		if _, hasActivity := processes[appName]; !hasActivity {
 			stat.UploadSpeed = 0
 			stat.DownloadSpeed = 0
 		}
+		totalUpSpeed += stat.UploadSpeed
+		totalDownSpeed += stat.DownloadSpeed
 	}
+	m.recordSample(SpeedSample{
+		Timestamp:     now.UnixMilli(),
+		UploadSpeed:   totalUpSpeed,
+		DownloadSpeed: totalDownSpeed,
+	})
 
+	// Add to batch for database storage, unless saving is off (live-only mode)
+	m.saveMux.RLock()
+	saveEnabled := m.saveEnabled
+	m.saveMux.RUnlock()
+	if saveEnabled && len(newRecords) > 0 {
+		m.batchMux.Lock()
+		for i := range newRecords {
+			m.keySeq++
+			newRecords[i].key = m.keyPrefix + "-" + strconv.FormatUint(m.keySeq, 36)
+		}
+		m.batch = append(m.batch, newRecords...)
+		if m.journal != nil {
+			if err := m.journal.append(newRecords); err != nil {
+				fmt.Printf("Failed to write batch journal: %v\n", err)
+			}
+		}
+		pending := len(m.batch)
+		m.batchMux.Unlock()
+
+		// A burst of activity shouldn't hold an ever-growing batch until the next interval
+		if limit := m.GetTiming().BatchSizeLimit; limit > 0 && pending >= limit {
+			select {
+			case m.flushNow <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	m.excludeMux.RLock()
+	excluded := m.excludedApps
+	m.excludeMux.RUnlock()
+
+	// Swap in the new stats under a brief write lock
+	m.statsMux.Lock()
+	m.reconcileStats(stats, gen, now, excluded)
+	m.stats = stats
+	m.sessionUpload += sessionUp
+	m.sessionDownload += sessionDown
+	m.checkClock(prevUpdate, now)
 	m.lastUpdate = now
+	m.samplesCollected++
+	m.statsMux.Unlock()
+
 	return nil
 }
 
-// flushBatch writes batched records to database
+// capCycle checks a collection's bytes against the sanity cap. The cap is set for a collection
+// UPDATE_INTERVAL long and grows with the real elapsed (monotonic) time, so longer intervals and late
+// collections aren't flagged for ordinary traffic; a collection is never held to less than the
+// configured interval allows. An oversized cycle is either scaled down to the cap or dropped (keep
+// is false); both are logged.
+func (m *Monitor) capCycle(processes map[string]processData, delta systemDelta, elapsed time.Duration) (map[string]processData, systemDelta, bool) {
+	m.saveMux.RLock()
+	capBytes, clamp := m.maxCycleBytes, m.clampCycles
+	m.saveMux.RUnlock()
+	if capBytes <= 0 {
+		return processes, delta, true
+	}
+	if interval := m.GetTiming().UpdateInterval; elapsed < interval {
+		elapsed = interval
+	}
+	limit := int64(math.Min(float64(capBytes)*float64(elapsed)/float64(UPDATE_INTERVAL), math.MaxInt64))
+
+	var attributed int64
+	for _, data := range processes {
+		attributed += data.uploadBytes + data.downloadBytes
+	}
+	total := delta.upload + delta.download
+	if attributed > total {
+		total = attributed
+	}
+	if total <= limit {
+		return processes, delta, true
+	}
+
+	m.statsMux.Lock()
+	m.suspectCycles++
+	m.statsMux.Unlock()
+
+	if !clamp {
+		fmt.Printf("Discarding collection of %s (over the %s cycle cap)\n",
+			utils.FormatBytes(total), utils.FormatBytes(limit))
+		return nil, systemDelta{}, false
+	}
+	fmt.Printf("Clamping collection of %s to the %s cycle cap\n", utils.FormatBytes(total), utils.FormatBytes(limit))
+
+	scale := float64(limit) / float64(total)
+	scaled := func(n int64) int64 { return int64(float64(n) * scale) }
+	for appName, data := range processes {
+		processes[appName] = data.scaled(scaled)
+	}
+	delta.upload = scaled(delta.upload)
+	delta.download = scaled(delta.download)
+	return processes, delta, true
+}
+
+// flushBatch writes batched records to database. Storage buckets that are still open stay in the
+// batch, so collections later in the bucket add to the same record.
 func (m *Monitor) flushBatch() {
+	m.flush(false)
+}
+
+// flush writes batched records to the database; final also writes buckets that are still open
+func (m *Monitor) flush(final bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Recovered from panic while flushing batch: %v\n", r)
+		}
+	}()
+
 	// Check if saving is enabled
 	m.saveMux.RLock()
 	if !m.saveEnabled {
@@ -233,102 +860,395 @@ func (m *Monitor) flushBatch() {
 		// Clear the batch without saving
 		m.batchMux.Lock()
 		m.batch = make([]batchRecord, 0)
+		m.pendingSystem = systemDelta{}
+		m.pendingDestinations = nil
+		m.resetJournal()
 		m.batchMux.Unlock()
 		return
 	}
+	storeSystem := m.storeSystemTotals
+	granularity := m.storageGranularity
 	m.saveMux.RUnlock()
 
 	m.batchMux.Lock()
+	system := m.pendingSystem
+	m.pendingSystem = systemDelta{}
+	destinations := m.pendingDestinations
+	m.pendingDestinations = nil
 	if len(m.batch) == 0 {
 		m.batchMux.Unlock()
+		if storeSystem {
+			m.flushSystemUsage(system)
+		}
+		m.flushDestinations(destinations)
 		return
 	}
 
-	batch := m.batch
+	batch := aggregateBatch(m.batch, granularity)
 	m.batch = make([]batchRecord, 0)
+	if !final {
+		batch, m.batch = splitOpenBuckets(batch, granularity, time.Now())
+	}
+	rotated := m.rotateJournal()
+	// The rotated journal is discarded once the closed buckets are stored, so journal the open ones again
+	if m.journal != nil && len(m.batch) > 0 {
+		if err := m.journal.append(m.batch); err != nil {
+			fmt.Printf("Failed to write batch journal: %v\n", err)
+		}
+	}
 	m.batchMux.Unlock()
 
-	// Convert batch records to database records
-	var totalUpload, totalDownload int64
-	records := make([]database.UsageRecord, len(batch))
-	appMetadataMap := make(map[string]database.AppMetadata)
-	now := time.Now().Unix()
+	if storeSystem {
+		m.flushSystemUsage(system)
+	}
+	m.flushDestinations(destinations)
+
+	if len(batch) == 0 {
+		m.discardJournal(rotated)
+		return
+	}
+	records := toUsageRecords(batch)
+
+	// Write to database with proper error handling
+	if db, ok := m.db.(*database.DB); ok {
+		started := time.Now()
+		if err := db.BatchInsertUsageRecords(records); err != nil {
+			fmt.Printf("Failed to insert batch records: %v\n", err)
+			m.recordFlush(len(records), time.Since(started), err)
+			return
+		}
+		m.discardJournal(rotated)
+
+		totalUpload, totalDownload := updateSummaries(db, records)
+
+		m.recordFlush(len(records), time.Since(started), nil)
+		fmt.Printf("Successfully flushed %d records (%s up, %s down)\n",
+			len(batch), utils.FormatBytes(totalUpload), utils.FormatBytes(totalDownload))
+	}
+}
+
+// aggregateBatch sums records per app into buckets granularity wide, stamped with the bucket start.
+// Records only merge when everything else stored with them (protocol, family, temporary, session
+// label, screen state) matches, and no bucket reaches back past local midnight, so daily summaries
+// come out the same. Each bucket keeps the process of its busiest record. A bucket split across
+// two flushes (only when a flush writes open buckets, such as the final one) is stored as two
+// records with the same timestamp. Aggregating records that are already aggregated changes nothing.
+func aggregateBatch(batch []batchRecord, granularity time.Duration) []batchRecord {
+	seconds := int64(granularity / time.Second)
+	if seconds <= 1 || len(batch) == 0 {
+		return batch
+	}
+
+	type bucketKey struct {
+		appName      string
+		protocol     string
+		family       string
+		timestamp    int64
+		isTemporary  bool
+		sessionLabel string
+		screenState  string
+	}
+	type bucket struct {
+		record  batchRecord
+		busiest int64 // Bytes of the record the process came from
+	}
+
+	buckets := make(map[bucketKey]*bucket)
+	var order []bucketKey
+	for _, rec := range batch {
+		start := rec.timestamp - rec.timestamp%seconds
+		if dayStart := localDayStart(rec.timestamp); start < dayStart {
+			start = dayStart
+		}
+		key := bucketKey{rec.appName, rec.protocol, rec.family, start, rec.isTemporary, rec.sessionLabel, rec.screenState}
+
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{record: rec, busiest: -1}
+			b.record.timestamp = start
+			b.record.upload, b.record.download = 0, 0
+			buckets[key] = b
+			order = append(order, key)
+		}
+		if total := rec.upload + rec.download; total > b.busiest {
+			b.busiest = total
+			b.record.processID = rec.processID
+		}
+		b.record.upload += rec.upload
+		b.record.download += rec.download
+		if rec.expiresAt > b.record.expiresAt {
+			b.record.expiresAt = rec.expiresAt
+		}
+	}
 
+	aggregated := make([]batchRecord, 0, len(order))
+	for _, key := range order {
+		aggregated = append(aggregated, buckets[key].record)
+	}
+	return aggregated
+}
+
+// splitOpenBuckets separates aggregated records whose bucket has ended by now from those still
+// collecting. Without buckets (granularity under two seconds) every record is closed.
+func splitOpenBuckets(batch []batchRecord, granularity time.Duration, now time.Time) (closed, open []batchRecord) {
+	seconds := int64(granularity / time.Second)
+	if seconds <= 1 {
+		return batch, nil
+	}
+	for _, rec := range batch {
+		// Bucket starts may be clamped to midnight; the bucket still ends on the aligned boundary
+		end := rec.timestamp - rec.timestamp%seconds + seconds
+		if end > now.Unix() {
+			open = append(open, rec)
+		} else {
+			closed = append(closed, rec)
+		}
+	}
+	return closed, open
+}
+
+// localDayStart returns the Unix timestamp of local midnight on the day of timestamp
+func localDayStart(timestamp int64) int64 {
+	t := time.Unix(timestamp, 0)
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location()).Unix()
+}
+
+// toUsageRecords converts batch records to database records
+func toUsageRecords(batch []batchRecord) []database.UsageRecord {
+	records := make([]database.UsageRecord, len(batch))
 	for i, rec := range batch {
 		records[i] = database.UsageRecord{
 			AppName:       rec.appName,
 			ProcessID:     rec.processID,
+			Protocol:      rec.protocol,
+			AddressFamily: rec.family,
 			UploadBytes:   rec.upload,
 			DownloadBytes: rec.download,
 			Timestamp:     rec.timestamp,
 			IsTemporary:   rec.isTemporary,
 			ExpiresAt:     rec.expiresAt,
+			SessionLabel:  rec.sessionLabel,
+			ScreenState:   rec.screenState,
+			RecordKey:     rec.key,
 		}
-		totalUpload += rec.upload
-		totalDownload += rec.download
+	}
+	return records
+}
+
+// updateSummaries updates app metadata and daily summaries for records just stored, and returns their totals
+func updateSummaries(db *database.DB, records []database.UsageRecord) (totalUpload, totalDownload int64) {
+	type dayTotal struct {
+		upload   int64
+		download int64
+	}
+	dailyTotals := make(map[string]*dayTotal) // Keyed by each record's own local date
+	appMetadataMap := make(map[string]database.AppMetadata)
+	now := time.Now().Unix()
+
+	for _, rec := range records {
+		totalUpload += rec.UploadBytes
+		totalDownload += rec.DownloadBytes
 
-		// Track app metadata (deduplicate by app name)
-		if _, exists := appMetadataMap[rec.appName]; !exists {
-			appMetadataMap[rec.appName] = database.AppMetadata{
-				AppName:        rec.appName,
-				ExecutablePath: rec.appName, // Could be enhanced with full path
-				FirstSeen:      rec.timestamp,
+		date := time.Unix(rec.Timestamp, 0).Format("2006-01-02")
+		day, exists := dailyTotals[date]
+		if !exists {
+			day = &dayTotal{}
+			dailyTotals[date] = day
+		}
+		day.upload += rec.UploadBytes
+		day.download += rec.DownloadBytes
+
+		// Track app metadata (deduplicate by app name, keeping the earliest timestamp)
+		if meta, exists := appMetadataMap[rec.AppName]; !exists {
+			appMetadataMap[rec.AppName] = database.AppMetadata{
+				AppName:        rec.AppName,
+				ExecutablePath: rec.AppName, // Could be enhanced with full path
+				FirstSeen:      rec.Timestamp,
 				LastSeen:       now,
 			}
+		} else if rec.Timestamp < meta.FirstSeen {
+			meta.FirstSeen = rec.Timestamp
+			appMetadataMap[rec.AppName] = meta
 		}
 	}
 
-	// Write to database with proper error handling
-	if db, ok := m.db.(*database.DB); ok {
-		if err := db.BatchInsertUsageRecords(records); err != nil {
-			fmt.Printf("Failed to insert batch records: %v\n", err)
-			return
+	// Update app metadata
+	for _, metadata := range appMetadataMap {
+		if err := db.UpsertAppMetadata(metadata); err != nil {
+			fmt.Printf("Failed to update app metadata for %s: %v\n", metadata.AppName, err)
 		}
+	}
 
-		// Update app metadata
-		for _, metadata := range appMetadataMap {
-			if err := db.UpsertAppMetadata(metadata); err != nil {
-				fmt.Printf("Failed to update app metadata for %s: %v\n", metadata.AppName, err)
-			}
+	// Update daily summaries, so a batch straddling midnight credits each day correctly
+	for date, day := range dailyTotals {
+		if err := db.UpdateDailySummary(date, day.upload, day.download); err != nil {
+			fmt.Printf("Failed to update daily summary for %s: %v\n", date, err)
 		}
+	}
+	return totalUpload, totalDownload
+}
+
+// recordFlush updates the flush counters after a batch write attempt
+func (m *Monitor) recordFlush(records int, duration time.Duration, err error) {
+	m.flushMux.Lock()
+	defer m.flushMux.Unlock()
+
+	m.flushStats.LastDurationMs = duration.Milliseconds()
+	m.flushStats.TotalDurationMs += duration.Milliseconds()
+	if err != nil {
+		m.flushStats.FailedFlushes++
+		m.flushStats.LastError = err.Error()
+		m.flushStats.LastFailed = true
+		return
+	}
+	m.flushStats.LastFailed = false
+	m.flushStats.Flushes++
+	m.flushStats.RecordsFlushed += int64(records)
+	m.flushStats.LastFlushAt = time.Now().Unix()
+}
+
+// GetFlushStats returns batch write counters and the current pending batch size
+func (m *Monitor) GetFlushStats() FlushStats {
+	m.flushMux.Lock()
+	stats := m.flushStats
+	m.flushMux.Unlock()
+
+	m.batchMux.Lock()
+	stats.PendingRecords = len(m.batch)
+	m.batchMux.Unlock()
+	return stats
+}
+
+// ResetFlushStats clears the batch write counters
+func (m *Monitor) ResetFlushStats() {
+	m.flushMux.Lock()
+	m.flushStats = FlushStats{}
+	m.flushMux.Unlock()
+}
 
-		// Update daily summary
-		today := time.Now().Format("2006-01-02")
-		if err := db.UpdateDailySummary(today, totalUpload, totalDownload); err != nil {
-			fmt.Printf("Failed to update daily summary: %v\n", err)
+// flushSystemUsage writes the raw system-wide delta accumulated since the last flush
+func (m *Monitor) flushSystemUsage(system systemDelta) {
+	if system.upload == 0 && system.download == 0 {
+		return
+	}
+	if db, ok := m.db.(*database.DB); ok {
+		if err := db.InsertSystemUsage(time.Now().Unix(), system.upload, system.download); err != nil {
+			fmt.Printf("Failed to insert system usage: %v\n", err)
 		}
+	}
+}
 
-		fmt.Printf("Successfully flushed %d records (%s up, %s down)\n",
-			len(batch), formatBytes(totalUpload), formatBytes(totalDownload))
+// distributeBytes splits total across PIDs in proportion to weight/totalWeight using the
+// largest-remainder method, so no bytes are lost to rounding. When the weights cover the
+// whole of totalWeight the returned shares sum exactly to total.
+func distributeBytes(total int64, weights map[uint32]float64, totalWeight float64) map[uint32]int64 {
+	shares := make(map[uint32]int64, len(weights))
+	if total <= 0 || totalWeight <= 0 || len(weights) == 0 {
+		return shares
+	}
+
+	type remainder struct {
+		pid  uint32
+		frac float64
+	}
+	remainders := make([]remainder, 0, len(weights))
+
+	var weightSum float64
+	var assigned int64
+	for pid, weight := range weights {
+		exact := float64(total) * weight / totalWeight
+		whole := int64(exact)
+		shares[pid] = whole
+		assigned += whole
+		weightSum += weight
+		remainders = append(remainders, remainder{pid: pid, frac: exact - float64(whole)})
+	}
+
+	// Bytes owed to these PIDs that rounding dropped
+	target := int64(math.Round(float64(total) * weightSum / totalWeight))
+	if target > total {
+		target = total
+	}
+	leftover := target - assigned
+	if leftover <= 0 {
+		return shares
 	}
+
+	sort.Slice(remainders, func(i, j int) bool {
+		if remainders[i].frac != remainders[j].frac {
+			return remainders[i].frac > remainders[j].frac
+		}
+		return remainders[i].pid < remainders[j].pid
+	})
+	for i := int64(0); i < leftover && i < int64(len(remainders)); i++ {
+		shares[remainders[i].pid]++
+	}
+	return shares
 }
 
-// formatBytes is a helper function for logging
-func formatBytes(bytes int64) string {
-	if bytes == 0 {
-		return "0 B"
+// reconcileStats brings a collection's stats map in line with changes made to m.stats since the
+// snapshot it was built from was taken (gen): apps pruned or marked inactive meanwhile keep that
+// state unless they had traffic this cycle, apps excluded meanwhile are dropped, and the current
+// app cap applies. Must be called with statsMux held.
+func (m *Monitor) reconcileStats(stats map[string]*NetworkStat, gen uint64, now time.Time, excluded map[string]bool) {
+	if m.statsGen != gen {
+		for appName, stat := range stats {
+			if stat.LastUpdate.Equal(now) {
+				continue
+			}
+			current, ok := m.stats[appName]
+			if !ok {
+				delete(stats, appName)
+				continue
+			}
+			stat.Inactive = current.Inactive
+		}
 	}
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+	for appName := range stats {
+		if excluded[utils.CanonicalAppName(appName)] {
+			delete(stats, appName)
+		}
+	}
+	evictLeastRecent(stats, m.maxTrackedApps)
+}
+
+// evictLeastRecent removes the least recently updated stats until at most max remain
+func evictLeastRecent(stats map[string]*NetworkStat, max int) {
+	if max <= 0 || len(stats) <= max {
+		return
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	sort.Slice(names, func(i, j int) bool {
+		return stats[names[i]].LastUpdate.Before(stats[names[j]].LastUpdate)
+	})
+	for _, name := range names[:len(names)-max] {
+		delete(stats, name)
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 }
 
-// cleanupInactive removes inactive processes from tracking
+// cleanupInactive marks apps idle past the cleanup threshold as inactive, and removes them once
+// the grace period after that also passes. An app that resumes in between keeps its totals.
 func (m *Monitor) cleanupInactive() {
 	now := time.Now()
+	timing := m.GetTiming()
 	m.statsMux.Lock()
 	defer m.statsMux.Unlock()
 
+	m.statsGen++
 	for appName, stat := range m.stats {
-		if now.Sub(stat.LastUpdate) > CLEANUP_THRESHOLD {
+		idle := now.Sub(stat.LastUpdate)
+		switch {
+		case idle > timing.CleanupThreshold+timing.InactiveGrace:
 			delete(m.stats, appName)
+		case idle > timing.CleanupThreshold:
+			stat.Inactive = true
+			stat.UploadSpeed = 0
+			stat.DownloadSpeed = 0
 		}
 	}
 }
@@ -346,17 +1266,150 @@ func (m *Monitor) GetStats() map[string]*NetworkStat {
 	return stats
 }
 
+// GetTotalSpeed returns the current upload and download speeds summed across all apps, in bytes per second
+func (m *Monitor) GetTotalSpeed() (upload, download int64) {
+	m.statsMux.RLock()
+	defer m.statsMux.RUnlock()
+	for _, stat := range m.stats {
+		upload += stat.UploadSpeed
+		download += stat.DownloadSpeed
+	}
+	return upload, download
+}
+
+// GetAppSpeed returns one app's current upload and download speeds in bytes per second, matching
+// the name case-insensitively and with or without ".exe". Unknown apps report zero.
+func (m *Monitor) GetAppSpeed(appName string) (upload, download int64) {
+	name := utils.CanonicalAppName(appName)
+	m.statsMux.RLock()
+	defer m.statsMux.RUnlock()
+	for key, stat := range m.stats {
+		if utils.CanonicalAppName(key) == name {
+			upload += stat.UploadSpeed
+			download += stat.DownloadSpeed
+		}
+	}
+	return upload, download
+}
+
+// GetConnections returns the connection table as read by the most recent collection that saw
+// traffic, so it matches the attribution behind the displayed stats
+func (m *Monitor) GetConnections() []ConnectionInfo {
+	return connectionSnapshot()
+}
+
+// GetActiveStats returns a copy of statistics for apps currently uploading or downloading
+func (m *Monitor) GetActiveStats() map[string]*NetworkStat {
+	m.statsMux.RLock()
+	defer m.statsMux.RUnlock()
+
+	stats := make(map[string]*NetworkStat)
+	for k, v := range m.stats {
+		if v.UploadSpeed == 0 && v.DownloadSpeed == 0 {
+			continue
+		}
+		statCopy := *v
+		stats[k] = &statCopy
+	}
+	return stats
+}
+
+// recordSample appends a speed sample to the ring buffer, overwriting the oldest when full
+func (m *Monitor) recordSample(sample SpeedSample) {
+	m.samplesMux.Lock()
+	defer m.samplesMux.Unlock()
+
+	m.samples[m.sampleNext] = sample
+	m.sampleNext = (m.sampleNext + 1) % SPEED_HISTORY
+	if m.sampleCount < SPEED_HISTORY {
+		m.sampleCount++
+	}
+}
+
+// GetRecentSamples returns recent total speed samples, oldest first
+func (m *Monitor) GetRecentSamples() []SpeedSample {
+	m.samplesMux.Lock()
+	defer m.samplesMux.Unlock()
+
+	samples := make([]SpeedSample, 0, m.sampleCount)
+	start := (m.sampleNext - m.sampleCount + SPEED_HISTORY) % SPEED_HISTORY
+	for i := 0; i < m.sampleCount; i++ {
+		samples = append(samples, m.samples[(start+i)%SPEED_HISTORY])
+	}
+	return samples
+}
+
+// GetSessionTotals returns the cumulative bytes observed since the monitor was created
+func (m *Monitor) GetSessionTotals() (upload, download int64) {
+	m.statsMux.RLock()
+	defer m.statsMux.RUnlock()
+	return m.sessionUpload, m.sessionDownload
+}
+
+// GetProcessCacheStats returns PID name cache effectiveness counters
+func (m *Monitor) GetProcessCacheStats() ProcessCacheStats {
+	return m.procCache.stats()
+}
+
 // GetMonitorStatus returns current monitor status
 func (m *Monitor) GetMonitorStatus() MonitorStatus {
 	m.pauseMux.RLock()
 	paused := m.paused
 	m.pauseMux.RUnlock()
 
+	healthy, lastError := m.isHealthy()
+
+	m.saveMux.RLock()
+	saveEnabled := m.saveEnabled
+	m.saveMux.RUnlock()
+
+	m.excludeMux.RLock()
+	proxies := m.proxyApps
+	m.excludeMux.RUnlock()
+
+	m.statsMux.RLock()
+	lastUpdate := m.lastUpdate
+	warming := m.samplesCollected < m.warmupSamples
+	trackedApps := len(m.stats)
+	clockJumps, lastClockJump := m.clockJumps, m.lastClockJump
+	suspectCycles := m.suspectCycles
+	var proxyApp string
+	var proxySpeed int64
+	for appName, stat := range m.stats {
+		speed := stat.UploadSpeed + stat.DownloadSpeed
+		if proxies[utils.CanonicalAppName(appName)] && (proxyApp == "" || speed > proxySpeed) {
+			proxyApp = appName
+			proxySpeed = speed
+		}
+	}
+	m.statsMux.RUnlock()
+
+	m.tempMux.RLock()
+	temporary := m.tempSession
+	m.tempMux.RUnlock()
+
+	m.runMux.Lock()
+	running := m.ctx != nil && m.ctx.Err() == nil
+	m.runMux.Unlock()
+
 	return MonitorStatus{
-		Running:        m.ctx != nil,
+		Running:        running,
 		Paused:         paused,
-		UpdateInterval: int(UPDATE_INTERVAL.Seconds()),
-		LastUpdate:     m.lastUpdate,
+		UpdateInterval: int(m.GetTiming().UpdateInterval.Seconds()),
+		LastUpdate:     lastUpdate,
+		Healthy:        healthy,
+		LastError:      lastError,
+		Temporary:      temporary,
+		Warming:        warming,
+		SaveEnabled:    saveEnabled,
+		Elevated:       m.elevated,
+		TrackedApps:    trackedApps,
+		Interfaces:     activeInterfaces(),
+		ProxyDetected:  proxyApp != "",
+		ProxyApp:       proxyApp,
+		ClockJumps:     clockJumps,
+		SuspectCycles:  suspectCycles,
+		LastClockJump:  lastClockJump,
 	}
 }
 
@@ -374,11 +1427,244 @@ func (m *Monitor) Resume() {
 	m.pauseMux.Unlock()
 }
 
-// SetSaveEnabled enables or disables saving data to database
+// SetExcludedApps replaces the list of apps that are never tracked
+func (m *Monitor) SetExcludedApps(apps []string) {
+	excluded := make(map[string]bool, len(apps))
+	for _, app := range apps {
+		excluded[utils.CanonicalAppName(app)] = true
+	}
+
+	m.excludeMux.Lock()
+	m.excludedApps = excluded
+	m.excludeMux.Unlock()
+
+	// Forget live stats for apps that are now excluded
+	m.statsMux.Lock()
+	m.statsGen++
+	for appName := range m.stats {
+		if excluded[utils.CanonicalAppName(appName)] {
+			delete(m.stats, appName)
+		}
+	}
+	m.statsMux.Unlock()
+}
+
+// PauseAppTracking stops storing records for an app for the given duration; it still shows in live stats.
+// Pausing an app that is already paused replaces the previous duration.
+func (m *Monitor) PauseAppTracking(appName string, duration time.Duration) {
+	canonical := utils.CanonicalAppName(appName)
+
+	m.excludeMux.Lock()
+	defer m.excludeMux.Unlock()
+
+	if existing, ok := m.pausedApps[canonical]; ok {
+		existing.timer.Stop()
+	}
+	paused := &pausedApp{until: time.Now().Add(duration)}
+	paused.timer = time.AfterFunc(duration, func() {
+		m.excludeMux.Lock()
+		defer m.excludeMux.Unlock()
+		// A newer pause may have replaced this one
+		if m.pausedApps[canonical] == paused {
+			delete(m.pausedApps, canonical)
+			fmt.Printf("Tracking resumed for %s\n", appName)
+		}
+	})
+	m.pausedApps[canonical] = paused
+	fmt.Printf("Tracking paused for %s until %s\n", appName, paused.until.Format(time.Kitchen))
+}
+
+// ResumeAppTracking lifts a temporary tracking pause early
+func (m *Monitor) ResumeAppTracking(appName string) {
+	canonical := utils.CanonicalAppName(appName)
+
+	m.excludeMux.Lock()
+	defer m.excludeMux.Unlock()
+
+	if paused, ok := m.pausedApps[canonical]; ok {
+		paused.timer.Stop()
+		delete(m.pausedApps, canonical)
+	}
+}
+
+// GetPausedApps returns temporarily paused apps (canonical names) and when each pause ends (Unix timestamp)
+func (m *Monitor) GetPausedApps() map[string]int64 {
+	m.excludeMux.RLock()
+	defer m.excludeMux.RUnlock()
+
+	paused := make(map[string]int64, len(m.pausedApps))
+	for name, p := range m.pausedApps {
+		paused[name] = p.until.Unix()
+	}
+	return paused
+}
+
+// SetProxyApps replaces the list of proxy/VPN processes that trigger the attribution warning
+func (m *Monitor) SetProxyApps(apps []string) {
+	proxies := canonicalSet(apps)
+	m.excludeMux.Lock()
+	m.proxyApps = proxies
+	m.excludeMux.Unlock()
+}
+
+// SetMaxTrackedApps sets how many apps are kept in memory (0 disables the cap)
+func (m *Monitor) SetMaxTrackedApps(max int) {
+	m.statsMux.Lock()
+	m.maxTrackedApps = max
+	m.statsGen++
+	evictLeastRecent(m.stats, max)
+	m.statsMux.Unlock()
+}
+
+// SetWarmupSamples sets how many collections must succeed before stats stop being flagged as warming
+func (m *Monitor) SetWarmupSamples(samples int) {
+	if samples < 1 {
+		samples = 1
+	}
+	m.statsMux.Lock()
+	m.warmupSamples = samples
+	m.statsMux.Unlock()
+}
+
+// BeginTemporarySession marks newly collected records as temporary, expiring after ttl
+func (m *Monitor) BeginTemporarySession(ttl time.Duration) {
+	m.tempMux.Lock()
+	m.tempSession = true
+	m.tempTTL = ttl
+	m.tempMux.Unlock()
+	fmt.Printf("Temporary session started (records expire after %s)\n", ttl)
+}
+
+// EndTemporarySession returns to recording permanent records
+func (m *Monitor) EndTemporarySession() {
+	m.tempMux.Lock()
+	m.tempSession = false
+	m.tempTTL = 0
+	m.tempMux.Unlock()
+	fmt.Println("Temporary session ended")
+}
+
+// StartLabeledSession stamps label onto every record collected until EndLabeledSession. Sessions
+// don't nest: starting one while another is open replaces its label, so the most recent wins.
+func (m *Monitor) StartLabeledSession(label string) {
+	m.tempMux.Lock()
+	previous := m.sessionLabel
+	m.sessionLabel = label
+	m.tempMux.Unlock()
+	if previous != "" {
+		fmt.Printf("Labeled session %q replaced by %q\n", previous, label)
+	} else {
+		fmt.Printf("Labeled session %q started\n", label)
+	}
+}
+
+// EndLabeledSession stops labeling records and returns the label that was open, if any
+func (m *Monitor) EndLabeledSession() string {
+	m.tempMux.Lock()
+	label := m.sessionLabel
+	m.sessionLabel = ""
+	m.tempMux.Unlock()
+	if label != "" {
+		fmt.Printf("Labeled session %q ended\n", label)
+	}
+	return label
+}
+
+// GetSessionLabel returns the label of the open labeled session, or "" if none is open
+func (m *Monitor) GetSessionLabel() string {
+	m.tempMux.RLock()
+	defer m.tempMux.RUnlock()
+	return m.sessionLabel
+}
+
+// SetScreenState sets the screen lock state stamped on records from now on: database.ScreenLocked,
+// database.ScreenUnlocked or database.ScreenUnknown
+func (m *Monitor) SetScreenState(state string) {
+	m.tempMux.Lock()
+	previous := m.screenState
+	m.screenState = state
+	m.tempMux.Unlock()
+	if state != previous {
+		switch state {
+		case database.ScreenLocked:
+			fmt.Println("Screen locked")
+		case database.ScreenUnlocked:
+			fmt.Println("Screen unlocked")
+		default:
+			fmt.Println("Screen lock state unknown")
+		}
+	}
+}
+
+// SetCycleCap sets the most bytes one collection may report (0 disables the check). Oversized
+// collections are scaled down to the cap when clamp is set, and discarded otherwise.
+func (m *Monitor) SetCycleCap(maxBytes int64, clamp bool) {
+	m.saveMux.Lock()
+	m.maxCycleBytes = maxBytes
+	m.clampCycles = clamp
+	m.saveMux.Unlock()
+}
+
+// SetMinProcessLifetime sets how long a process must have existed before its traffic is stored
+// under its own name; younger processes are stored as TRANSIENT_APP. 0 stores every process by name.
+func (m *Monitor) SetMinProcessLifetime(lifetime time.Duration) {
+	m.saveMux.Lock()
+	m.minProcessLifetime = lifetime
+	m.saveMux.Unlock()
+}
+
+// SetRollupByProcessTree enables crediting each process's traffic to its root ancestor, so apps
+// that spawn helper processes are tracked as one
+func (m *Monitor) SetRollupByProcessTree(enabled bool) {
+	m.procCache.rollupTree.Store(enabled)
+}
+
+// SetTrackLANTraffic sets whether traffic to private (RFC 1918) and link-local addresses is
+// credited to apps. When off, the share of such connections is dropped from app usage.
+func (m *Monitor) SetTrackLANTraffic(enabled bool) {
+	m.procCache.skipLAN.Store(!enabled)
+}
+
+// SetStorageGranularity sets the width of the buckets records are summed into per app before they
+// are stored. Live stats keep the collection interval; 0 stores every collection. Open buckets are
+// kept in the batch (and journal) across flushes and written once they end, so buckets longer than
+// the batch interval still produce one record per app and bucket.
+func (m *Monitor) SetStorageGranularity(granularity time.Duration) {
+	m.saveMux.Lock()
+	m.storageGranularity = granularity
+	m.saveMux.Unlock()
+}
+
+// SetStoreSystemTotals enables or disables storing raw system-wide totals
+func (m *Monitor) SetStoreSystemTotals(enabled bool) {
+	m.saveMux.Lock()
+	m.storeSystemTotals = enabled
+	m.saveMux.Unlock()
+}
+
+// SetSaveEnabled enables or disables saving data to database. Records collected while saving was
+// still on are written before it turns off.
 func (m *Monitor) SetSaveEnabled(enabled bool) {
+	// Hold off collections so none lands between the last write and the switch
+	m.collectMux.Lock()
+	defer m.collectMux.Unlock()
+
+	if !enabled {
+		m.flush(true)
+	}
 	m.saveMux.Lock()
 	m.saveEnabled = enabled
 	m.saveMux.Unlock()
+
+	// Drop whatever the write couldn't store, so live-only mode never persists it later
+	if !enabled {
+		m.batchMux.Lock()
+		m.batch = make([]batchRecord, 0)
+		m.pendingSystem = systemDelta{}
+		m.pendingDestinations = nil
+		m.resetJournal()
+		m.batchMux.Unlock()
+	}
 	if enabled {
 		fmt.Println("Data saving enabled")
 	} else {
@@ -0,0 +1,25 @@
+//go:build !windows
+
+package monitor
+
+import "fmt"
+
+// IsElevated reports whether the process runs with administrator privileges
+func IsElevated() bool {
+	return false
+}
+
+// getNetworkProcesses is only implemented on Windows
+func getNetworkProcesses(cache *processCache) (map[string]processData, systemDelta, error) {
+	return nil, systemDelta{}, fmt.Errorf("connection tables: %w", ErrTableUnavailable)
+}
+
+// connectionSnapshot returns no connections outside Windows
+func connectionSnapshot() []ConnectionInfo {
+	return []ConnectionInfo{}
+}
+
+// activeInterfaces returns no interfaces outside Windows
+func activeInterfaces() []string {
+	return []string{}
+}
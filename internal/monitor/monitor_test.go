@@ -0,0 +1,502 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"netpus/internal/database"
+	"netpus/internal/utils"
+)
+
+// fakeSource returns a source that reports the given collections in order, then idle ones.
+// The system delta of each collection is the sum of its apps.
+func fakeSource(cycles ...map[string]processData) collectSource {
+	return func(*processCache) (map[string]processData, systemDelta, error) {
+		if len(cycles) == 0 {
+			return map[string]processData{}, systemDelta{}, nil
+		}
+		next := cycles[0]
+		cycles = cycles[1:]
+		var delta systemDelta
+		for _, data := range next {
+			delta.upload += data.uploadBytes
+			delta.download += data.downloadBytes
+		}
+		return next, delta, nil
+	}
+}
+
+// newTestMonitor creates a monitor that collects from source and writes to db (nil for none)
+func newTestMonitor(db interface{}, source collectSource) *Monitor {
+	m := New(db)
+	m.source = source
+	return m
+}
+
+// mustCollect runs one collection and fails the test on error
+func mustCollect(t testing.TB, m *Monitor) {
+	t.Helper()
+	if err := m.collect(); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+}
+
+// newTestDB opens a database in a temporary directory
+func newTestDB(t testing.TB) *database.DB {
+	t.Helper()
+	db, err := database.New(filepath.Join(t.TempDir(), "netpus.db"))
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// storedRecords returns every usage record in db
+func storedRecords(t testing.TB, db *database.DB) []database.UsageRecord {
+	t.Helper()
+	records, err := db.GetUsageByTimeRange(0, math.MaxInt64)
+	if err != nil {
+		t.Fatalf("GetUsageByTimeRange: %v", err)
+	}
+	return records
+}
+
+func TestFlushKeepsOpenBuckets(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMonitor(db, fakeSource())
+	m.SetStorageGranularity(time.Hour)
+
+	now := time.Now().Unix()
+	earlier := now - 2*3600
+	m.batch = []batchRecord{
+		{appName: "app.exe", upload: 1, download: 2, timestamp: earlier},
+		{appName: "app.exe", upload: 10, download: 20, timestamp: now},
+		{appName: "app.exe", upload: 10, download: 20, timestamp: now},
+	}
+
+	m.flushBatch()
+	records := storedRecords(t, db)
+	if len(records) != 1 || records[0].UploadBytes != 1 {
+		t.Fatalf("stored %+v, want only the closed bucket", records)
+	}
+	if len(m.batch) != 1 || m.batch[0].upload != 20 || m.batch[0].download != 40 {
+		t.Fatalf("batch = %+v, want the open bucket summed into one record", m.batch)
+	}
+
+	// Stop writes the open bucket too
+	m.Stop()
+	if records := storedRecords(t, db); len(records) != 2 {
+		t.Fatalf("stored %d records after Stop, want 2", len(records))
+	}
+}
+
+func TestReconcileStatsKeepsConcurrentPruning(t *testing.T) {
+	m := newTestMonitor(nil, fakeSource())
+	now := time.Now()
+	earlier := now.Add(-time.Minute)
+	m.stats = map[string]*NetworkStat{
+		"idle.exe":   {AppName: "idle.exe", LastUpdate: earlier},
+		"marked.exe": {AppName: "marked.exe", LastUpdate: earlier},
+		"busy.exe":   {AppName: "busy.exe", LastUpdate: earlier},
+	}
+	gen := m.statsGen
+
+	// The collection built its map from the snapshot; busy.exe had traffic this cycle
+	stats := map[string]*NetworkStat{
+		"idle.exe":    {AppName: "idle.exe", LastUpdate: earlier},
+		"marked.exe":  {AppName: "marked.exe", LastUpdate: earlier},
+		"busy.exe":    {AppName: "busy.exe", LastUpdate: now},
+		"blocked.exe": {AppName: "blocked.exe", LastUpdate: now},
+	}
+
+	// Meanwhile cleanup removed idle.exe and busy.exe and marked marked.exe inactive
+	m.statsGen++
+	delete(m.stats, "idle.exe")
+	delete(m.stats, "busy.exe")
+	m.stats["marked.exe"].Inactive = true
+
+	m.reconcileStats(stats, gen, now, map[string]bool{utils.CanonicalAppName("blocked.exe"): true})
+
+	if _, ok := stats["idle.exe"]; ok {
+		t.Error("idle.exe was removed during the collection but came back")
+	}
+	if _, ok := stats["busy.exe"]; !ok {
+		t.Error("busy.exe had traffic this cycle and should be kept")
+	}
+	if stat, ok := stats["marked.exe"]; !ok || !stat.Inactive {
+		t.Error("marked.exe should keep the inactive flag set during the collection")
+	}
+	if _, ok := stats["blocked.exe"]; ok {
+		t.Error("blocked.exe was excluded during the collection but is still tracked")
+	}
+}
+
+func TestCollectRespectsMaxTrackedApps(t *testing.T) {
+	m := newTestMonitor(nil, fakeSource(map[string]processData{
+		"a.exe": {uploadBytes: 1},
+		"b.exe": {uploadBytes: 1},
+		"c.exe": {uploadBytes: 1},
+	}))
+	mustCollect(t, m)
+	m.SetMaxTrackedApps(2)
+	if got := len(m.GetStats()); got != 2 {
+		t.Fatalf("tracked apps = %d, want 2", got)
+	}
+}
+
+func TestCollectConcurrentWithSettings(t *testing.T) {
+	source := func(*processCache) (map[string]processData, systemDelta, error) {
+		return map[string]processData{
+			"a.exe": {uploadBytes: 100, downloadBytes: 100},
+			"b.exe": {uploadBytes: 100, downloadBytes: 100},
+		}, systemDelta{upload: 200, download: 200}, nil
+	}
+	m := newTestMonitor(nil, source)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			m.SetExcludedApps([]string{"b.exe"})
+			m.cleanupInactive()
+			m.SetMaxTrackedApps(1 + i%3)
+			m.GetStats()
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		mustCollect(t, m)
+	}
+	<-done
+
+	mustCollect(t, m)
+	if _, ok := m.GetStats()["b.exe"]; ok {
+		t.Error("excluded app is tracked after concurrent collections")
+	}
+}
+
+func BenchmarkGetStatsDuringCollect(b *testing.B) {
+	processes := make(map[string]processData, 200)
+	for i := 0; i < 200; i++ {
+		processes[fmt.Sprintf("app%d.exe", i)] = processData{uploadBytes: 1000, downloadBytes: 1000}
+	}
+	source := func(*processCache) (map[string]processData, systemDelta, error) {
+		next := make(map[string]processData, len(processes))
+		for name, data := range processes {
+			next[name] = data
+		}
+		return next, systemDelta{upload: 200000, download: 200000}, nil
+	}
+	m := newTestMonitor(nil, source)
+	mustCollect(b, m)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				m.collect()
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.GetStats()
+	}
+	b.StopTimer()
+	close(stop)
+	<-done
+}
+
+func TestStopWritesFinalBatch(t *testing.T) {
+	db := newTestDB(t)
+	cycles := fakeSource(
+		map[string]processData{"browser.exe": {uploadBytes: 100, downloadBytes: 1000}},
+		map[string]processData{"browser.exe": {uploadBytes: 50, downloadBytes: 500}},
+		map[string]processData{"mail.exe": {uploadBytes: 7, downloadBytes: 70}},
+	)
+	calls := 0
+	drained := make(chan struct{})
+	m := newTestMonitor(db, func(cache *processCache) (map[string]processData, systemDelta, error) {
+		calls++
+		if calls == 3 {
+			close(drained)
+		}
+		return cycles(cache)
+	})
+	// Collect quickly but never reach a scheduled write, so only Stop can store the batch
+	timing := DefaultTiming()
+	timing.UpdateInterval = 10 * time.Millisecond
+	timing.BatchInterval = time.Hour
+	m.SetTiming(timing)
+
+	if err := m.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not collect the fake cycles")
+	}
+	if records := storedRecords(t, db); len(records) != 0 {
+		t.Fatalf("%d records stored before Stop", len(records))
+	}
+	m.Stop()
+
+	totals := make(map[string][2]int64)
+	for _, r := range storedRecords(t, db) {
+		total := totals[r.AppName]
+		total[0] += r.UploadBytes
+		total[1] += r.DownloadBytes
+		totals[r.AppName] = total
+	}
+	if got := totals["browser.exe"]; got != [2]int64{150, 1500} {
+		t.Errorf("browser.exe stored %v, want [150 1500]", got)
+	}
+	if got := totals["mail.exe"]; got != [2]int64{7, 70} {
+		t.Errorf("mail.exe stored %v, want [7 70]", got)
+	}
+}
+
+func TestFlushCreditsEachDay(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMonitor(db, fakeSource())
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	before, after := midnight.Add(-10*time.Second), midnight.Add(10*time.Second)
+	m.batch = []batchRecord{
+		{appName: "app.exe", upload: 1, download: 2, timestamp: before.Unix()},
+		{appName: "app.exe", upload: 10, download: 20, timestamp: after.Unix()},
+		{appName: "other.exe", upload: 100, download: 200, timestamp: after.Unix()},
+	}
+	m.flush(true)
+
+	tests := []struct {
+		date             string
+		upload, download int64
+	}{
+		{before.Format("2006-01-02"), 1, 2},
+		{after.Format("2006-01-02"), 110, 220},
+	}
+	for _, tt := range tests {
+		summary, err := db.GetDailySummary(tt.date)
+		if err != nil {
+			t.Fatalf("GetDailySummary(%s): %v", tt.date, err)
+		}
+		if summary.TotalUpload != tt.upload || summary.TotalDownload != tt.download {
+			t.Errorf("%s summary = %d up / %d down, want %d / %d",
+				tt.date, summary.TotalUpload, summary.TotalDownload, tt.upload, tt.download)
+		}
+	}
+}
+
+func TestCollectFromFakeSource(t *testing.T) {
+	tcp := trafficKind{"tcp", "ipv4"}
+	udp := trafficKind{"udp", "ipv6"}
+	m := newTestMonitor(nil, fakeSource(
+		map[string]processData{
+			"browser.exe": {
+				processID: 42, uploadBytes: 300, downloadBytes: 3000,
+				shares: map[trafficKind]traffic{
+					tcp: {upload: 200, download: 2000},
+					udp: {upload: 100, download: 1000},
+				},
+			},
+		},
+		map[string]processData{"browser.exe": {processID: 42, uploadBytes: 1000, downloadBytes: 4000}},
+	))
+
+	// The first collection has no previous one, so it counts as one second
+	mustCollect(t, m)
+	stat := m.GetStats()["browser.exe"]
+	if stat == nil {
+		t.Fatal("browser.exe missing from stats")
+	}
+	if stat.UploadSpeed != 300 || stat.DownloadSpeed != 3000 {
+		t.Errorf("first speeds = %d/%d, want 300/3000", stat.UploadSpeed, stat.DownloadSpeed)
+	}
+
+	wantRecords := []batchRecord{
+		{appName: "browser.exe", processID: 42, protocol: "tcp", family: "ipv4", upload: 200, download: 2000},
+		{appName: "browser.exe", processID: 42, protocol: "udp", family: "ipv6", upload: 100, download: 1000},
+	}
+	if len(m.batch) != len(wantRecords) {
+		t.Fatalf("batch has %d records, want %d: %+v", len(m.batch), len(wantRecords), m.batch)
+	}
+	for i, want := range wantRecords {
+		got := m.batch[i]
+		got.timestamp, got.expiresAt, got.screenState, got.key = 0, 0, "", ""
+		if got != want {
+			t.Errorf("batch[%d] = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Pretend the previous collection was two seconds ago
+	m.statsMux.Lock()
+	m.stats["browser.exe"].LastUpdate = m.stats["browser.exe"].LastUpdate.Add(-2 * time.Second)
+	m.statsMux.Unlock()
+	mustCollect(t, m)
+
+	stat = m.GetStats()["browser.exe"]
+	if stat.UploadSpeed < 450 || stat.UploadSpeed > 500 || stat.DownloadSpeed < 1800 || stat.DownloadSpeed > 2000 {
+		t.Errorf("second speeds = %d/%d, want about 500/2000", stat.UploadSpeed, stat.DownloadSpeed)
+	}
+	if stat.TotalUpload != 1300 || stat.TotalDownload != 7000 {
+		t.Errorf("totals = %d/%d, want 1300/7000", stat.TotalUpload, stat.TotalDownload)
+	}
+	if len(m.batch) != 3 {
+		t.Errorf("batch has %d records after the second collection, want 3", len(m.batch))
+	}
+}
+
+func TestStopCancelsPauseTimers(t *testing.T) {
+	m := newTestMonitor(nil, fakeSource())
+	m.PauseAppTracking("browser.exe", time.Hour)
+	m.PauseAppTracking("mail.exe", time.Hour)
+	timers := make([]*time.Timer, 0, 2)
+	for _, paused := range m.pausedApps {
+		timers = append(timers, paused.timer)
+	}
+
+	m.Stop()
+
+	if paused := m.GetPausedApps(); len(paused) != 0 {
+		t.Errorf("paused apps after Stop = %v, want none", paused)
+	}
+	for _, timer := range timers {
+		if timer.Stop() {
+			t.Error("a pause timer was still running after Stop")
+		}
+	}
+}
+
+func TestCycleCapScalesWithElapsedTime(t *testing.T) {
+	const DOWNLOAD = 375 * 1000 * 1000 // 300 Mbit/s for 10 seconds
+	tests := []struct {
+		name     string
+		interval time.Duration
+		elapsed  time.Duration
+		wantKeep bool
+	}{
+		{"10s interval", 10 * time.Second, 10 * time.Second, true},
+		{"10s interval, first collection", 10 * time.Second, 0, true},
+		{"late collection", UPDATE_INTERVAL, 10 * time.Second, true},
+		{"default interval", UPDATE_INTERVAL, UPDATE_INTERVAL, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMonitor(nil, fakeSource())
+			timing := DefaultTiming()
+			timing.UpdateInterval = tt.interval
+			m.SetTiming(timing)
+			m.SetCycleCap(utils.DefaultMaxCycleBytes, false)
+
+			processes := map[string]processData{"browser.exe": {downloadBytes: DOWNLOAD}}
+			_, _, keep := m.capCycle(processes, systemDelta{download: DOWNLOAD}, tt.elapsed)
+			if keep != tt.wantKeep {
+				t.Errorf("keep = %v, want %v", keep, tt.wantKeep)
+			}
+		})
+	}
+}
+
+func TestCollectKeepsTrafficAtLongInterval(t *testing.T) {
+	const DOWNLOAD = 375 * 1000 * 1000
+	m := newTestMonitor(nil, fakeSource(map[string]processData{"browser.exe": {downloadBytes: DOWNLOAD}}))
+	timing := DefaultTiming()
+	timing.UpdateInterval = 10 * time.Second
+	m.SetTiming(timing)
+	m.SetCycleCap(utils.DefaultMaxCycleBytes, false)
+	m.lastUpdate = time.Now().Add(-10 * time.Second)
+
+	mustCollect(t, m)
+
+	if stat := m.GetStats()["browser.exe"]; stat == nil || stat.TotalDownload != DOWNLOAD {
+		t.Errorf("browser.exe stats = %+v, want %d bytes downloaded", stat, DOWNLOAD)
+	}
+	if m.suspectCycles != 0 {
+		t.Errorf("suspectCycles = %d, want 0", m.suspectCycles)
+	}
+}
+
+func TestDisablingSaveWritesPendingBatch(t *testing.T) {
+	db := newTestDB(t)
+	m := newTestMonitor(db, fakeSource(
+		map[string]processData{"browser.exe": {uploadBytes: 100, downloadBytes: 1000}},
+		map[string]processData{"browser.exe": {uploadBytes: 5, downloadBytes: 50}},
+	))
+
+	mustCollect(t, m)
+	m.SetSaveEnabled(false)
+	mustCollect(t, m)
+	m.flush(true)
+
+	var up, down int64
+	for _, r := range storedRecords(t, db) {
+		up += r.UploadBytes
+		down += r.DownloadBytes
+	}
+	if up != 100 || down != 1000 {
+		t.Errorf("stored %d up / %d down, want only the collection made while saving: 100 / 1000", up, down)
+	}
+}
+
+func TestJournalReplaySkipsStoredBuckets(t *testing.T) {
+	db := newTestDB(t)
+	path := filepath.Join(t.TempDir(), "batch.journal")
+	m := newTestMonitor(db, fakeSource())
+	m.SetStorageGranularity(time.Minute)
+
+	// Two collections in one bucket, journaled as collected
+	start := time.Now().Unix()
+	start -= start % 60
+	batch := []batchRecord{
+		{appName: "app.exe", upload: 1, download: 10, timestamp: start, key: "run-1"},
+		{appName: "app.exe", upload: 2, download: 20, timestamp: start + 1, key: "run-2"},
+	}
+	journal, err := openJournal(path)
+	if err != nil {
+		t.Fatalf("openJournal: %v", err)
+	}
+	if err := journal.append(batch); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	journal.close()
+	crashed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	// The flush stores the bucket, then the process dies before the journal is removed
+	m.batch = batch
+	m.flush(true)
+
+	for replay := 1; replay <= 2; replay++ {
+		if err := os.WriteFile(path, crashed, 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		replayed, err := m.replayJournal(path)
+		if err != nil {
+			t.Fatalf("replay %d: %v", replay, err)
+		}
+		if replayed != 0 {
+			t.Errorf("replay %d stored %d records already in the database", replay, replayed)
+		}
+	}
+
+	records := storedRecords(t, db)
+	if len(records) != 1 || records[0].UploadBytes != 3 || records[0].DownloadBytes != 30 {
+		t.Errorf("stored %+v, want one 3/30 record", records)
+	}
+}
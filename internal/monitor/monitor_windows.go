@@ -3,13 +3,20 @@
 package monitor
 
 import (
+	"errors"
 	"fmt"
+	"net/netip"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"netpus/internal/utils"
 )
 
 var (
@@ -17,29 +24,125 @@ var (
 	procGetExtendedTcpTable = iphlpapi.NewProc("GetExtendedTcpTable")
 	procGetExtendedUdpTable = iphlpapi.NewProc("GetExtendedUdpTable")
 	procGetIfTable2         = iphlpapi.NewProc("GetIfTable2")
+
+	wtsapi32                        = syscall.NewLazyDLL("wtsapi32.dll")
+	procWTSQuerySessionInformationW = wtsapi32.NewProc("WTSQuerySessionInformationW")
+)
+
+// Session lock state query (WTSQuerySessionInformation with WTSSessionInfoEx)
+const (
+	wtsCurrentServerHandle = 0          // WTS_CURRENT_SERVER_HANDLE
+	wtsCurrentSession      = 0xFFFFFFFF // WTS_CURRENT_SESSION
+	wtsSessionInfoEx       = 25         // WTSSessionInfoEx
+	wtsSessionStateLock    = 0          // WTS_SESSIONSTATE_LOCK
+	wtsSessionStateUnlock  = 1          // WTS_SESSIONSTATE_UNLOCK
 )
 
+// wtsInfoEx is WTSINFOEXW with its WTSINFOEX_LEVEL1_W member, up to SessionFlags
+type wtsInfoEx struct {
+	Level        uint32
+	SessionID    uint32
+	SessionState int32
+	SessionFlags int32
+}
+
 // Track previous system I/O for delta calculation
 var (
 	prevSystemUpload   int64
 	prevSystemDownload int64
+	prevInterfaces     map[uint64]string // LUID to alias of the interfaces the baseline was taken from
 	prevSystemMux      sync.Mutex
 	systemInitialized  bool
 )
 
-type processData struct {
-	processID     int
-	uploadBytes   int64
-	downloadBytes int64
+// Connection table from the most recent collection that read it, for the connection view
+var (
+	lastConnections []ConnectionInfo
+	connectionsMux  sync.Mutex
+)
+
+// tcpStateNames maps MIB_TCP_STATE values to their names
+var tcpStateNames = map[uint32]string{
+	1:  "CLOSED",
+	2:  "LISTEN",
+	3:  "SYN_SENT",
+	4:  "SYN_RCVD",
+	5:  "ESTABLISHED",
+	6:  "FIN_WAIT1",
+	7:  "FIN_WAIT2",
+	8:  "CLOSE_WAIT",
+	9:  "CLOSING",
+	10: "LAST_ACK",
+	11: "TIME_WAIT",
+	12: "DELETE_TCB",
+}
+
+const ifOperStatusUp = 1 // IfOperStatusUp
+
+// IsElevated reports whether the process runs with administrator privileges.
+// Unelevated, connection tables can be incomplete for other users' processes.
+func IsElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// RunningProcesses returns the canonical names of all running processes
+func RunningProcesses() (map[string]bool, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("CreateToolhelp32Snapshot: %w", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	names := make(map[string]bool)
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		names[utils.CanonicalAppName(windows.UTF16ToString(entry.ExeFile[:]))] = true
+	}
+	return names, nil
+}
+
+// ScreenLocked reports whether the workstation is locked, from the lock state Windows keeps for
+// the current session. ok is false when the state can't be read or Windows reports it as unknown,
+// as for services and sessions without an interactive logon.
+func ScreenLocked() (locked bool, ok bool) {
+	if procWTSQuerySessionInformationW.Find() != nil {
+		return false, false
+	}
+
+	var info *wtsInfoEx
+	var size uint32
+	ret, _, _ := procWTSQuerySessionInformationW.Call(
+		wtsCurrentServerHandle,
+		wtsCurrentSession,
+		wtsSessionInfoEx,
+		uintptr(unsafe.Pointer(&info)),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if ret == 0 || info == nil {
+		return false, false
+	}
+	defer windows.WTSFreeMemory(uintptr(unsafe.Pointer(info)))
+
+	if info.Level != 1 || size < uint32(unsafe.Sizeof(*info)) {
+		return false, false
+	}
+	switch info.SessionFlags {
+	case wtsSessionStateLock:
+		return true, true
+	case wtsSessionStateUnlock:
+		return false, true
+	}
+	return false, false
 }
 
 // getNetworkProcesses collects network statistics for all processes on Windows
 // This now returns DELTA bytes (bytes transferred since last call) distributed to processes
-func getNetworkProcesses() (map[string]processData, error) {
+func getNetworkProcesses(cache *processCache) (map[string]processData, systemDelta, error) {
 	// Get system-wide network I/O (cumulative totals)
-	totalUpload, totalDownload, err := getSystemNetworkIO()
+	totalUpload, totalDownload, interfaces, err := getSystemNetworkIO()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get system network I/O: %w", err)
+		return nil, systemDelta{}, fmt.Errorf("failed to get system network I/O: %w", err)
 	}
 
 	prevSystemMux.Lock()
@@ -48,12 +151,18 @@ func getNetworkProcesses() (map[string]processData, error) {
 	// Calculate system-wide deltas
 	var uploadDelta, downloadDelta int64
 
-	if !systemInitialized {
-		// First call - initialize baseline, return zero
+	// First call, or an interface came up or went away (network switch, VPN toggle):
+	// the old baseline no longer matches the summed counters, so re-baseline and return zero
+	if !systemInitialized || !sameInterfaces(prevInterfaces, interfaces) {
+		if systemInitialized {
+			fmt.Printf("Network interfaces changed (%d -> %d active), re-baselining counters\n",
+				len(prevInterfaces), len(interfaces))
+		}
 		prevSystemUpload = totalUpload
 		prevSystemDownload = totalDownload
+		prevInterfaces = interfaces
 		systemInitialized = true
-		return make(map[string]processData), nil
+		return make(map[string]processData), systemDelta{}, nil
 	}
 
 	// Calculate deltas since last call
@@ -72,83 +181,148 @@ func getNetworkProcesses() (map[string]processData, error) {
 	prevSystemUpload = totalUpload
 	prevSystemDownload = totalDownload
 
+	delta := systemDelta{upload: uploadDelta, download: downloadDelta}
+
 	// If no traffic, return empty
 	if uploadDelta == 0 && downloadDelta == 0 {
-		return make(map[string]processData), nil
+		return make(map[string]processData), delta, nil
 	}
 
 	// Get connection information
-	tcpConns, err := getTCPStats()
+	tcpConns, err := getTCPTable[tcpRow](windows.AF_INET)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get TCP stats: %w", err)
+		return nil, delta, fmt.Errorf("failed to get TCP stats: %w", err)
 	}
-
-	udpConns, err := getUDPStats()
+	udpConns, err := getUDPTable[udpRow](windows.AF_INET)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get UDP stats: %w", err)
+		return nil, delta, fmt.Errorf("failed to get UDP stats: %w", err)
 	}
 
-	// Build process connection map with weights
-	// Only count ESTABLISHED TCP connections (actually transferring data)
-	processWeights := make(map[uint32]float64)
-	processNames := make(map[uint32]string)
-	var totalWeight float64
+	// IPv6 can be disabled, which leaves only IPv4 traffic to attribute
+	tcp6Conns, err := getTCPTable[tcp6Row](windows.AF_INET6)
+	if err != nil && !errors.Is(err, ErrTableUnavailable) {
+		return nil, delta, fmt.Errorf("failed to get TCP6 stats: %w", err)
+	}
+	udp6Conns, err := getUDPTable[udp6Row](windows.AF_INET6)
+	if err != nil && !errors.Is(err, ErrTableUnavailable) {
+		return nil, delta, fmt.Errorf("failed to get UDP6 stats: %w", err)
+	}
 
-	// TCP connections - only ESTABLISHED connections are likely transferring data
-	for _, conn := range tcpConns {
-		if conn.State != 5 { // Only ESTABLISHED
-			continue
-		}
-		weight := 1.0
-		processWeights[conn.OwningPid] += weight
-		totalWeight += weight
+	conns, view := decodeConnections(tcpConns, tcp6Conns, udpConns, udp6Conns)
 
-		if _, exists := processNames[conn.OwningPid]; !exists {
-			if name := getProcessPath(conn.OwningPid); name != "" {
-				processNames[conn.OwningPid] = name
+	// Credit child processes to the ancestor that launched them, when enabled
+	if cache.rollupTree.Load() {
+		if tree, err := snapshotProcessTree(); err != nil {
+			fmt.Printf("Process tree rollup skipped: %v\n", err)
+		} else {
+			for i := range conns {
+				conns[i].pid = tree.root(conns[i].pid)
 			}
 		}
 	}
 
-	// UDP connections (listening sockets that may be receiving data)
-	for _, conn := range udpConns {
-		weight := 0.3 // Lower weight for UDP
-		processWeights[conn.OwningPid] += weight
-		totalWeight += weight
-
-		if _, exists := processNames[conn.OwningPid]; !exists {
-			if name := getProcessPath(conn.OwningPid); name != "" {
-				processNames[conn.OwningPid] = name
-			}
-		}
+	// Resolve process names for every PID with connections, in any TCP state for the connection view
+	pidSet := make(map[uint32]bool, len(conns))
+	for _, conn := range conns {
+		pidSet[conn.pid] = true
+	}
+	for _, entry := range view {
+		pidSet[uint32(entry.ProcessID)] = true
 	}
+	pids := make([]uint32, 0, len(pidSet))
+	for pid := range pidSet {
+		pids = append(pids, pid)
+	}
+	processNames := resolveProcessNames(cache, pids, lookupProcess)
+	storeConnections(view, processNames)
 
 	// Distribute the DELTA bytes based on weights
-	result := make(map[string]processData)
+	result := attribute(conns, processNames, uploadDelta, downloadDelta, cache.skipLAN.Load(), cache)
 
-	if totalWeight > 0 {
-		for pid, weight := range processWeights {
-			name, exists := processNames[pid]
-			if !exists || name == "" {
-				continue
-			}
+	return result, delta, nil
+}
 
-			proportion := weight / totalWeight
-			upload := int64(float64(uploadDelta) * proportion)
-			download := int64(float64(downloadDelta) * proportion)
-
-			// Only add if there's actual traffic
-			if upload > 0 || download > 0 {
-				result[name] = processData{
-					processID:     int(pid),
-					uploadBytes:   upload,
-					downloadBytes: download,
-				}
-			}
+// ipv4Addr converts a table IPv4 address (network byte order, so the first octet is the low byte)
+func ipv4Addr(addr uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(addr), byte(addr >> 8), byte(addr >> 16), byte(addr >> 24)})
+}
+
+// formatEndpoint renders an address and a table port (network byte order) as ip:port
+func formatEndpoint(addr netip.Addr, port uint32) string {
+	return netip.AddrPortFrom(addr, uint16(port&0xff)<<8|uint16(port>>8&0xff)).String()
+}
+
+// decodeConnections converts the connection tables into attribution inputs (established TCP
+// connections and UDP sockets) and entries for the connection view (every row). View entries
+// get their process names from storeConnections.
+func decodeConnections(tcp4 []tcpRow, tcp6 []tcp6Row, udp4 []udpRow, udp6 []udp6Row) ([]connection, []ConnectionInfo) {
+	conns := make([]connection, 0, len(tcp4)+len(tcp6)+len(udp4)+len(udp6))
+	view := make([]ConnectionInfo, 0, len(tcp4)+len(tcp6)+len(udp4)+len(udp6))
+
+	addTCP := func(family string, state, pid uint32, local, remote netip.Addr, localPort, remotePort uint32) {
+		name, ok := tcpStateNames[state]
+		if !ok {
+			name = strconv.Itoa(int(state))
+		}
+		view = append(view, ConnectionInfo{
+			Protocol:   "tcp",
+			Family:     family,
+			LocalAddr:  formatEndpoint(local, localPort),
+			RemoteAddr: formatEndpoint(remote, remotePort),
+			State:      name,
+			ProcessID:  int(pid),
+		})
+		if state == 5 { // Only ESTABLISHED connections are likely transferring data
+			conns = append(conns, connection{pid: pid, protocol: "tcp", family: family, remote: remote})
 		}
 	}
+	addUDP := func(family string, pid uint32, local netip.Addr, localPort uint32) {
+		view = append(view, ConnectionInfo{
+			Protocol:  "udp",
+			Family:    family,
+			LocalAddr: formatEndpoint(local, localPort),
+			ProcessID: int(pid),
+		})
+		conns = append(conns, connection{pid: pid, protocol: "udp", family: family})
+	}
+
+	for _, row := range tcp4 {
+		addTCP("ipv4", row.State, row.OwningPid, ipv4Addr(row.LocalAddr), ipv4Addr(row.RemoteAddr), row.LocalPort, row.RemotePort)
+	}
+	for _, row := range tcp6 {
+		local, remote := netip.AddrFrom16(row.LocalAddr), netip.AddrFrom16(row.RemoteAddr)
+		family := "ipv6"
+		if remote.Is4In6() {
+			// A dual-stack socket talking to an IPv4 peer carries IPv4 traffic
+			family, local, remote = "ipv4", local.Unmap(), remote.Unmap()
+		}
+		addTCP(family, row.State, row.OwningPid, local, remote, row.LocalPort, row.RemotePort)
+	}
+	for _, row := range udp4 {
+		addUDP("ipv4", row.OwningPid, ipv4Addr(row.LocalAddr), row.LocalPort)
+	}
+	for _, row := range udp6 {
+		addUDP("ipv6", row.OwningPid, netip.AddrFrom16(row.LocalAddr), row.LocalPort)
+	}
+	return conns, view
+}
+
+// storeConnections fills in process names and keeps the view for connectionSnapshot
+func storeConnections(view []ConnectionInfo, names map[uint32]string) {
+	for i := range view {
+		view[i].ProcessName = names[uint32(view[i].ProcessID)]
+	}
+
+	connectionsMux.Lock()
+	lastConnections = view
+	connectionsMux.Unlock()
+}
 
-	return result, nil
+// connectionSnapshot returns a copy of the last decoded connection table
+func connectionSnapshot() []ConnectionInfo {
+	connectionsMux.Lock()
+	defer connectionsMux.Unlock()
+	return append([]ConnectionInfo{}, lastConnections...)
 }
 
 // MIB_IF_ROW2 structure (simplified)
@@ -201,29 +375,87 @@ type mibIfTable2 struct {
 	Table      [1]mibIfRow2
 }
 
-// getSystemNetworkIO gets total network I/O from all interfaces
-func getSystemNetworkIO() (upload, download int64, err error) {
+// ListInterfaces returns every network interface with its status and byte counters, sorted by alias
+func ListInterfaces() ([]InterfaceInfo, error) {
+	if err := procGetIfTable2.Find(); err != nil {
+		return nil, fmt.Errorf("GetIfTable2: %w", ErrTableUnavailable)
+	}
+
 	var table *mibIfTable2
 	ret, _, _ := procGetIfTable2.Call(uintptr(unsafe.Pointer(&table)))
 	if ret != 0 {
-		return 0, 0, fmt.Errorf("GetIfTable2 failed with code %d", ret)
+		return nil, apiError("GetIfTable2", ret)
+	}
+	if table == nil || table.NumEntries == 0 {
+		return []InterfaceInfo{}, nil
 	}
-	if table == nil {
-		return 0, 0, nil
+
+	entries := unsafe.Slice(&table.Table[0], int(table.NumEntries))
+	interfaces := make([]InterfaceInfo, 0, len(entries))
+	for _, entry := range entries {
+		interfaces = append(interfaces, InterfaceInfo{
+			LUID:          entry.InterfaceLuid,
+			Alias:         windows.UTF16ToString(entry.Alias[:]),
+			Description:   windows.UTF16ToString(entry.Description[:]),
+			Up:            entry.OperStatus == ifOperStatusUp,
+			BytesSent:     entry.OutOctets,
+			BytesReceived: entry.InOctets,
+		})
 	}
+	sort.Slice(interfaces, func(i, j int) bool {
+		return interfaces[i].Alias < interfaces[j].Alias
+	})
+	return interfaces, nil
+}
+
+// getSystemNetworkIO gets total network I/O from all interfaces that are up,
+// along with the set of those interfaces (LUID to alias)
+func getSystemNetworkIO() (upload, download int64, interfaces map[uint64]string, err error) {
+	list, err := ListInterfaces()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	interfaces = make(map[uint64]string)
+	for _, iface := range list {
+		if !iface.Up {
+			continue
+		}
+		upload += int64(iface.BytesSent)
+		download += int64(iface.BytesReceived)
+		interfaces[iface.LUID] = iface.Alias
+	}
+
+	return upload, download, interfaces, nil
+}
 
-	numEntries := int(table.NumEntries)
-	if numEntries > 0 {
-		entries := unsafe.Slice(&table.Table[0], numEntries)
-		for _, entry := range entries {
-			upload += int64(entry.OutOctets)
-			download += int64(entry.InOctets)
+// sameInterfaces reports whether two interface sets contain the same LUIDs
+func sameInterfaces(a, b map[uint64]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for luid := range a {
+		if _, ok := b[luid]; !ok {
+			return false
 		}
 	}
+	return true
+}
+
+// activeInterfaces returns the aliases of the interfaces counted in the current baseline, sorted
+func activeInterfaces() []string {
+	prevSystemMux.Lock()
+	defer prevSystemMux.Unlock()
 
-	return upload, download, nil
+	names := make([]string, 0, len(prevInterfaces))
+	for _, alias := range prevInterfaces {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
 }
 
+// tcpRow is MIB_TCPROW_OWNER_PID
 type tcpRow struct {
 	State      uint32
 	LocalAddr  uint32
@@ -233,120 +465,126 @@ type tcpRow struct {
 	OwningPid  uint32
 }
 
-type tcpTable struct {
-	NumEntries uint32
-	Table      [1]tcpRow
+// tcp6Row is MIB_TCP6ROW_OWNER_PID
+type tcp6Row struct {
+	LocalAddr     [16]byte
+	LocalScopeID  uint32
+	LocalPort     uint32
+	RemoteAddr    [16]byte
+	RemoteScopeID uint32
+	RemotePort    uint32
+	State         uint32
+	OwningPid     uint32
 }
 
-// getTCPStats retrieves TCP connection information
-func getTCPStats() ([]tcpRow, error) {
-	var size uint32
-	family := uint32(windows.AF_INET)
-	class := uint32(5) // TCP_TABLE_OWNER_PID_ALL
-
-	// Get required size
-	procGetExtendedTcpTable.Call(
-		0,
-		uintptr(unsafe.Pointer(&size)),
-		0,
-		uintptr(family),
-		uintptr(class),
-		0,
-	)
-
-	if size == 0 {
-		return nil, fmt.Errorf("failed to get TCP table size")
-	}
-
-	// Allocate buffer
-	buf := make([]byte, size)
-	ret, _, _ := procGetExtendedTcpTable.Call(
-		uintptr(unsafe.Pointer(&buf[0])),
-		uintptr(unsafe.Pointer(&size)),
-		0,
-		uintptr(family),
-		uintptr(class),
-		0,
-	)
-
-	if ret != 0 {
-		return nil, fmt.Errorf("GetExtendedTcpTable failed with code %d", ret)
+// getTCPTable retrieves the TCP connections of one address family (AF_INET or AF_INET6)
+func getTCPTable[T tcpRow | tcp6Row](family uint32) ([]T, error) {
+	if err := procGetExtendedTcpTable.Find(); err != nil {
+		return nil, fmt.Errorf("GetExtendedTcpTable: %w", ErrTableUnavailable)
 	}
 
-	table := (*tcpTable)(unsafe.Pointer(&buf[0]))
-	numEntries := int(table.NumEntries)
-	entries := unsafe.Slice(&table.Table[0], numEntries)
-
-	return entries, nil
+	class := uint32(5) // TCP_TABLE_OWNER_PID_ALL
+	return readTable[T]("GetExtendedTcpTable", func(buf *byte, size *uint32) uintptr {
+		ret, _, _ := procGetExtendedTcpTable.Call(
+			uintptr(unsafe.Pointer(buf)),
+			uintptr(unsafe.Pointer(size)),
+			0,
+			uintptr(family),
+			uintptr(class),
+			0,
+		)
+		return ret
+	})
 }
 
+// udpRow is MIB_UDPROW_OWNER_PID
 type udpRow struct {
 	LocalAddr uint32
 	LocalPort uint32
 	OwningPid uint32
 }
 
-type udpTable struct {
-	NumEntries uint32
-	Table      [1]udpRow
+// udp6Row is MIB_UDP6ROW_OWNER_PID
+type udp6Row struct {
+	LocalAddr    [16]byte
+	LocalScopeID uint32
+	LocalPort    uint32
+	OwningPid    uint32
 }
 
-// getUDPStats retrieves UDP connection information
-func getUDPStats() ([]udpRow, error) {
-	var size uint32
-	family := uint32(windows.AF_INET)
+// getUDPTable retrieves the UDP sockets of one address family (AF_INET or AF_INET6)
+func getUDPTable[T udpRow | udp6Row](family uint32) ([]T, error) {
+	if err := procGetExtendedUdpTable.Find(); err != nil {
+		return nil, fmt.Errorf("GetExtendedUdpTable: %w", ErrTableUnavailable)
+	}
+
 	class := uint32(1) // UDP_TABLE_OWNER_PID
+	return readTable[T]("GetExtendedUdpTable", func(buf *byte, size *uint32) uintptr {
+		ret, _, _ := procGetExtendedUdpTable.Call(
+			uintptr(unsafe.Pointer(buf)),
+			uintptr(unsafe.Pointer(size)),
+			0,
+			uintptr(family),
+			uintptr(class),
+			0,
+		)
+		return ret
+	})
+}
 
-	// Get required size
-	procGetExtendedUdpTable.Call(
-		0,
-		uintptr(unsafe.Pointer(&size)),
-		0,
-		uintptr(family),
-		uintptr(class),
-		0,
-	)
+// lookupProcess resolves a PID and refreshes its cache entry.
+// The executable name is only re-queried when the process start time changed (PID reuse).
+func lookupProcess(cache *processCache, pid uint32, now time.Time) (string, bool) {
+	const PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
 
-	if size == 0 {
-		return nil, fmt.Errorf("failed to get UDP table size")
+	cache.countLookup()
+	handle, err := windows.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		cache.remove(pid)
+		return "", false
 	}
+	defer windows.CloseHandle(handle)
 
-	// Allocate buffer
-	buf := make([]byte, size)
-	ret, _, _ := procGetExtendedUdpTable.Call(
-		uintptr(unsafe.Pointer(&buf[0])),
-		uintptr(unsafe.Pointer(&size)),
-		0,
-		uintptr(family),
-		uintptr(class),
-		0,
-	)
+	startTime := getProcessStartTime(handle)
 
-	if ret != 0 {
-		return nil, fmt.Errorf("GetExtendedUdpTable failed with code %d", ret)
+	cached, ok := cache.peek(pid)
+	name := cached.name
+	if !ok || cached.startTime != startTime || name == "" {
+		name = getProcessPath(handle)
+		if name == "" {
+			return "", false
+		}
 	}
 
-	table := (*udpTable)(unsafe.Pointer(&buf[0]))
-	numEntries := int(table.NumEntries)
-	entries := unsafe.Slice(&table.Table[0], numEntries)
+	entry := cachedProc{
+		name:       name,
+		startTime:  startTime,
+		resolvedAt: now,
+		lastSeen:   now,
+	}
+	if startTime > 0 {
+		entry.firstSeen = time.Unix(0, startTime)
+	}
+	cache.put(pid, entry)
 
-	return entries, nil
+	return name, true
 }
 
-// getProcessPath retrieves the executable path for a process ID
-func getProcessPath(pid uint32) string {
-	const PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
-
-	handle, err := windows.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
-	if err != nil {
-		return ""
+// getProcessStartTime returns the creation time of a process, or 0 if unavailable
+func getProcessStartTime(handle windows.Handle) int64 {
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(handle, &creation, &exit, &kernel, &user); err != nil {
+		return 0
 	}
-	defer windows.CloseHandle(handle)
+	return creation.Nanoseconds()
+}
 
+// getProcessPath retrieves the executable name for an open process handle
+func getProcessPath(handle windows.Handle) string {
 	var buf [windows.MAX_PATH]uint16
 	size := uint32(len(buf))
 
-	err = windows.QueryFullProcessImageName(handle, 0, &buf[0], &size)
+	err := windows.QueryFullProcessImageName(handle, 0, &buf[0], &size)
 	if err != nil {
 		return ""
 	}
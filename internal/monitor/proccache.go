@@ -0,0 +1,221 @@
+package monitor
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	PROCESS_CACHE_TTL      = 30 * time.Second // How long a resolved PID name is trusted without re-checking
+	PROCESS_CACHE_MAX_SIZE = 4096             // Upper bound on cached PIDs
+	PROCESS_LOOKUP_WORKERS = 8                // Maximum concurrent process lookups per collection
+)
+
+// ProcessCacheStats reports how effective the PID name cache is
+type ProcessCacheStats struct {
+	Entries int    `json:"entries"`
+	Lookups uint64 `json:"lookups"` // OpenProcess calls made
+	Hits    uint64 `json:"hits"`    // PIDs answered from the cache
+}
+
+// cachedProc is a resolved process name along with the start time it was resolved for
+type cachedProc struct {
+	name       string
+	startTime  int64
+	resolvedAt time.Time
+	lastSeen   time.Time
+	firstSeen  time.Time // When the process started, or was first resolved if its start time is unknown
+}
+
+// processCache maps PIDs to process names across collection cycles
+type processCache struct {
+	entries map[uint32]cachedProc
+	mux     sync.Mutex
+	lookups atomic.Uint64
+	hits    atomic.Uint64
+
+	// Credit traffic of child processes to their root ancestor (set by SetRollupByProcessTree)
+	rollupTree atomic.Bool
+	// Leave connections to private and link-local addresses out of attribution (set by SetTrackLANTraffic)
+	skipLAN atomic.Bool
+}
+
+// newProcessCache creates an empty process cache
+func newProcessCache() *processCache {
+	return &processCache{
+		entries: make(map[uint32]cachedProc),
+	}
+}
+
+// processLookup resolves a PID the cache couldn't answer and refreshes its cache entry
+type processLookup func(cache *processCache, pid uint32, now time.Time) (string, bool)
+
+// resolveProcessNames returns process names for the given PIDs, using the cache where possible
+// and resolving unknown or stale PIDs with lookup, concurrently with a bounded worker pool
+func resolveProcessNames(cache *processCache, pids []uint32, lookup processLookup) map[uint32]string {
+	names := make(map[uint32]string, len(pids))
+	now := time.Now()
+	cache.expireAbsent(pids)
+
+	var pending []uint32
+	for _, pid := range pids {
+		if name, ok := cache.get(pid, now); ok {
+			names[pid] = name
+			continue
+		}
+		pending = append(pending, pid)
+	}
+
+	if len(pending) > 0 {
+		jobs := make(chan uint32)
+		var wg sync.WaitGroup
+		var resultMux sync.Mutex
+
+		workers := PROCESS_LOOKUP_WORKERS
+		if len(pending) < workers {
+			workers = len(pending)
+		}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for pid := range jobs {
+					name, ok := lookup(cache, pid, now)
+					if !ok {
+						continue
+					}
+					resultMux.Lock()
+					names[pid] = name
+					resultMux.Unlock()
+				}
+			}()
+		}
+
+		for _, pid := range pending {
+			jobs <- pid
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	cache.prune(now)
+	return names
+}
+
+// get returns a cached name if it was resolved within the TTL
+func (c *processCache) get(pid uint32, now time.Time) (string, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	entry, ok := c.entries[pid]
+	if !ok || now.Sub(entry.resolvedAt) >= PROCESS_CACHE_TTL {
+		return "", false
+	}
+	entry.lastSeen = now
+	c.entries[pid] = entry
+	c.hits.Add(1)
+	return entry.name, true
+}
+
+// expireAbsent marks entries for PIDs missing from the current collection as stale, so if the PID
+// shows up again it is re-checked against its start time instead of served from the cache. A PID
+// is only reused after its process exited, which drops that process's connections.
+func (c *processCache) expireAbsent(pids []uint32) {
+	present := make(map[uint32]bool, len(pids))
+	for _, pid := range pids {
+		present[pid] = true
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for pid, entry := range c.entries {
+		if !present[pid] && !entry.resolvedAt.IsZero() {
+			entry.resolvedAt = time.Time{}
+			c.entries[pid] = entry
+		}
+	}
+}
+
+// peek returns the cached entry for a PID regardless of age
+func (c *processCache) peek(pid uint32) (cachedProc, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	entry, ok := c.entries[pid]
+	return entry, ok
+}
+
+// put stores a resolved entry
+func (c *processCache) put(pid uint32, entry cachedProc) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	// A re-resolved process keeps the first-seen time from when it was first cached
+	if prev, ok := c.entries[pid]; ok && prev.startTime == entry.startTime && !prev.firstSeen.IsZero() {
+		entry.firstSeen = prev.firstSeen
+	} else if entry.firstSeen.IsZero() {
+		entry.firstSeen = entry.resolvedAt
+	}
+	c.entries[pid] = entry
+}
+
+// firstSeen returns when a cached process was first seen
+func (c *processCache) firstSeen(pid uint32) (time.Time, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	entry, ok := c.entries[pid]
+	return entry.firstSeen, ok && !entry.firstSeen.IsZero()
+}
+
+// remove drops a PID that no longer exists
+func (c *processCache) remove(pid uint32) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.entries, pid)
+}
+
+// countLookup records an OpenProcess call
+func (c *processCache) countLookup() {
+	c.lookups.Add(1)
+}
+
+// prune removes PIDs not seen recently and enforces the size bound
+func (c *processCache) prune(now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for pid, entry := range c.entries {
+		if now.Sub(entry.lastSeen) > PROCESS_CACHE_TTL {
+			delete(c.entries, pid)
+		}
+	}
+
+	if len(c.entries) <= PROCESS_CACHE_MAX_SIZE {
+		return
+	}
+
+	// Evict least recently seen entries first
+	pids := make([]uint32, 0, len(c.entries))
+	for pid := range c.entries {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool {
+		return c.entries[pids[i]].lastSeen.Before(c.entries[pids[j]].lastSeen)
+	})
+	for _, pid := range pids[:len(pids)-PROCESS_CACHE_MAX_SIZE] {
+		delete(c.entries, pid)
+	}
+}
+
+// stats returns cache effectiveness counters
+func (c *processCache) stats() ProcessCacheStats {
+	c.mux.Lock()
+	entries := len(c.entries)
+	c.mux.Unlock()
+
+	return ProcessCacheStats{
+		Entries: entries,
+		Lookups: c.lookups.Load(),
+		Hits:    c.hits.Load(),
+	}
+}
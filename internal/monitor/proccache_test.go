@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestProcessCacheExpiresAbsentPIDs(t *testing.T) {
+	cache := newProcessCache()
+	now := time.Now()
+	cache.put(100, cachedProc{name: "old.exe", startTime: 1, resolvedAt: now, lastSeen: now})
+	cache.put(200, cachedProc{name: "kept.exe", startTime: 2, resolvedAt: now, lastSeen: now})
+
+	// PID 100 has no connections this collection; its process may have exited
+	cache.expireAbsent([]uint32{200})
+
+	if _, ok := cache.get(100, now); ok {
+		t.Error("PID missing from the collection was still served from the cache")
+	}
+	if name, ok := cache.get(200, now); !ok || name != "kept.exe" {
+		t.Errorf("get(200) = %q, %v; want kept.exe from the cache", name, ok)
+	}
+	// The stale entry stays around so a lookup can compare start times and keep its first-seen time
+	if entry, ok := cache.peek(100); !ok || entry.startTime != 1 {
+		t.Errorf("stale entry was dropped: %+v, %v", entry, ok)
+	}
+}
+
+func BenchmarkProcessCacheCollection(b *testing.B) {
+	const PIDS = 500
+	cache := newProcessCache()
+	now := time.Now()
+	pids := make([]uint32, PIDS)
+	for i := range pids {
+		pids[i] = uint32(4 * (i + 1))
+		cache.put(pids[i], cachedProc{name: "app.exe", startTime: int64(i), resolvedAt: now, lastSeen: now})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Each collection sees all but one PID, rotating which one is absent
+		present := append([]uint32(nil), pids[:i%PIDS]...)
+		present = append(present, pids[i%PIDS+1:]...)
+		cache.expireAbsent(present)
+		for _, pid := range present {
+			if _, ok := cache.get(pid, now); !ok {
+				cache.put(pid, cachedProc{name: "app.exe", resolvedAt: now, lastSeen: now})
+			}
+		}
+	}
+}
+
+// fakeLookup resolves every PID like lookupProcess would, counting the OpenProcess call
+func fakeLookup(cache *processCache, pid uint32, now time.Time) (string, bool) {
+	cache.countLookup()
+	name := fmt.Sprintf("app%d.exe", pid%50)
+	cache.put(pid, cachedProc{name: name, startTime: int64(pid), resolvedAt: now, lastSeen: now})
+	return name, true
+}
+
+// BenchmarkResolveProcessNames compares OpenProcess calls per collection with the cache kept
+// across collections and with a fresh cache each time, as before it persisted
+func BenchmarkResolveProcessNames(b *testing.B) {
+	pids := make([]uint32, 300)
+	for i := range pids {
+		pids[i] = uint32(4 * (i + 1))
+	}
+
+	b.Run("persistent", func(b *testing.B) {
+		cache := newProcessCache()
+		for i := 0; i < b.N; i++ {
+			resolveProcessNames(cache, pids, fakeLookup)
+		}
+		stats := cache.stats()
+		b.ReportMetric(float64(stats.Lookups)/float64(b.N), "lookups/op")
+		b.ReportMetric(float64(stats.Hits)/float64(b.N), "hits/op")
+	})
+	b.Run("per-collection", func(b *testing.B) {
+		var lookups uint64
+		for i := 0; i < b.N; i++ {
+			cache := newProcessCache()
+			resolveProcessNames(cache, pids, fakeLookup)
+			lookups += cache.stats().Lookups
+		}
+		b.ReportMetric(float64(lookups)/float64(b.N), "lookups/op")
+	})
+}
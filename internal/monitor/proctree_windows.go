@@ -0,0 +1,119 @@
+//go:build windows
+
+package monitor
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"netpus/internal/utils"
+)
+
+// MAX_TREE_DEPTH bounds how far rollup climbs, in case reused PIDs form a cycle
+const MAX_TREE_DEPTH = 64
+
+// treeBoundaries are processes rollup never climbs into. Shells, session hosts and service hosts
+// parent nearly everything, so rolling up past them would merge unrelated apps.
+var treeBoundaries = map[string]bool{
+	"system":           true,
+	"[system process]": true,
+	"smss":             true,
+	"csrss":            true,
+	"wininit":          true,
+	"winlogon":         true,
+	"services":         true,
+	"svchost":          true,
+	"userinit":         true,
+	"explorer":         true,
+	"sihost":           true,
+	"taskhostw":        true,
+	"runtimebroker":    true,
+	"cmd":              true,
+	"powershell":       true,
+	"pwsh":             true,
+	"windowsterminal":  true,
+	"openconsole":      true,
+	"conhost":          true,
+}
+
+// processTree is one snapshot of every process's parent, with roots memoized as they are resolved
+type processTree struct {
+	parents    map[uint32]uint32
+	names      map[uint32]string // Canonical executable names
+	startTimes map[uint32]int64  // Creation times, looked up on demand; 0 if unknown
+	roots      map[uint32]uint32
+}
+
+// snapshotProcessTree reads the parent of every running process
+func snapshotProcessTree() (*processTree, error) {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPPROCESS, 0)
+	if err != nil {
+		return nil, fmt.Errorf("CreateToolhelp32Snapshot: %w", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	tree := &processTree{
+		parents:    make(map[uint32]uint32),
+		names:      make(map[uint32]string),
+		startTimes: make(map[uint32]int64),
+		roots:      make(map[uint32]uint32),
+	}
+	var entry windows.ProcessEntry32
+	entry.Size = uint32(unsafe.Sizeof(entry))
+	for err = windows.Process32First(snapshot, &entry); err == nil; err = windows.Process32Next(snapshot, &entry) {
+		tree.parents[entry.ProcessID] = entry.ParentProcessID
+		tree.names[entry.ProcessID] = utils.CanonicalAppName(windows.UTF16ToString(entry.ExeFile[:]))
+	}
+	return tree, nil
+}
+
+// startTime returns a process's creation time, remembering it for the rest of the cycle
+func (t *processTree) startTime(pid uint32) int64 {
+	const PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+
+	if startTime, ok := t.startTimes[pid]; ok {
+		return startTime
+	}
+	var startTime int64
+	if handle, err := windows.OpenProcess(PROCESS_QUERY_LIMITED_INFORMATION, false, pid); err == nil {
+		startTime = getProcessStartTime(handle)
+		windows.CloseHandle(handle)
+	}
+	t.startTimes[pid] = startTime
+	return startTime
+}
+
+// root returns the topmost ancestor of pid that can own its traffic. Climbing stops below boundary
+// processes, at parents that have exited, and at parents that started after their child, whose
+// PID was reused by an unrelated process.
+func (t *processTree) root(pid uint32) uint32 {
+	var chain []uint32
+	current := pid
+	for depth := 0; depth < MAX_TREE_DEPTH; depth++ {
+		if root, ok := t.roots[current]; ok {
+			current = root
+			break
+		}
+		chain = append(chain, current)
+
+		parent, ok := t.parents[current]
+		if !ok || parent == 0 || parent == current {
+			break
+		}
+		name, exists := t.names[parent]
+		if !exists || treeBoundaries[name] {
+			break
+		}
+		if parentStart, childStart := t.startTime(parent), t.startTime(current); parentStart != 0 && childStart != 0 && parentStart > childStart {
+			break
+		}
+		current = parent
+	}
+
+	for _, p := range chain {
+		t.roots[p] = current
+	}
+	return current
+}
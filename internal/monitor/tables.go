@@ -0,0 +1,52 @@
+package monitor
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Windows API result codes that map to typed collector errors
+const (
+	errorAccessDenied = 5  // ERROR_ACCESS_DENIED
+	errorNotSupported = 50 // ERROR_NOT_SUPPORTED, e.g. an address family that is not installed
+)
+
+// apiError converts a Windows API return code into a typed collector error
+func apiError(api string, code uintptr) error {
+	switch code {
+	case errorAccessDenied:
+		return fmt.Errorf("%s: %w", api, ErrPermissionDenied)
+	case errorNotSupported:
+		return fmt.Errorf("%s: %w", api, ErrTableUnavailable)
+	}
+	return fmt.Errorf("%s failed with code %d", api, code)
+}
+
+// tableCall calls GetExtendedTcpTable or GetExtendedUdpTable with buf (nil to ask for the size)
+// and returns the API result code
+type tableCall func(buf *byte, size *uint32) uintptr
+
+// connTable is the layout the connection table APIs fill in: a row count followed by the rows
+type connTable[T any] struct {
+	NumEntries uint32
+	Table      [1]T
+}
+
+// readTable reads a connection table, asking for the buffer size with a first call
+func readTable[T any](api string, call tableCall) ([]T, error) {
+	var size uint32
+	call(nil, &size)
+
+	// No connections at all is a valid, empty table rather than an error
+	if size == 0 {
+		return []T{}, nil
+	}
+
+	buf := make([]byte, size)
+	if ret := call(&buf[0], &size); ret != 0 {
+		return nil, apiError(api, ret)
+	}
+
+	table := (*connTable[T])(unsafe.Pointer(&buf[0]))
+	return unsafe.Slice(&table.Table[0], int(table.NumEntries)), nil
+}
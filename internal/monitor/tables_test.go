@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
+
+type testRow struct {
+	State uint32
+	PID   uint32
+}
+
+func TestReadTableNoConnections(t *testing.T) {
+	calls := 0
+	rows, err := readTable[testRow]("GetExtendedTcpTable", func(buf *byte, size *uint32) uintptr {
+		calls++
+		*size = 0
+		return 0
+	})
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	if rows == nil || len(rows) != 0 {
+		t.Errorf("rows = %#v, want an empty table", rows)
+	}
+	if calls != 1 {
+		t.Errorf("table API called %d times, want only the size query", calls)
+	}
+}
+
+func TestReadTableRows(t *testing.T) {
+	want := []testRow{{State: 5, PID: 100}, {State: 2, PID: 200}}
+	rows, err := readTable[testRow]("GetExtendedTcpTable", func(buf *byte, size *uint32) uintptr {
+		needed := uint32(unsafe.Sizeof(connTable[testRow]{})) + uint32(len(want)-1)*uint32(unsafe.Sizeof(testRow{}))
+		if buf == nil || *size < needed {
+			*size = needed
+			return 122 // ERROR_INSUFFICIENT_BUFFER
+		}
+		table := (*connTable[testRow])(unsafe.Pointer(buf))
+		table.NumEntries = uint32(len(want))
+		copy(unsafe.Slice(&table.Table[0], len(want)), want)
+		return 0
+	})
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	if len(rows) != len(want) || rows[0] != want[0] || rows[1] != want[1] {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestReadTableErrors(t *testing.T) {
+	tests := []struct {
+		code uintptr
+		want error
+	}{
+		{errorAccessDenied, ErrPermissionDenied},
+		{errorNotSupported, ErrTableUnavailable},
+	}
+	for _, tt := range tests {
+		_, err := readTable[testRow]("GetExtendedUdpTable", func(buf *byte, size *uint32) uintptr {
+			if buf == nil {
+				*size = 64
+				return 122
+			}
+			return tt.code
+		})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("code %d: err = %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
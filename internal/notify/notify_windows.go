@@ -0,0 +1,61 @@
+//go:build windows
+
+package notify
+
+import (
+	"fmt"
+	"html"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// toastScript shows a Windows toast notification through the WinRT notification APIs
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+[Windows.Data.Xml.Dom.XmlDocument, Windows.Data.Xml.Dom.XmlDocument, ContentType = WindowsRuntime] | Out-Null
+$xml = New-Object Windows.Data.Xml.Dom.XmlDocument
+$xml.LoadXml('<toast><visual><binding template="ToastGeneric"><text>%s</text><text>%s</text></binding></visual></toast>')
+$toast = New-Object Windows.UI.Notifications.ToastNotification $xml
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('%s').Show($toast)
+`
+
+// Notifier sends desktop notifications
+type Notifier struct {
+	appID      string
+	powershell string
+}
+
+// New creates a Notifier, failing if the notification subsystem is unavailable
+func New(appID string) (*Notifier, error) {
+	powershell, err := exec.LookPath("powershell.exe")
+	if err != nil {
+		return nil, fmt.Errorf("powershell not found: %w", err)
+	}
+	return &Notifier{
+		appID:      appID,
+		powershell: powershell,
+	}, nil
+}
+
+// Send shows a toast notification with the given title and message
+func (n *Notifier) Send(title, message string) error {
+	script := fmt.Sprintf(toastScript, escape(title), escape(message), psQuote(n.appID))
+
+	cmd := exec.Command(n.powershell, "-NoProfile", "-NonInteractive", "-Command", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to show notification: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// escape makes text safe inside the single-quoted toast XML literal
+func escape(text string) string {
+	return psQuote(html.EscapeString(text))
+}
+
+// psQuote escapes text for a PowerShell single-quoted string
+func psQuote(text string) string {
+	return strings.ReplaceAll(text, "'", "''")
+}
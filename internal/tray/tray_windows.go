@@ -4,8 +4,11 @@ package tray
 
 import (
 	"os"
+	"sync"
 
 	"github.com/energye/systray"
+
+	"netpus/internal/brand"
 )
 
 // Proper 16x16 32-bit ICO icon with visible network arrows (green up, orange down)
@@ -148,6 +151,10 @@ type Tray struct {
 	menuPause  *systray.MenuItem
 	menuResume *systray.MenuItem
 	menuQuit   *systray.MenuItem
+
+	// Pause state, kept so a state set before the menu exists is shown once it does
+	paused    bool
+	pausedMux sync.Mutex
 }
 
 // AppInterface defines the required methods from the main app
@@ -175,8 +182,8 @@ func (t *Tray) Setup() {
 func (t *Tray) onReady() {
 	// Set embedded icon
 	systray.SetIcon(defaultIcon)
-	systray.SetTitle("Netpus")
-	systray.SetTooltip("Netpus Network Monitor")
+	systray.SetTitle(brand.AppName)
+	systray.SetTooltip(brand.ProductName)
 
 	app, ok := t.app.(AppInterface)
 
@@ -197,6 +204,7 @@ func (t *Tray) onReady() {
 
 	systray.AddSeparator()
 
+	t.pausedMux.Lock()
 	t.menuPause = systray.AddMenuItem("Pause Monitoring", "Pause network monitoring")
 	if ok {
 		t.menuPause.Click(func() {
@@ -208,6 +216,11 @@ func (t *Tray) onReady() {
 
 	t.menuResume = systray.AddMenuItem("Resume Monitoring", "Resume network monitoring")
 	t.menuResume.Hide() // Initially hidden
+	if t.paused {
+		t.menuPause.Hide()
+		t.menuResume.Show()
+	}
+	t.pausedMux.Unlock()
 	if ok {
 		t.menuResume.Click(func() {
 			app.ResumeMonitoring()
@@ -235,13 +248,26 @@ func (t *Tray) onExit() {
 
 // handleMenuClicks is no longer needed - using Click callbacks instead
 
+// maxTooltipLength is the longest tooltip Windows will display (128 UTF-16 units including NUL)
+const maxTooltipLength = 127
+
 // UpdateTooltip updates the tray icon tooltip
 func (t *Tray) UpdateTooltip(text string) {
+	if runes := []rune(text); len(runes) > maxTooltipLength {
+		text = string(runes[:maxTooltipLength])
+	}
 	systray.SetTooltip(text)
 }
 
-// UpdatePauseState updates the pause/resume menu items
+// UpdatePauseState updates the pause/resume menu items. Called before the tray is ready, it
+// takes effect once the menu is created.
 func (t *Tray) UpdatePauseState(paused bool) {
+	t.pausedMux.Lock()
+	defer t.pausedMux.Unlock()
+	t.paused = paused
+	if t.menuPause == nil || t.menuResume == nil {
+		return
+	}
 	if paused {
 		t.menuPause.Hide()
 		t.menuResume.Show()
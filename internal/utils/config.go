@@ -1,44 +1,445 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
+	"log"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
+	"time"
+
+	"netpus/internal/brand"
+)
+
+// Data retention sentinel values; positive values are a number of days
+const (
+	RetentionDoNotSave = -2 // Collect live stats but never store them
+	RetentionForever   = -1 // Never delete by age
+	RetentionTestMode  = 0  // Delete records older than one minute (testing)
+)
+
+// RetentionMode classifies a data retention value
+type RetentionMode string
+
+const (
+	RetentionModeDoNotSave RetentionMode = "doNotSave"
+	RetentionModeForever   RetentionMode = "forever"
+	RetentionModeTest      RetentionMode = "test"
+	RetentionModeDays      RetentionMode = "days"
+	RetentionModeInvalid   RetentionMode = "invalid"
 )
 
+// ClassifyRetention returns the mode of a data retention value
+func ClassifyRetention(retention int) RetentionMode {
+	switch {
+	case retention == RetentionDoNotSave:
+		return RetentionModeDoNotSave
+	case retention == RetentionForever:
+		return RetentionModeForever
+	case retention == RetentionTestMode:
+		return RetentionModeTest
+	case retention > 0:
+		return RetentionModeDays
+	default:
+		return RetentionModeInvalid
+	}
+}
+
 // Config represents application configuration
 type Config struct {
-	AutoStart        bool   `json:"autoStart"`
-	Theme            string `json:"theme"`
-	DataRetention    int    `json:"dataRetention"`    // Days to keep data, 0 = forever
-	NetworkInterface string `json:"networkInterface"` // Reserved for future use
+	AutoStart                 bool                `json:"autoStart"`
+	Theme                     string              `json:"theme"`
+	DataRetention             int                 `json:"dataRetention"`             // Days to keep data, or one of the Retention* sentinels
+	NetworkInterface          string              `json:"networkInterface"`          // Reserved for future use
+	UseSIUnits                bool                `json:"useSIUnits"`                // Format sizes in base-1000 units (KB, MB) instead of base-1024 (KiB, MiB)
+	ExcludedApps              []string            `json:"excludedApps"`              // Apps that are never tracked or stored
+	StoreSystemTotals         bool                `json:"storeSystemTotals"`         // Also store raw system-wide totals, independent of per-app attribution
+	TrayFormat                string              `json:"trayFormat"`                // Tray tooltip template, supports {up} {down} {today} {active}
+	StartupDelaySeconds       int                 `json:"startupDelaySeconds"`       // Wait before monitoring when launched by autostart
+	ActiveWhenRunning         []string            `json:"activeWhenRunning"`         // If set, only monitor while one of these apps is running
+	TrayUnit                  DataUnit            `json:"trayUnit"`                  // Unit for tray tooltip values; empty follows UseSIUnits
+	DisplayUnit               DataUnit            `json:"displayUnit"`               // Unit for values shown in the window; empty follows UseSIUnits
+	ProxyApps                 []string            `json:"proxyApps"`                 // Proxy/VPN processes that funnel other apps' traffic
+	CrashJournal              bool                `json:"crashJournal"`              // Journal unflushed records to disk so a crash doesn't lose them
+	SummaryRetentionDays      int                 `json:"summaryRetentionDays"`      // Days to keep daily summaries; 0 keeps them forever
+	ConnectionRetentionDays   int                 `json:"connectionRetentionDays"`   // Days to keep per-destination totals; 0 follows DataRetention
+	Monitor                   MonitorConfig       `json:"monitor"`                   // Collection and write cadence
+	DestinationRules          []DestinationRule   `json:"destinationRules"`          // IP ranges that group traffic by destination service
+	QuietHoursStart           string              `json:"quietHoursStart"`           // "HH:MM" local time notifications stop; empty disables quiet hours
+	QuietHoursEnd             string              `json:"quietHoursEnd"`             // "HH:MM" local time notifications resume
+	QueueQuietAlerts          bool                `json:"queueQuietAlerts"`          // Deliver alerts held during quiet hours once they end, instead of dropping them
+	MaxCycleBytes             int64               `json:"maxCycleBytes"`             // Bytes per default-length (500ms) collection above which a cycle is suspect (e.g. a counter reset); scaled by the real elapsed time; 0 disables
+	OversizedCycleAction      string              `json:"oversizedCycleAction"`      // What to do with a suspect cycle: CycleActionDiscard or CycleActionClamp
+	AutoBackupDays            int                 `json:"autoBackupDays"`            // Back up the database every N days; 0 disables scheduled backups
+	BackupDir                 string              `json:"backupDir"`                 // Where backups go; empty uses a "backups" folder next to the database
+	BackupKeep                int                 `json:"backupKeep"`                // Newest backups kept when scheduled backups prune
+	MinProcessLifetimeSeconds int                 `json:"minProcessLifetimeSeconds"` // Younger processes are stored as "Transient" instead of by name; 0 disables
+	StorageGranularitySeconds int                 `json:"storageGranularitySeconds"` // Stored records are summed per app into buckets this long; 0 stores every collection
+	RollupByProcessTree       bool                `json:"rollupByProcessTree"`       // Credit child processes' traffic to the app that launched them
+	TrackLANTraffic           bool                `json:"trackLANTraffic"`           // Count traffic to private and link-local addresses toward app usage
+	UsageTierThresholds       UsageTierThresholds `json:"usageTierThresholds"`       // Byte totals at which an app counts as a medium or heavy user
+	SpeedTestURL              string              `json:"speedTestURL"`              // Payload downloaded by the speed test
+}
+
+// Oversized collection cycle handling
+const (
+	CycleActionDiscard = "discard" // Drop the cycle's bytes entirely
+	CycleActionClamp   = "clamp"   // Scale the cycle's bytes down to MaxCycleBytes
+
+	// Fastest traffic a collection is expected to see: a 2.5 Gbit/s link with 2x headroom
+	MaxLinkBytesPerSecond = 2 * 2500 * 1000 * 1000 / 8
+	// About 312 MB: what that rate moves in one collection at the default interval. The monitor
+	// scales the cap by each collection's real duration, so in effect it is a rate.
+	DefaultMaxCycleBytes = MaxLinkBytesPerSecond * DefaultUpdateIntervalMs / 1000
+)
+
+// ValidateCycleAction checks that an oversized cycle action is supported
+func ValidateCycleAction(action string) error {
+	if action != CycleActionDiscard && action != CycleActionClamp {
+		return fmt.Errorf("invalid oversized cycle action: %q (expected %q or %q)", action, CycleActionDiscard, CycleActionClamp)
+	}
+	return nil
+}
+
+// ParseClock parses an "HH:MM" time of day into minutes after midnight
+func ParseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (expected HH:MM)", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateQuietHours checks that quiet hours are either both unset or both valid times
+func ValidateQuietHours(start, end string) error {
+	if start == "" && end == "" {
+		return nil
+	}
+	if _, err := ParseClock(start); err != nil {
+		return fmt.Errorf("quiet hours start: %w", err)
+	}
+	if _, err := ParseClock(end); err != nil {
+		return fmt.Errorf("quiet hours end: %w", err)
+	}
+	return nil
+}
+
+// InQuietHours reports whether t falls within the configured quiet hours. Windows that span
+// midnight (e.g. 23:00-07:00) are supported; equal start and end mean no quiet hours.
+func (c *Config) InQuietHours(t time.Time) bool {
+	start, err := ParseClock(c.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := ParseClock(c.QuietHoursEnd)
+	if err != nil || start == end {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// DestinationRule labels traffic to an IPv4 range, e.g. a CDN or cloud provider
+type DestinationRule struct {
+	CIDR  string `json:"cidr"`  // e.g. "104.16.0.0/13"
+	Label string `json:"label"` // e.g. "Cloudflare"
+}
+
+// DestinationOther is the label for traffic no destination rule matches
+const DestinationOther = "Other"
+
+// DefaultDestinationRules is a small built-in set of well-known provider ranges. It is
+// deliberately approximate; providers publish their full, changing lists.
+var DefaultDestinationRules = []DestinationRule{
+	{CIDR: "10.0.0.0/8", Label: "Local network"},
+	{CIDR: "172.16.0.0/12", Label: "Local network"},
+	{CIDR: "192.168.0.0/16", Label: "Local network"},
+	{CIDR: "104.16.0.0/13", Label: "Cloudflare"},
+	{CIDR: "104.24.0.0/14", Label: "Cloudflare"},
+	{CIDR: "172.64.0.0/13", Label: "Cloudflare"},
+	{CIDR: "162.158.0.0/15", Label: "Cloudflare"},
+	{CIDR: "1.1.1.0/24", Label: "Cloudflare"},
+	{CIDR: "8.8.8.0/24", Label: "Google"},
+	{CIDR: "8.8.4.0/24", Label: "Google"},
+	{CIDR: "142.250.0.0/15", Label: "Google"},
+	{CIDR: "172.217.0.0/16", Label: "Google"},
+	{CIDR: "216.58.192.0/19", Label: "Google"},
+	{CIDR: "74.125.0.0/16", Label: "Google"},
+	{CIDR: "173.194.0.0/16", Label: "Google"},
+	{CIDR: "3.0.0.0/9", Label: "AWS"},
+	{CIDR: "52.0.0.0/10", Label: "AWS"},
+	{CIDR: "54.0.0.0/8", Label: "AWS"},
+	{CIDR: "13.32.0.0/15", Label: "AWS"},
+	{CIDR: "13.64.0.0/11", Label: "Microsoft"},
+	{CIDR: "20.0.0.0/8", Label: "Microsoft"},
+	{CIDR: "40.64.0.0/10", Label: "Microsoft"},
+	{CIDR: "23.32.0.0/11", Label: "Akamai"},
+	{CIDR: "23.192.0.0/11", Label: "Akamai"},
+	{CIDR: "2.16.0.0/13", Label: "Akamai"},
+	{CIDR: "151.101.0.0/16", Label: "Fastly"},
+}
+
+// ValidateDestinationRules checks that every rule has an IPv4 CIDR and a label
+func ValidateDestinationRules(rules []DestinationRule) error {
+	for _, rule := range rules {
+		ip, _, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			return fmt.Errorf("invalid destination range %q: %w", rule.CIDR, err)
+		}
+		if ip.To4() == nil {
+			return fmt.Errorf("destination range %q is not IPv4", rule.CIDR)
+		}
+		if strings.TrimSpace(rule.Label) == "" {
+			return fmt.Errorf("destination range %q has no label", rule.CIDR)
+		}
+	}
+	return nil
+}
+
+// MonitorConfig is the monitor's collection and write cadence
+type MonitorConfig struct {
+	UpdateIntervalMs     int `json:"updateIntervalMs"`     // Time between collections
+	BatchIntervalSeconds int `json:"batchIntervalSeconds"` // Time between database writes
+	CleanupThresholdMs   int `json:"cleanupThresholdMs"`   // Inactivity after which an app is shown as inactive
+	InactiveGraceMs      int `json:"inactiveGraceMs"`      // Further inactivity after which an app leaves the live view; 0 removes it right away
+	BatchSizeThreshold   int `json:"batchSizeThreshold"`   // Pending records that trigger an early write; 0 disables
+	WarmupSamples        int `json:"warmupSamples"`        // Collections after startup before stats stop being flagged as warming up
+	MaxTrackedApps       int `json:"maxTrackedApps"`       // Apps kept in live stats; the least recently updated are evicted beyond this
+}
+
+// DefaultMonitorConfig returns the built-in monitor cadence
+func DefaultMonitorConfig() MonitorConfig {
+	return MonitorConfig{
+		UpdateIntervalMs:     DefaultUpdateIntervalMs,
+		BatchIntervalSeconds: 10,
+		CleanupThresholdMs:   3000,
+		InactiveGraceMs:      60000,
+		BatchSizeThreshold:   5000,
+		WarmupSamples:        2,
+		MaxTrackedApps:       500,
+	}
+}
+
+// DefaultUpdateIntervalMs is the built-in time between collections
+const DefaultUpdateIntervalMs = 500
+
+// Monitor setting bounds enforced by ValidateMonitorConfig
+const (
+	MinUpdateIntervalMs     = 100
+	MaxUpdateIntervalMs     = 10000
+	MinBatchIntervalSeconds = 1
+	MaxBatchIntervalSeconds = 300
+	MaxCleanupThresholdMs   = 600000 // The minimum is twice the update interval
+	MaxInactiveGraceMs      = 3600000
+	MinBatchSizeThreshold   = 100 // 0 is also accepted and disables size-triggered writes
+	MaxBatchSizeThreshold   = 100000
+	MinWarmupSamples        = 1
+	MaxWarmupSamples        = 20
+	MinMaxTrackedApps       = 10
+	MaxMaxTrackedApps       = 100000
+)
+
+// ValidateMonitorConfig checks each monitor setting against its bounds
+func ValidateMonitorConfig(c MonitorConfig) error {
+	if c.UpdateIntervalMs < MinUpdateIntervalMs || c.UpdateIntervalMs > MaxUpdateIntervalMs {
+		return fmt.Errorf("update interval must be %d-%d ms, got %d",
+			MinUpdateIntervalMs, MaxUpdateIntervalMs, c.UpdateIntervalMs)
+	}
+	if c.BatchIntervalSeconds < MinBatchIntervalSeconds || c.BatchIntervalSeconds > MaxBatchIntervalSeconds {
+		return fmt.Errorf("batch interval must be %d-%d seconds, got %d",
+			MinBatchIntervalSeconds, MaxBatchIntervalSeconds, c.BatchIntervalSeconds)
+	}
+	if c.BatchIntervalSeconds*1000 < c.UpdateIntervalMs {
+		return fmt.Errorf("batch interval (%d s) must not be shorter than the update interval (%d ms)",
+			c.BatchIntervalSeconds, c.UpdateIntervalMs)
+	}
+	if c.CleanupThresholdMs < 2*c.UpdateIntervalMs || c.CleanupThresholdMs > MaxCleanupThresholdMs {
+		return fmt.Errorf("cleanup threshold must be between twice the update interval (%d ms) and %d ms, got %d",
+			2*c.UpdateIntervalMs, MaxCleanupThresholdMs, c.CleanupThresholdMs)
+	}
+	if c.InactiveGraceMs < 0 || c.InactiveGraceMs > MaxInactiveGraceMs {
+		return fmt.Errorf("inactive grace period must be 0-%d ms, got %d", MaxInactiveGraceMs, c.InactiveGraceMs)
+	}
+	if c.BatchSizeThreshold != 0 && (c.BatchSizeThreshold < MinBatchSizeThreshold || c.BatchSizeThreshold > MaxBatchSizeThreshold) {
+		return fmt.Errorf("batch size threshold must be 0 (disabled) or %d-%d, got %d",
+			MinBatchSizeThreshold, MaxBatchSizeThreshold, c.BatchSizeThreshold)
+	}
+	if c.WarmupSamples < MinWarmupSamples || c.WarmupSamples > MaxWarmupSamples {
+		return fmt.Errorf("warmup samples must be %d-%d, got %d", MinWarmupSamples, MaxWarmupSamples, c.WarmupSamples)
+	}
+	if c.MaxTrackedApps < MinMaxTrackedApps || c.MaxTrackedApps > MaxMaxTrackedApps {
+		return fmt.Errorf("tracked apps limit must be %d-%d, got %d", MinMaxTrackedApps, MaxMaxTrackedApps, c.MaxTrackedApps)
+	}
+	return nil
+}
+
+// DefaultSpeedTestURL serves a 25 MB payload from a global CDN
+const DefaultSpeedTestURL = "https://speed.cloudflare.com/__down?bytes=25000000"
+
+// ValidateSpeedTestURL checks that the speed test URL is an absolute http or https URL
+func ValidateSpeedTestURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid speed test URL %q: %w", rawURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid speed test URL %q (expected an http or https address)", rawURL)
+	}
+	return nil
+}
+
+// Usage tiers, from least to most traffic
+const (
+	TierLight  = "light"
+	TierMedium = "medium"
+	TierHeavy  = "heavy"
+)
+
+// UsageTierThresholds are the total bytes (upload plus download) at which an app moves up a tier
+type UsageTierThresholds struct {
+	MediumBytes int64 `json:"mediumBytes"` // Apps below this are light
+	HeavyBytes  int64 `json:"heavyBytes"`  // Apps at or above this are heavy
+}
+
+// DefaultUsageTierThresholds returns the built-in tiers: medium from 500 MiB, heavy from 5 GiB
+func DefaultUsageTierThresholds() UsageTierThresholds {
+	return UsageTierThresholds{
+		MediumBytes: 500 << 20,
+		HeavyBytes:  5 << 30,
+	}
+}
+
+// ValidateUsageTierThresholds checks that both thresholds are positive and in ascending order
+func ValidateUsageTierThresholds(t UsageTierThresholds) error {
+	if t.MediumBytes <= 0 {
+		return fmt.Errorf("medium usage threshold must be positive, got %d", t.MediumBytes)
+	}
+	if t.HeavyBytes <= t.MediumBytes {
+		return fmt.Errorf("heavy usage threshold (%d) must be above the medium threshold (%d)", t.HeavyBytes, t.MediumBytes)
+	}
+	return nil
+}
+
+// Tier classifies a total byte count as TierLight, TierMedium or TierHeavy
+func (t UsageTierThresholds) Tier(totalBytes int64) string {
+	switch {
+	case totalBytes >= t.HeavyBytes:
+		return TierHeavy
+	case totalBytes >= t.MediumBytes:
+		return TierMedium
+	default:
+		return TierLight
+	}
+}
+
+// DefaultProxyApps lists well-known local proxies and VPN clients that traffic is funneled through
+var DefaultProxyApps = []string{
+	"Fiddler.exe",
+	"Charles.exe",
+	"Proxifier.exe",
+	"openvpn.exe",
+	"wireguard.exe",
+	"tor.exe",
+	"privoxy.exe",
+	"v2ray.exe",
+	"xray.exe",
+	"clash.exe",
+	"Shadowsocks.exe",
+}
+
+// MaxStartupDelaySeconds is the longest autostart delay accepted
+const MaxStartupDelaySeconds = 300
+
+// MinBackupKeep is the fewest backups scheduled pruning may keep
+const MinBackupKeep = 1
+
+// MaxProcessLifetimeSeconds is the longest minimum process lifetime accepted
+const MaxProcessLifetimeSeconds = 3600
+
+// MaxStorageGranularitySeconds is the coarsest storage bucket accepted
+const MaxStorageGranularitySeconds = 3600
+
+// Themes lists the accepted theme names
+var Themes = []string{"auto", "light", "dark"}
+
+// ValidateTheme checks that a theme name is supported
+func ValidateTheme(theme string) error {
+	for _, name := range Themes {
+		if theme == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid theme: %s", theme)
 }
 
 // DefaultConfig returns default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		AutoStart:        false,
-		Theme:            "auto",
-		DataRetention:    30,
-		NetworkInterface: "",
+		AutoStart:            false,
+		Theme:                "auto",
+		DataRetention:        30,
+		NetworkInterface:     "",
+		UseSIUnits:           false,
+		ExcludedApps:         []string{},
+		StoreSystemTotals:    true,
+		TrayFormat:           DefaultTrayFormat,
+		StartupDelaySeconds:  10,
+		ActiveWhenRunning:    []string{},
+		ProxyApps:            append([]string{}, DefaultProxyApps...),
+		CrashJournal:         true,
+		Monitor:              DefaultMonitorConfig(),
+		DestinationRules:     append([]DestinationRule{}, DefaultDestinationRules...),
+		MaxCycleBytes:        DefaultMaxCycleBytes,
+		OversizedCycleAction: CycleActionDiscard,
+		AutoBackupDays:       7,
+		BackupKeep:           4,
+		TrackLANTraffic:      true,
+		UsageTierThresholds:  DefaultUsageTierThresholds(),
+		SpeedTestURL:         DefaultSpeedTestURL,
 	}
 }
 
 // LoadConfig loads configuration from database
 func LoadConfig(db interface{}) (*Config, error) {
+	return LoadConfigWithDefaults(db, DefaultConfig())
+}
+
+// LoadConfigFile reads a JSON config file. Fields missing from the file keep their default values.
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return config, nil
+}
+
+// LoadConfigWithDefaults loads configuration from database on top of the given defaults.
+// Any setting stored in the database wins; defaults only fill settings the database doesn't have,
+// so a config file seeds first-run values without overriding later user changes.
+func LoadConfigWithDefaults(db interface{}, defaults *Config) (*Config, error) {
 	type SettingsDB interface {
 		GetSetting(key string) (string, error)
 	}
 
+	config := *defaults
 	sdb, ok := db.(SettingsDB)
 	if !ok {
-		return DefaultConfig(), fmt.Errorf("invalid database interface")
+		return &config, fmt.Errorf("invalid database interface")
 	}
 
-	config := DefaultConfig()
-
 	if val, err := sdb.GetSetting("autoStart"); err == nil && val != "" {
 		config.AutoStart = val == "true"
 	}
@@ -57,7 +458,156 @@ func LoadConfig(db interface{}) (*Config, error) {
 		config.NetworkInterface = val
 	}
 
-	return config, nil
+	if val, err := sdb.GetSetting("useSIUnits"); err == nil && val != "" {
+		config.UseSIUnits = val == "true"
+	}
+
+	if val, err := sdb.GetSetting("storeSystemTotals"); err == nil && val != "" {
+		config.StoreSystemTotals = val == "true"
+	}
+
+	if val, err := sdb.GetSetting("crashJournal"); err == nil && val != "" {
+		config.CrashJournal = val == "true"
+	}
+
+	if val, err := sdb.GetSetting("trayFormat"); err == nil && val != "" {
+		if ValidateTrayFormat(val) == nil {
+			config.TrayFormat = val
+		}
+	}
+
+	if val, err := sdb.GetSetting("startupDelaySeconds"); err == nil && val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 && seconds <= MaxStartupDelaySeconds {
+			config.StartupDelaySeconds = seconds
+		}
+	}
+
+	if val, err := sdb.GetSetting("summaryRetentionDays"); err == nil && val != "" {
+		if days, err := strconv.Atoi(val); err == nil && days >= 0 {
+			config.SummaryRetentionDays = days
+		}
+	}
+
+	if val, err := sdb.GetSetting("connectionRetentionDays"); err == nil && val != "" {
+		if days, err := strconv.Atoi(val); err == nil && days >= 0 {
+			config.ConnectionRetentionDays = days
+		}
+	}
+
+	if val, err := sdb.GetSetting("trayUnit"); err == nil && val != "" && ValidateDataUnit(DataUnit(val)) == nil {
+		config.TrayUnit = DataUnit(val)
+	}
+
+	if val, err := sdb.GetSetting("displayUnit"); err == nil && val != "" && ValidateDataUnit(DataUnit(val)) == nil {
+		config.DisplayUnit = DataUnit(val)
+	}
+
+	if val, err := sdb.GetSetting("excludedApps"); err == nil && val != "" {
+		var apps []string
+		if err := json.Unmarshal([]byte(val), &apps); err == nil {
+			config.ExcludedApps = apps
+		}
+	}
+
+	if val, err := sdb.GetSetting("activeWhenRunning"); err == nil && val != "" {
+		var apps []string
+		if err := json.Unmarshal([]byte(val), &apps); err == nil {
+			config.ActiveWhenRunning = apps
+		}
+	}
+
+	if val, err := sdb.GetSetting("proxyApps"); err == nil && val != "" {
+		var apps []string
+		if err := json.Unmarshal([]byte(val), &apps); err == nil {
+			config.ProxyApps = apps
+		}
+	}
+
+	if val, err := sdb.GetSetting("destinationRules"); err == nil && val != "" {
+		var rules []DestinationRule
+		if err := json.Unmarshal([]byte(val), &rules); err == nil && ValidateDestinationRules(rules) == nil {
+			config.DestinationRules = rules
+		}
+	}
+
+	if val, err := sdb.GetSetting("quietHoursStart"); err == nil && val != "" {
+		config.QuietHoursStart = val
+	}
+
+	if val, err := sdb.GetSetting("quietHoursEnd"); err == nil && val != "" {
+		config.QuietHoursEnd = val
+	}
+
+	if val, err := sdb.GetSetting("queueQuietAlerts"); err == nil && val != "" {
+		config.QueueQuietAlerts = val == "true"
+	}
+
+	if val, err := sdb.GetSetting("maxCycleBytes"); err == nil && val != "" {
+		if bytes, err := strconv.ParseInt(val, 10, 64); err == nil && bytes >= 0 {
+			config.MaxCycleBytes = bytes
+		}
+	}
+
+	if val, err := sdb.GetSetting("oversizedCycleAction"); err == nil && val != "" && ValidateCycleAction(val) == nil {
+		config.OversizedCycleAction = val
+	}
+
+	if val, err := sdb.GetSetting("autoBackupDays"); err == nil && val != "" {
+		if days, err := strconv.Atoi(val); err == nil && days >= 0 {
+			config.AutoBackupDays = days
+		}
+	}
+
+	if val, err := sdb.GetSetting("backupDir"); err == nil && val != "" {
+		config.BackupDir = val
+	}
+
+	if val, err := sdb.GetSetting("minProcessLifetimeSeconds"); err == nil && val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 && seconds <= MaxProcessLifetimeSeconds {
+			config.MinProcessLifetimeSeconds = seconds
+		}
+	}
+
+	if val, err := sdb.GetSetting("trackLANTraffic"); err == nil && val != "" {
+		config.TrackLANTraffic = val == "true"
+	}
+
+	if val, err := sdb.GetSetting("rollupByProcessTree"); err == nil && val != "" {
+		config.RollupByProcessTree = val == "true"
+	}
+
+	if val, err := sdb.GetSetting("storageGranularitySeconds"); err == nil && val != "" {
+		if seconds, err := strconv.Atoi(val); err == nil && seconds >= 0 && seconds <= MaxStorageGranularitySeconds {
+			config.StorageGranularitySeconds = seconds
+		}
+	}
+
+	if val, err := sdb.GetSetting("backupKeep"); err == nil && val != "" {
+		if keep, err := strconv.Atoi(val); err == nil && keep >= MinBackupKeep {
+			config.BackupKeep = keep
+		}
+	}
+
+	if val, err := sdb.GetSetting("monitor"); err == nil && val != "" {
+		// Fields added after the setting was stored keep their defaults
+		monitorConfig := DefaultMonitorConfig()
+		if err := json.Unmarshal([]byte(val), &monitorConfig); err == nil && ValidateMonitorConfig(monitorConfig) == nil {
+			config.Monitor = monitorConfig
+		}
+	}
+
+	if val, err := sdb.GetSetting("speedTestURL"); err == nil && val != "" && ValidateSpeedTestURL(val) == nil {
+		config.SpeedTestURL = val
+	}
+
+	if val, err := sdb.GetSetting("usageTierThresholds"); err == nil && val != "" {
+		var thresholds UsageTierThresholds
+		if err := json.Unmarshal([]byte(val), &thresholds); err == nil && ValidateUsageTierThresholds(thresholds) == nil {
+			config.UsageTierThresholds = thresholds
+		}
+	}
+
+	return &config, nil
 }
 
 // Save saves configuration to database
@@ -87,26 +637,315 @@ func (c *Config) Save(db interface{}) error {
 		return err
 	}
 
+	if err := sdb.SetSetting("useSIUnits", strconv.FormatBool(c.UseSIUnits)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("storeSystemTotals", strconv.FormatBool(c.StoreSystemTotals)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("crashJournal", strconv.FormatBool(c.CrashJournal)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("trayFormat", c.TrayFormat); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("startupDelaySeconds", strconv.Itoa(c.StartupDelaySeconds)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("summaryRetentionDays", strconv.Itoa(c.SummaryRetentionDays)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("connectionRetentionDays", strconv.Itoa(c.ConnectionRetentionDays)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("trayUnit", string(c.TrayUnit)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("displayUnit", string(c.DisplayUnit)); err != nil {
+		return err
+	}
+
+	excluded := c.ExcludedApps
+	if excluded == nil {
+		excluded = []string{}
+	}
+	excludedJSON, err := json.Marshal(excluded)
+	if err != nil {
+		return err
+	}
+	if err := sdb.SetSetting("excludedApps", string(excludedJSON)); err != nil {
+		return err
+	}
+
+	watched := c.ActiveWhenRunning
+	if watched == nil {
+		watched = []string{}
+	}
+	watchedJSON, err := json.Marshal(watched)
+	if err != nil {
+		return err
+	}
+	if err := sdb.SetSetting("activeWhenRunning", string(watchedJSON)); err != nil {
+		return err
+	}
+
+	proxies := c.ProxyApps
+	if proxies == nil {
+		proxies = []string{}
+	}
+	proxiesJSON, err := json.Marshal(proxies)
+	if err != nil {
+		return err
+	}
+	if err := sdb.SetSetting("proxyApps", string(proxiesJSON)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("quietHoursStart", c.QuietHoursStart); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("quietHoursEnd", c.QuietHoursEnd); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("queueQuietAlerts", strconv.FormatBool(c.QueueQuietAlerts)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("maxCycleBytes", strconv.FormatInt(c.MaxCycleBytes, 10)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("oversizedCycleAction", c.OversizedCycleAction); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("autoBackupDays", strconv.Itoa(c.AutoBackupDays)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("backupDir", c.BackupDir); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("backupKeep", strconv.Itoa(c.BackupKeep)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("minProcessLifetimeSeconds", strconv.Itoa(c.MinProcessLifetimeSeconds)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("storageGranularitySeconds", strconv.Itoa(c.StorageGranularitySeconds)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("rollupByProcessTree", strconv.FormatBool(c.RollupByProcessTree)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("trackLANTraffic", strconv.FormatBool(c.TrackLANTraffic)); err != nil {
+		return err
+	}
+
+	if err := sdb.SetSetting("speedTestURL", c.SpeedTestURL); err != nil {
+		return err
+	}
+
+	rules := c.DestinationRules
+	if rules == nil {
+		rules = []DestinationRule{}
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	if err := sdb.SetSetting("destinationRules", string(rulesJSON)); err != nil {
+		return err
+	}
+
+	monitorJSON, err := json.Marshal(c.Monitor)
+	if err != nil {
+		return err
+	}
+	if err := sdb.SetSetting("monitor", string(monitorJSON)); err != nil {
+		return err
+	}
+
+	tiersJSON, err := json.Marshal(c.UsageTierThresholds)
+	if err != nil {
+		return err
+	}
+	if err := sdb.SetSetting("usageTierThresholds", string(tiersJSON)); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// GetDatabasePath returns the platform-specific database path
-func GetDatabasePath() string {
-	var basePath string
+// RetentionMode classifies the configured data retention
+func (c *Config) RetentionMode() RetentionMode {
+	return ClassifyRetention(c.DataRetention)
+}
+
+// IsAppExcluded reports whether an app is in the exclusion list
+func (c *Config) IsAppExcluded(appName string) bool {
+	canonical := CanonicalAppName(appName)
+	for _, excluded := range c.ExcludedApps {
+		if CanonicalAppName(excluded) == canonical {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalAppName normalizes an app name for comparison (case-insensitive, ".exe" optional)
+func CanonicalAppName(appName string) string {
+	name := strings.ToLower(strings.TrimSpace(appName))
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// databaseLocationFile names the file in the default data folder that records where the user
+// moved the database, so later launches open it there
+const databaseLocationFile = "database-location"
+
+// GetDatabasePath returns the platform-specific database path, or the path saved by
+// SaveDatabaseLocation if the user moved the database. Service accounts and minimal containers can
+// lack APPDATA/HOME, so when the usual per-user folder is unknown or not writable it falls back to
+// the user config folder, the executable's folder and finally the temp folder. It fails if none of
+// them is writable rather than placing the database somewhere arbitrary.
+func GetDatabasePath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	if saved, err := os.ReadFile(filepath.Join(dir, databaseLocationFile)); err == nil {
+		if path := strings.TrimSpace(string(saved)); filepath.IsAbs(path) {
+			return path, nil
+		}
+	}
+	return filepath.Join(dir, brand.DatabaseFileName()), nil
+}
+
+// SaveDatabaseLocation records dbPath as the database to open on later launches
+func SaveDatabaseLocation(dbPath string) error {
+	dir, err := dataDir()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, databaseLocationFile), []byte(dbPath), 0644)
+}
 
-	if runtime.GOOS == "windows" {
-		basePath = os.Getenv("APPDATA")
-		if basePath == "" {
-			basePath = filepath.Join(os.Getenv("USERPROFILE"), "AppData", "Roaming")
+// dataDir returns the first writable data folder, creating it if needed
+func dataDir() (string, error) {
+	return findDataDir(osDataDirEnv)
+}
+
+// findDataDir returns the first writable data folder among env's candidates. Falling back from
+// the per-user folder is logged, since it moves where the database is kept.
+func findDataDir(env dataDirEnv) (string, error) {
+	preferred, fallbacks := dataDirCandidates(env)
+	candidates := append(append([]string(nil), preferred...), fallbacks...)
+	for i, base := range candidates {
+		dir := filepath.Join(base, brand.DataDirName)
+		if err := checkWritableDir(dir); err != nil {
+			continue
+		}
+		if i >= len(preferred) {
+			if len(preferred) == 0 {
+				log.Printf("No per-user data folder is set (APPDATA/HOME missing); using fallback data folder %s", dir)
+			} else {
+				log.Printf("Per-user data folders (%s) are not writable; using fallback data folder %s", strings.Join(preferred, ", "), dir)
+			}
+		}
+		return dir, nil
+	}
+	return "", fmt.Errorf("no writable folder for the database (tried %s)", strings.Join(candidates, ", "))
+}
+
+// dataDirEnv is what the data folder lookup reads from the system, so tests can fake it
+type dataDirEnv struct {
+	goos          string
+	getenv        func(string) string
+	userConfigDir func() (string, error)
+	executable    func() (string, error)
+	tempDir       func() string
+}
+
+// osDataDirEnv reads the real environment
+var osDataDirEnv = dataDirEnv{
+	goos:          runtime.GOOS,
+	getenv:        os.Getenv,
+	userConfigDir: os.UserConfigDir,
+	executable:    os.Executable,
+	tempDir:       os.TempDir,
+}
+
+// dataDirCandidates lists the folders the data directory may be created in, most preferred first:
+// the per-user folders named by the environment, then the fallbacks (user config folder, the
+// executable's folder, the temp folder). Unset or relative paths are skipped, so the database
+// never lands at the filesystem root or in the working directory.
+func dataDirCandidates(env dataDirEnv) (preferred, fallbacks []string) {
+	var userBases []string
+	if env.goos == "windows" {
+		userBases = append(userBases, env.getenv("APPDATA"))
+		if profile := env.getenv("USERPROFILE"); profile != "" {
+			userBases = append(userBases, filepath.Join(profile, "AppData", "Roaming"))
 		}
 	} else {
-		basePath = os.Getenv("XDG_DATA_HOME")
-		if basePath == "" {
-			basePath = filepath.Join(os.Getenv("HOME"), ".local", "share")
+		userBases = append(userBases, env.getenv("XDG_DATA_HOME"))
+		if home := env.getenv("HOME"); home != "" {
+			userBases = append(userBases, filepath.Join(home, ".local", "share"))
 		}
 	}
 
-	return filepath.Join(basePath, "netpus", "netpus.db")
+	var fallbackBases []string
+	if configDir, err := env.userConfigDir(); err == nil {
+		fallbackBases = append(fallbackBases, configDir)
+	}
+	if execPath, err := env.executable(); err == nil {
+		fallbackBases = append(fallbackBases, filepath.Dir(execPath))
+	}
+	fallbackBases = append(fallbackBases, env.tempDir())
+
+	seen := make(map[string]bool, len(userBases)+len(fallbackBases))
+	usable := func(bases []string) []string {
+		var result []string
+		for _, base := range bases {
+			if base == "" || !filepath.IsAbs(base) || seen[base] {
+				continue
+			}
+			seen[base] = true
+			result = append(result, base)
+		}
+		return result
+	}
+	preferred = usable(userBases)
+	fallbacks = usable(fallbackBases)
+	return preferred, fallbacks
+}
+
+// checkWritableDir creates dir if needed and verifies a file can be written in it
+func checkWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	name := probe.Name()
+	probe.Close()
+	return os.Remove(name)
 }
 
 // GetExecutablePath returns the current executable path
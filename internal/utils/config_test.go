@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"netpus/internal/brand"
+)
+
+// fakeDataDirEnv returns an environment with the given variables and fallback folders
+func fakeDataDirEnv(vars map[string]string, configDir, execDir, tempDir string) dataDirEnv {
+	return dataDirEnv{
+		goos:   runtime.GOOS,
+		getenv: func(key string) string { return vars[key] },
+		userConfigDir: func() (string, error) {
+			if configDir == "" {
+				return "", errors.New("neither $XDG_CONFIG_HOME nor $HOME are defined")
+			}
+			return configDir, nil
+		},
+		executable: func() (string, error) {
+			if execDir == "" {
+				return "", errors.New("executable path unavailable")
+			}
+			return filepath.Join(execDir, "netpus"), nil
+		},
+		tempDir: func() string { return tempDir },
+	}
+}
+
+func TestDataDirCandidates(t *testing.T) {
+	root := t.TempDir()
+	configDir := filepath.Join(root, "config")
+	execDir := filepath.Join(root, "bin")
+	tempDir := filepath.Join(root, "tmp")
+	home := filepath.Join(root, "home")
+
+	userVars := map[string]string{"HOME": home, "USERPROFILE": home}
+	userDir := filepath.Join(home, ".local", "share")
+	if runtime.GOOS == "windows" {
+		userDir = filepath.Join(home, "AppData", "Roaming")
+	}
+
+	tests := []struct {
+		name          string
+		env           dataDirEnv
+		wantPreferred []string
+		wantFallbacks []string
+	}{
+		{
+			name:          "missing env",
+			env:           fakeDataDirEnv(nil, "", execDir, tempDir),
+			wantFallbacks: []string{execDir, tempDir},
+		},
+		{
+			name:          "relative env",
+			env:           fakeDataDirEnv(map[string]string{"HOME": "home", "USERPROFILE": "home", "APPDATA": "data", "XDG_DATA_HOME": "data"}, configDir, execDir, tempDir),
+			wantFallbacks: []string{configDir, execDir, tempDir},
+		},
+		{
+			name:          "per-user folder",
+			env:           fakeDataDirEnv(userVars, configDir, execDir, tempDir),
+			wantPreferred: []string{userDir},
+			wantFallbacks: []string{configDir, execDir, tempDir},
+		},
+		{
+			name:          "duplicates",
+			env:           fakeDataDirEnv(nil, tempDir, tempDir, tempDir),
+			wantFallbacks: []string{tempDir},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preferred, fallbacks := dataDirCandidates(tt.env)
+			if !reflect.DeepEqual(preferred, tt.wantPreferred) {
+				t.Errorf("preferred = %v, want %v", preferred, tt.wantPreferred)
+			}
+			if !reflect.DeepEqual(fallbacks, tt.wantFallbacks) {
+				t.Errorf("fallbacks = %v, want %v", fallbacks, tt.wantFallbacks)
+			}
+		})
+	}
+}
+
+func TestFindDataDirMissingEnv(t *testing.T) {
+	root := t.TempDir()
+	tempDir := filepath.Join(root, "tmp")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The executable's folder is a file, so it can't hold the data folder
+	blocked := filepath.Join(root, "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := findDataDir(fakeDataDirEnv(nil, "", blocked, tempDir))
+	if err != nil {
+		t.Fatalf("findDataDir: %v", err)
+	}
+	if want := filepath.Join(tempDir, brand.DataDirName); dir != want {
+		t.Errorf("data folder = %s, want %s", dir, want)
+	}
+
+	if _, err := findDataDir(fakeDataDirEnv(nil, "", blocked, "")); err == nil {
+		t.Error("findDataDir succeeded without any usable folder")
+	}
+}
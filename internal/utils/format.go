@@ -2,26 +2,168 @@ package utils
 
 import (
 	"fmt"
+	"hash/fnv"
+	"math"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"netpus/internal/brand"
 )
 
+// useSIUnits selects base-1000 (KB, MB) instead of base-1024 (KiB, MiB) formatting
+var useSIUnits atomic.Bool
+
+// SetSIUnits switches byte formatting between SI (base-1000) and binary (base-1024) units
+func SetSIUnits(enabled bool) {
+	useSIUnits.Store(enabled)
+}
+
+// DataUnit selects how sizes and speeds are formatted
+type DataUnit string
+
+const (
+	UnitDefault DataUnit = ""       // Follow the global SI setting
+	UnitBinary  DataUnit = "binary" // KiB, MiB (base 1024)
+	UnitSI      DataUnit = "si"     // KB, MB (base 1000)
+	UnitBits    DataUnit = "bits"   // Speeds in Kbps, Mbps; sizes in KB, MB
+)
+
+// ValidateDataUnit checks that a unit name is supported
+func ValidateDataUnit(unit DataUnit) error {
+	switch unit {
+	case UnitDefault, UnitBinary, UnitSI, UnitBits:
+		return nil
+	}
+	return fmt.Errorf("unknown data unit: %q", unit)
+}
+
 // FormatBytes formats bytes into human-readable format
 func FormatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+	return FormatBytesAs(bytes, UnitDefault)
+}
+
+// FormatSpeed formats bytes per second into human-readable format
+func FormatSpeed(bytesPerSecond int64) string {
+	return FormatSpeedAs(bytesPerSecond, UnitDefault)
+}
+
+// FormatBytesAs formats bytes using the given unit
+func FormatBytesAs(bytes int64, unit DataUnit) string {
+	si := useSIUnits.Load()
+	switch unit {
+	case UnitBinary:
+		si = false
+	case UnitSI, UnitBits:
+		si = true
 	}
 
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
+	if si {
+		return formatScaled(bytes, 1000, "B", []string{"KB", "MB", "GB", "TB", "PB", "EB"})
+	}
+	return formatScaled(bytes, 1024, "B", []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"})
+}
+
+// FormatSpeedAs formats bytes per second using the given unit
+func FormatSpeedAs(bytesPerSecond int64, unit DataUnit) string {
+	if unit == UnitBits {
+		return formatScaled(bytesPerSecond*8, 1000, "bps", []string{"Kbps", "Mbps", "Gbps", "Tbps", "Pbps", "Ebps"})
+	}
+	return FormatBytesAs(bytesPerSecond, unit) + "/s"
+}
+
+// formatScaled divides value by powers of base until it fits and appends the matching unit
+func formatScaled(value, base int64, baseUnit string, units []string) string {
+	if value < base {
+		return fmt.Sprintf("%d %s", value, baseUnit)
+	}
+
+	div, exp := base, 0
+	for n := value / base; n >= base; n /= base {
+		div *= base
 		exp++
 	}
 
-	units := []string{"KB", "MB", "GB", "TB", "PB"}
-	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), units[exp])
+	// Just below the next unit the value rounds up to the base ("1024.0 KiB"); show it as that unit
+	scaled := float64(value) / float64(div)
+	if math.Round(scaled*10)/10 >= float64(base) && exp+1 < len(units) {
+		scaled /= float64(base)
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", scaled, units[exp])
 }
 
-// FormatSpeed formats bytes per second into human-readable format
-func FormatSpeed(bytesPerSecond int64) string {
-	return FormatBytes(bytesPerSecond) + "/s"
+// DefaultTrayFormat is the tray tooltip template used when none is configured
+var DefaultTrayFormat = brand.AppName + "\n↑ {up} ↓ {down}"
+
+// TrayPlaceholders lists the placeholders supported in tray tooltip templates
+var TrayPlaceholders = []string{"up", "down", "today", "active"}
+
+var placeholderPattern = regexp.MustCompile(`\{([a-zA-Z]+)\}`)
+
+// ValidateTrayFormat checks that a tray template only references known placeholders
+func ValidateTrayFormat(format string) error {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(format, -1) {
+		known := false
+		for _, name := range TrayPlaceholders {
+			if match[1] == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown placeholder: {%s}", match[1])
+		}
+	}
+	return nil
+}
+
+// RenderTrayFormat substitutes placeholder values into a tray template
+func RenderTrayFormat(format string, values map[string]string) string {
+	pairs := make([]string, 0, len(values)*2)
+	for name, value := range values {
+		pairs = append(pairs, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(format)
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidateColor checks that a color is a "#rrggbb" hex string
+func ValidateColor(color string) error {
+	if !colorPattern.MatchString(color) {
+		return fmt.Errorf("invalid color %q (expected #rrggbb)", color)
+	}
+	return nil
+}
+
+// DefaultAppColor derives a stable chart color from an app name, so an app keeps its color
+// across sessions without one being assigned. Name variants that canonicalize alike share it.
+func DefaultAppColor(appName string) string {
+	h := fnv.New32a()
+	h.Write([]byte(CanonicalAppName(appName)))
+	hue := float64(h.Sum32()%360) / 60
+
+	// HSL with fixed saturation and lightness, readable on light and dark themes
+	const saturation, lightness = 0.65, 0.5
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue, 2)-1))
+	var r, g, b float64
+	switch int(hue) {
+	case 0:
+		r, g = chroma, x
+	case 1:
+		r, g = x, chroma
+	case 2:
+		g, b = chroma, x
+	case 3:
+		g, b = x, chroma
+	case 4:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	m := lightness - chroma/2
+	return fmt.Sprintf("#%02x%02x%02x",
+		int(math.Round((r+m)*255)), int(math.Round((g+m)*255)), int(math.Round((b+m)*255)))
 }
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatBytesBoundaries(t *testing.T) {
+	defer SetSIUnits(useSIUnits.Load())
+
+	tests := []struct {
+		bytes int64
+		si    bool
+		want  string
+	}{
+		{0, false, "0 B"},
+		{1023, false, "1023 B"},
+		{1024, false, "1.0 KiB"},
+		{1536, false, "1.5 KiB"},
+		{1048575, false, "1.0 MiB"},
+		{1 << 20, false, "1.0 MiB"},
+		{1<<30 - 1, false, "1.0 GiB"},
+		{1 << 40, false, "1.0 TiB"},
+		{math.MaxInt64, false, "8.0 EiB"},
+		{0, true, "0 B"},
+		{999, true, "999 B"},
+		{1000, true, "1.0 KB"},
+		{1024, true, "1.0 KB"},
+		{999949, true, "999.9 KB"},
+		{999999, true, "1.0 MB"},
+		{1000000, true, "1.0 MB"},
+		{1500000000, true, "1.5 GB"},
+		{math.MaxInt64, true, "9.2 EB"},
+	}
+	for _, tt := range tests {
+		SetSIUnits(tt.si)
+		if got := FormatBytes(tt.bytes); got != tt.want {
+			t.Errorf("FormatBytes(%d) with SI=%v = %q, want %q", tt.bytes, tt.si, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSpeedBoundaries(t *testing.T) {
+	defer SetSIUnits(useSIUnits.Load())
+
+	tests := []struct {
+		bytesPerSecond int64
+		unit           DataUnit
+		si             bool
+		want           string
+	}{
+		{1023, UnitDefault, false, "1023 B/s"},
+		{1024, UnitDefault, false, "1.0 KiB/s"},
+		{999, UnitDefault, true, "999 B/s"},
+		{1000, UnitDefault, true, "1.0 KB/s"},
+		{1024, UnitSI, false, "1.0 KB/s"},
+		{1000, UnitBinary, true, "1000 B/s"},
+		{124, UnitBits, false, "992 bps"},
+		{125, UnitBits, false, "1.0 Kbps"},
+		{125000, UnitBits, false, "1.0 Mbps"},
+		{124999, UnitBits, false, "1.0 Mbps"},
+	}
+	for _, tt := range tests {
+		SetSIUnits(tt.si)
+		if got := FormatSpeedAs(tt.bytesPerSecond, tt.unit); got != tt.want {
+			t.Errorf("FormatSpeedAs(%d, %q) with SI=%v = %q, want %q", tt.bytesPerSecond, tt.unit, tt.si, got, tt.want)
+		}
+	}
+	SetSIUnits(false)
+	if got := FormatSpeed(2048); got != "2.0 KiB/s" {
+		t.Errorf("FormatSpeed(2048) = %q, want 2.0 KiB/s", got)
+	}
+}
@@ -0,0 +1,130 @@
+package utils
+
+// SettingOption is one allowed value of a setting
+type SettingOption struct {
+	Value interface{} `json:"value"`
+	Label string      `json:"label"`
+}
+
+// SettingSchema describes one Config field so a settings form can be rendered and validated
+// without hardcoding it. Bounds and options mirror the checks in UpdateSettings.
+type SettingSchema struct {
+	Key      string          `json:"key"`                // JSON field name in Config
+	Type     string          `json:"type"`               // "bool", "int", "string", "clock", "stringList", "object" or "objectList"
+	Label    string          `json:"label"`              // Human-readable name
+	Default  interface{}     `json:"default"`            // Value in DefaultConfig
+	Options  []SettingOption `json:"options,omitempty"`  // Allowed values; for ints, sentinels accepted besides Min-Max
+	Min      *int            `json:"min,omitempty"`      // Smallest accepted int, when bounded
+	Max      *int            `json:"max,omitempty"`      // Largest accepted int, when bounded
+	Optional bool            `json:"optional,omitempty"` // Empty is accepted (for strings)
+	Note     string          `json:"note,omitempty"`     // Constraints the other fields can't express
+	Fields   []SettingSchema `json:"fields,omitempty"`   // Members of object and objectList settings
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// ConfigSchema returns the schema of every user-editable Config field
+func ConfigSchema() []SettingSchema {
+	defaults := DefaultConfig()
+
+	themes := make([]SettingOption, len(Themes))
+	for i, theme := range Themes {
+		themes[i] = SettingOption{Value: theme, Label: theme}
+	}
+	units := []SettingOption{
+		{Value: UnitDefault, Label: "Follow SI setting"},
+		{Value: UnitBinary, Label: "Binary (KiB, MiB)"},
+		{Value: UnitSI, Label: "SI (KB, MB)"},
+		{Value: UnitBits, Label: "Bits (Kbps, Mbps)"},
+	}
+	appList := func(key, label string, def []string) SettingSchema {
+		return SettingSchema{Key: key, Type: "stringList", Label: label, Default: def}
+	}
+
+	return []SettingSchema{
+		{Key: "autoStart", Type: "bool", Label: "Start with Windows", Default: defaults.AutoStart},
+		{Key: "startupDelaySeconds", Type: "int", Label: "Startup delay (seconds)", Default: defaults.StartupDelaySeconds,
+			Min: intPtr(0), Max: intPtr(MaxStartupDelaySeconds)},
+		{Key: "theme", Type: "string", Label: "Theme", Default: defaults.Theme, Options: themes},
+		{Key: "dataRetention", Type: "int", Label: "Keep records (days)", Default: defaults.DataRetention,
+			Min: intPtr(1), Options: []SettingOption{
+				{Value: RetentionDoNotSave, Label: "Don't save"},
+				{Value: RetentionForever, Label: "Forever"},
+				{Value: RetentionTestMode, Label: "1 minute (testing)"},
+			}},
+		{Key: "summaryRetentionDays", Type: "int", Label: "Keep daily summaries (days)", Default: defaults.SummaryRetentionDays,
+			Min: intPtr(1), Options: []SettingOption{{Value: 0, Label: "Forever"}}},
+		{Key: "connectionRetentionDays", Type: "int", Label: "Keep destination totals (days)", Default: defaults.ConnectionRetentionDays,
+			Min: intPtr(1), Options: []SettingOption{{Value: 0, Label: "Same as records"}}},
+		{Key: "useSIUnits", Type: "bool", Label: "Use SI units (KB, MB)", Default: defaults.UseSIUnits},
+		{Key: "displayUnit", Type: "string", Label: "Window units", Default: defaults.DisplayUnit, Options: units},
+		{Key: "trayUnit", Type: "string", Label: "Tray units", Default: defaults.TrayUnit, Options: units},
+		{Key: "trayFormat", Type: "string", Label: "Tray tooltip", Default: defaults.TrayFormat, Optional: true,
+			Note: "Placeholders: {up} {down} {today} {active}; an invalid template falls back to the default"},
+		{Key: "storeSystemTotals", Type: "bool", Label: "Store system-wide totals", Default: defaults.StoreSystemTotals},
+		{Key: "crashJournal", Type: "bool", Label: "Journal unsaved records to survive crashes", Default: defaults.CrashJournal},
+		appList("excludedApps", "Never track these apps", defaults.ExcludedApps),
+		appList("activeWhenRunning", "Only monitor while one of these apps runs", defaults.ActiveWhenRunning),
+		appList("proxyApps", "Proxy/VPN apps", defaults.ProxyApps),
+		{Key: "quietHoursStart", Type: "clock", Label: "Quiet hours start", Default: defaults.QuietHoursStart, Optional: true,
+			Note: "HH:MM; set both start and end, or neither"},
+		{Key: "quietHoursEnd", Type: "clock", Label: "Quiet hours end", Default: defaults.QuietHoursEnd, Optional: true,
+			Note: "HH:MM; set both start and end, or neither"},
+		{Key: "queueQuietAlerts", Type: "bool", Label: "Deliver alerts held during quiet hours", Default: defaults.QueueQuietAlerts},
+		{Key: "maxCycleBytes", Type: "int", Label: "Suspect collections over (bytes)", Default: defaults.MaxCycleBytes,
+			Min: intPtr(1), Options: []SettingOption{{Value: 0, Label: "Never"}},
+			Note: "Per 500 ms; longer collections get a proportionally larger cap"},
+		{Key: "oversizedCycleAction", Type: "string", Label: "For suspect collections", Default: defaults.OversizedCycleAction,
+			Options: []SettingOption{
+				{Value: CycleActionDiscard, Label: "Discard them"},
+				{Value: CycleActionClamp, Label: "Clamp them to the limit"},
+			}},
+		{Key: "autoBackupDays", Type: "int", Label: "Back up every (days)", Default: defaults.AutoBackupDays,
+			Min: intPtr(1), Options: []SettingOption{{Value: 0, Label: "Never"}}},
+		{Key: "backupDir", Type: "string", Label: "Backup folder", Default: defaults.BackupDir, Optional: true,
+			Note: "Empty uses a backups folder next to the database"},
+		{Key: "backupKeep", Type: "int", Label: "Backups to keep", Default: defaults.BackupKeep, Min: intPtr(MinBackupKeep)},
+		{Key: "minProcessLifetimeSeconds", Type: "int", Label: "Store processes by name after (seconds)", Default: defaults.MinProcessLifetimeSeconds,
+			Min: intPtr(0), Max: intPtr(MaxProcessLifetimeSeconds), Note: "Younger processes are stored as Transient; 0 disables"},
+		{Key: "trackLANTraffic", Type: "bool", Label: "Count local network traffic", Default: defaults.TrackLANTraffic,
+			Note: "Off leaves TCP traffic to 10/8, 172.16/12, 192.168/16 and fe80::/10 out of app usage"},
+		{Key: "rollupByProcessTree", Type: "bool", Label: "Count child processes under the app that started them", Default: defaults.RollupByProcessTree},
+		{Key: "storageGranularitySeconds", Type: "int", Label: "Store usage in buckets of (seconds)", Default: defaults.StorageGranularitySeconds,
+			Min: intPtr(0), Max: intPtr(MaxStorageGranularitySeconds), Note: "0 stores every collection; live stats are unaffected"},
+		{Key: "speedTestURL", Type: "string", Label: "Speed test download", Default: defaults.SpeedTestURL,
+			Note: "An http or https URL; the test stops after 30 seconds or 100 MiB"},
+		{Key: "destinationRules", Type: "objectList", Label: "Destination ranges", Default: defaults.DestinationRules,
+			Fields: []SettingSchema{
+				{Key: "cidr", Type: "string", Label: "IPv4 range (CIDR)"},
+				{Key: "label", Type: "string", Label: "Label"},
+			}},
+		{Key: "usageTierThresholds", Type: "object", Label: "Usage tiers", Default: defaults.UsageTierThresholds,
+			Note: "Apps are light below the medium threshold and heavy from the heavy threshold",
+			Fields: []SettingSchema{
+				{Key: "mediumBytes", Type: "int", Label: "Medium from (bytes)", Default: defaults.UsageTierThresholds.MediumBytes, Min: intPtr(1)},
+				{Key: "heavyBytes", Type: "int", Label: "Heavy from (bytes)", Default: defaults.UsageTierThresholds.HeavyBytes,
+					Note: "Must be above the medium threshold"},
+			}},
+		{Key: "monitor", Type: "object", Label: "Monitor cadence", Default: defaults.Monitor,
+			Fields: []SettingSchema{
+				{Key: "updateIntervalMs", Type: "int", Label: "Update interval (ms)", Default: defaults.Monitor.UpdateIntervalMs,
+					Min: intPtr(MinUpdateIntervalMs), Max: intPtr(MaxUpdateIntervalMs)},
+				{Key: "batchIntervalSeconds", Type: "int", Label: "Write interval (seconds)", Default: defaults.Monitor.BatchIntervalSeconds,
+					Min: intPtr(MinBatchIntervalSeconds), Max: intPtr(MaxBatchIntervalSeconds),
+					Note: "Must not be shorter than the update interval"},
+				{Key: "cleanupThresholdMs", Type: "int", Label: "Mark apps inactive after (ms)", Default: defaults.Monitor.CleanupThresholdMs,
+					Max: intPtr(MaxCleanupThresholdMs), Note: "At least twice the update interval"},
+				{Key: "inactiveGraceMs", Type: "int", Label: "Keep inactive apps for (ms)", Default: defaults.Monitor.InactiveGraceMs,
+					Min: intPtr(0), Max: intPtr(MaxInactiveGraceMs)},
+				{Key: "batchSizeThreshold", Type: "int", Label: "Write early at pending records", Default: defaults.Monitor.BatchSizeThreshold,
+					Min: intPtr(MinBatchSizeThreshold), Max: intPtr(MaxBatchSizeThreshold),
+					Options: []SettingOption{{Value: 0, Label: "Never"}}},
+				{Key: "warmupSamples", Type: "int", Label: "Warm up for (collections)", Default: defaults.Monitor.WarmupSamples,
+					Min: intPtr(MinWarmupSamples), Max: intPtr(MaxWarmupSamples)},
+				{Key: "maxTrackedApps", Type: "int", Label: "Apps kept in live stats", Default: defaults.Monitor.MaxTrackedApps,
+					Min: intPtr(MinMaxTrackedApps), Max: intPtr(MaxMaxTrackedApps), Note: "The least recently active apps are dropped beyond this"},
+			}},
+	}
+}
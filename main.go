@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/wailsapp/wails/v2"
@@ -16,6 +17,7 @@ import (
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
 	"github.com/wailsapp/wails/v2/pkg/options/windows"
 
+	"netpus/internal/brand"
 	"netpus/internal/installer"
 )
 
@@ -23,9 +25,11 @@ import (
 var assets embed.FS
 
 var (
-	installFlag   = flag.Bool("install", false, "Install Netpus (create shortcuts)")
-	uninstallFlag = flag.Bool("uninstall", false, "Uninstall Netpus (remove shortcuts)")
+	installFlag   = flag.Bool("install", false, "Install "+brand.AppName+" (create shortcuts)")
+	uninstallFlag = flag.Bool("uninstall", false, "Uninstall "+brand.AppName+" (remove shortcuts)")
 	versionFlag   = flag.Bool("version", false, "Show version information")
+	configFlag    = flag.String("config", "", "Path to a JSON file with default settings (stored settings take precedence)")
+	delayFlag     = flag.Int("delay", 0, "Seconds to wait before starting the network monitor (set by autostart)")
 )
 
 const version = "1.0.0"
@@ -52,7 +56,7 @@ const (
 
 // checkSingleInstance returns true if this is the first instance
 func checkSingleInstance() bool {
-	mutexName, _ := syscall.UTF16PtrFromString("Global\\NetpusNetworkMonitor")
+	mutexName, _ := syscall.UTF16PtrFromString(brand.MutexName())
 
 	ret, _, _ := createMutexW.Call(0, 0, uintptr(unsafe.Pointer(mutexName)))
 	if ret == 0 {
@@ -69,10 +73,10 @@ func checkSingleInstance() bool {
 	return true
 }
 
-// bringExistingToFront finds and shows the existing Netpus window
+// bringExistingToFront finds and shows the existing app window
 func bringExistingToFront() {
-	// Try to find the Netpus window by title
-	windowTitle, _ := syscall.UTF16PtrFromString("Netpus Network Monitor")
+	// Try to find the window by title
+	windowTitle, _ := syscall.UTF16PtrFromString(brand.ProductName)
 	hwnd, _, _ := findWindowW.Call(0, uintptr(unsafe.Pointer(windowTitle)))
 
 	if hwnd != 0 {
@@ -87,7 +91,7 @@ func main() {
 
 	// Handle CLI flags
 	if *versionFlag {
-		fmt.Printf("Netpus Network Monitor v%s\n", version)
+		fmt.Printf("%s v%s\n", brand.ProductName, version)
 		fmt.Printf("Platform: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 		os.Exit(0)
 	}
@@ -100,7 +104,7 @@ func main() {
 		if err := installer.Install(execPath); err != nil {
 			log.Fatalf("Installation failed: %v", err)
 		}
-		fmt.Println("Netpus installed successfully!")
+		fmt.Println(brand.AppName + " installed successfully!")
 		os.Exit(0)
 	}
 
@@ -108,19 +112,19 @@ func main() {
 		if err := installer.Uninstall(); err != nil {
 			log.Fatalf("Uninstallation failed: %v", err)
 		}
-		fmt.Println("Netpus uninstalled successfully!")
+		fmt.Println(brand.AppName + " uninstalled successfully!")
 		os.Exit(0)
 	}
 
 	// Check for single instance using Windows mutex
 	if !checkSingleInstance() {
 		// Another instance is running, we tried to bring it to front
-		fmt.Println("Netpus is already running. Bringing existing window to front.")
+		fmt.Println(brand.AppName + " is already running. Bringing existing window to front.")
 		os.Exit(0)
 	}
 
 	// Also use lock file as backup
-	lockPath := filepath.Join(os.TempDir(), "netpus.lock")
+	lockPath := filepath.Join(os.TempDir(), brand.LockFileName())
 	lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 	if err == nil {
 		fmt.Fprintf(lockFile, "%d", os.Getpid())
@@ -129,11 +133,11 @@ func main() {
 	}
 
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp(*configFlag, time.Duration(*delayFlag)*time.Second)
 
 	// Create application with options
 	runErr := wails.Run(&options.App{
-		Title:  "Netpus Network Monitor",
+		Title:  brand.ProductName,
 		Width:  1440,
 		Height: 768,
 		AssetServer: &assetserver.Options{